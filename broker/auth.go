@@ -0,0 +1,190 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package broker
+
+import (
+	log "github.com/cihub/seelog"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/auth"
+	"github.com/trustedanalytics/application-broker/env"
+	"github.com/trustedanalytics/application-broker/oauth"
+	"net/http"
+	"strings"
+)
+
+// role is the set of broker endpoints allowed for credentials
+type role string
+
+const (
+	// platformRole is used by Cloud Controller calling Service Broker API and by instance management
+	platformRole role = "platform"
+	// catalogAdminRole manages the catalog of services offered in the marketplace
+	catalogAdminRole role = "catalog-admin"
+	// instanceOwnerRole is granted to bearer tokens of platform users, handlers check they own the instance
+	instanceOwnerRole role = "instance-owner"
+)
+
+type credential struct {
+	username string
+	password string
+	role     role
+}
+
+// roles are mapped to martini context of authenticated requests
+type roles []role
+
+func (r roles) has(allowed ...role) bool {
+	for _, granted := range r {
+		for _, candidate := range allowed {
+			if granted == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type credentials []credential
+
+// credentialsFromEnv reads broker credentials. AUTH_USER and AUTH_PASS are the platform credentials registered
+// in Cloud Controller, AUTH_ALTERNATIVE_CREDENTIALS are platform credentials accepted as well, e.g. while rotating them.
+// Without CATALOG_ADMIN_CREDENTIALS catalog is managed with platform credentials, as before roles were introduced.
+func credentialsFromEnv() credentials {
+	creds := credentials{{
+		username: env.GetEnvVarAsString("AUTH_USER", ""),
+		password: env.GetEnvVarAsString("AUTH_PASS", ""),
+		role:     platformRole,
+	}}
+	creds = append(creds, parseCredentials(env.GetEnvVarAsString("AUTH_ALTERNATIVE_CREDENTIALS", ""), platformRole)...)
+
+	admins := parseCredentials(env.GetEnvVarAsString("CATALOG_ADMIN_CREDENTIALS", ""), catalogAdminRole)
+	if len(admins) == 0 {
+		log.Warn("CATALOG_ADMIN_CREDENTIALS not set, catalog can be modified with platform credentials")
+		for _, platform := range creds {
+			admins = append(admins, credential{platform.username, platform.password, catalogAdminRole})
+		}
+	}
+	return append(creds, admins...)
+}
+
+// parseCredentials reads comma separated username:password pairs
+func parseCredentials(pairs string, r role) credentials {
+	creds := credentials{}
+	for _, pair := range strings.Split(pairs, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+		tokens := strings.SplitN(pair, ":", 2)
+		if len(tokens) != 2 || len(tokens[0]) == 0 {
+			log.Errorf("Ignoring malformed %v credentials, expected username:password", r)
+			continue
+		}
+		creds = append(creds, credential{username: tokens[0], password: tokens[1], role: r})
+	}
+	return creds
+}
+
+// rolesOf returns roles of all credentials matching username and password
+func (c credentials) rolesOf(username, password string) roles {
+	granted := roles{}
+	for _, cred := range c {
+		// both compared every time, so that response time does not tell which one is wrong
+		usernameMatches := auth.SecureCompare(username, cred.username)
+		passwordMatches := auth.SecureCompare(password, cred.password)
+		if usernameMatches && passwordMatches {
+			granted = append(granted, cred.role)
+		}
+	}
+	return granted
+}
+
+// tokenAuthenticator accepts bearer tokens of operators in place of catalog admin credentials,
+// and tokens of platform users managing their own instances
+type tokenAuthenticator struct {
+	validator *oauth.Validator
+	scopes    []string
+}
+
+// tokenAuthenticatorFromEnv returns nil unless ADMIN_TOKEN_KEYS_URL is set
+func tokenAuthenticatorFromEnv() *tokenAuthenticator {
+	keysURL := env.GetEnvVarAsString("ADMIN_TOKEN_KEYS_URL", "")
+	if len(keysURL) == 0 {
+		return nil
+	}
+	scopes := strings.Split(env.GetEnvVarAsString("ADMIN_TOKEN_SCOPES", "application_broker.admin"), ",")
+	for i := range scopes {
+		scopes[i] = strings.TrimSpace(scopes[i])
+	}
+	return &tokenAuthenticator{
+		validator: oauth.NewValidator(keysURL, env.GetEnvVarAsString("ADMIN_TOKEN_ISSUER", "")),
+		scopes:    scopes,
+	}
+}
+
+// authenticate validates bearer token and maps its claims, user and roles.
+// Catalog admin role is granted only to tokens with required scopes.
+func (t *tokenAuthenticator) authenticate(res http.ResponseWriter, token string, c martini.Context) {
+	claims, err := t.validator.Validate(token)
+	if err != nil {
+		log.Warnf("Rejecting bearer token: %v", err)
+		res.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(res, "Not Authorized", http.StatusUnauthorized)
+		return
+	}
+	granted := roles{instanceOwnerRole}
+	if claims.HasScopes(t.scopes) {
+		granted = append(granted, catalogAdminRole)
+	}
+	c.Map(claims)
+	c.Map(auth.User(claims.Actor()))
+	c.Map(granted)
+}
+
+// authenticate responds with 401 to requests without valid credentials, roles of valid ones are mapped for authorize.
+// Bearer tokens are accepted when tokens is not nil.
+func authenticate(creds credentials, tokens *tokenAuthenticator) martini.Handler {
+	return func(res http.ResponseWriter, req *http.Request, c martini.Context) {
+		header := req.Header.Get("Authorization")
+		if tokens != nil && strings.HasPrefix(header, "Bearer ") {
+			tokens.authenticate(res, strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")), c)
+			return
+		}
+		username, password, ok := req.BasicAuth()
+		granted := roles{}
+		if ok {
+			granted = creds.rolesOf(username, password)
+		}
+		if len(granted) == 0 {
+			res.Header().Set("WWW-Authenticate", "Basic realm=\""+auth.BasicRealm+"\"")
+			http.Error(res, "Not Authorized", http.StatusUnauthorized)
+			return
+		}
+		c.Map((*oauth.Claims)(nil))
+		c.Map(auth.User(username))
+		c.Map(granted)
+	}
+}
+
+// authorize responds with 403 to authenticated requests without any of allowed roles
+func authorize(allowed ...role) martini.Handler {
+	return func(res http.ResponseWriter, granted roles) {
+		if !granted.has(allowed...) {
+			http.Error(res, "Forbidden", http.StatusForbidden)
+		}
+	}
+}
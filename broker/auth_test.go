@@ -0,0 +1,220 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package broker
+
+import (
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/auth"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/trustedanalytics/application-broker/oauth"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var _ = Describe("Auth", func() {
+
+	Describe("parsing credentials", func() {
+		It("should read comma separated pairs", func() {
+			creds := parseCredentials("ops:first, ci:se:cret,,", catalogAdminRole)
+
+			Expect(creds).To(Equal(credentials{
+				{username: "ops", password: "first", role: catalogAdminRole},
+				{username: "ci", password: "se:cret", role: catalogAdminRole},
+			}))
+		})
+
+		It("should ignore malformed pairs", func() {
+			Expect(parseCredentials("no-password,:no-user", platformRole)).To(BeEmpty())
+		})
+	})
+
+	Describe("authorization", func() {
+
+		var (
+			sut *martini.ClassicMartini
+		)
+
+		serve := func(method, path, username, password string) int {
+			recorder := httptest.NewRecorder()
+			req, _ := http.NewRequest(method, path, nil)
+			if len(username) > 0 {
+				req.SetBasicAuth(username, password)
+			}
+			sut.ServeHTTP(recorder, req)
+			return recorder.Code
+		}
+
+		BeforeEach(func() {
+			creds := credentials{
+				{username: "cc", password: "new", role: platformRole},
+				{username: "cc", password: "old", role: platformRole},
+				{username: "ops", password: "admin", role: catalogAdminRole},
+			}
+			ok := func() string { return "ok" }
+			sut = martini.Classic()
+			sut.Use(authenticate(creds, nil))
+			sut.Put("/provision", authorize(platformRole), ok)
+			sut.Post("/catalog", authorize(catalogAdminRole), ok)
+			sut.Get("/catalog", authorize(platformRole, catalogAdminRole), ok)
+		})
+
+		It("should reject requests without valid credentials", func() {
+			Expect(serve("GET", "/catalog", "", "")).To(Equal(http.StatusUnauthorized))
+			Expect(serve("GET", "/catalog", "cc", "wrong")).To(Equal(http.StatusUnauthorized))
+			Expect(serve("GET", "/catalog", "ops", "new")).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should accept every configured pair of role", func() {
+			Expect(serve("PUT", "/provision", "cc", "new")).To(Equal(http.StatusOK))
+			Expect(serve("PUT", "/provision", "cc", "old")).To(Equal(http.StatusOK))
+		})
+
+		It("should forbid catalog changes to platform", func() {
+			Expect(serve("POST", "/catalog", "cc", "new")).To(Equal(http.StatusForbidden))
+			Expect(serve("POST", "/catalog", "ops", "admin")).To(Equal(http.StatusOK))
+		})
+
+		It("should forbid provisioning to catalog admin", func() {
+			Expect(serve("PUT", "/provision", "ops", "admin")).To(Equal(http.StatusForbidden))
+		})
+
+		It("should let both roles read catalog", func() {
+			Expect(serve("GET", "/catalog", "cc", "old")).To(Equal(http.StatusOK))
+			Expect(serve("GET", "/catalog", "ops", "admin")).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("bearer tokens", func() {
+
+		var (
+			sut    *martini.ClassicMartini
+			issuer *oauth.IssuerMock
+			dir    string
+		)
+
+		serve := func(method, path, token string) (int, string) {
+			recorder := httptest.NewRecorder()
+			req, _ := http.NewRequest(method, path, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			sut.ServeHTTP(recorder, req)
+			return recorder.Code, recorder.Body.String()
+		}
+
+		token := func(scope ...string) string {
+			return issuer.Sign(map[string]interface{}{
+				"user_name": "operator",
+				"user_id":   "user-guid",
+				"scope":     scope,
+				"exp":       time.Now().Add(time.Hour).Unix(),
+			})
+		}
+
+		BeforeEach(func() {
+			var err error
+			issuer, err = oauth.NewIssuerMock("key-1")
+			Expect(err).NotTo(HaveOccurred())
+			dir, err = ioutil.TempDir("", "broker-auth")
+			Expect(err).NotTo(HaveOccurred())
+			keySet := filepath.Join(dir, "keys.json")
+			Expect(issuer.WriteKeySet(keySet)).To(Succeed())
+			os.Setenv("ADMIN_TOKEN_KEYS_URL", "file://"+keySet)
+
+			actor := func(user auth.User) string { return string(user) }
+			sut = martini.Classic()
+			sut.Use(authenticate(credentials{}, tokenAuthenticatorFromEnv()))
+			sut.Put("/provision", authorize(platformRole), actor)
+			sut.Post("/catalog", authorize(catalogAdminRole), actor)
+			sut.Post("/extend", authorize(platformRole, instanceOwnerRole), func(claims *oauth.Claims) string {
+				return claims.UserID
+			})
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("ADMIN_TOKEN_KEYS_URL")
+			os.RemoveAll(dir)
+		})
+
+		It("should let catalog admin in with token of required scope", func() {
+			code, body := serve("POST", "/catalog", token("application_broker.admin"))
+
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("operator"))
+		})
+
+		It("should reject token without required scope", func() {
+			code, _ := serve("POST", "/catalog", token("openid"))
+
+			Expect(code).To(Equal(http.StatusForbidden))
+		})
+
+		It("should let token without required scope in as instance owner", func() {
+			code, body := serve("POST", "/extend", token("openid"))
+
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(body).To(Equal("user-guid"))
+		})
+
+		It("should reject invalid token", func() {
+			code, _ := serve("POST", "/catalog", token("application_broker.admin")+"x")
+
+			Expect(code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should not grant platform role", func() {
+			code, _ := serve("PUT", "/provision", token("application_broker.admin"))
+
+			Expect(code).To(Equal(http.StatusForbidden))
+		})
+	})
+
+	Describe("credentials from environment", func() {
+
+		AfterEach(func() {
+			os.Unsetenv("AUTH_USER")
+			os.Unsetenv("AUTH_PASS")
+			os.Unsetenv("AUTH_ALTERNATIVE_CREDENTIALS")
+			os.Unsetenv("CATALOG_ADMIN_CREDENTIALS")
+		})
+
+		BeforeEach(func() {
+			os.Setenv("AUTH_USER", "cc")
+			os.Setenv("AUTH_PASS", "new")
+			os.Setenv("AUTH_ALTERNATIVE_CREDENTIALS", "cc:old")
+		})
+
+		It("should separate catalog admin credentials", func() {
+			os.Setenv("CATALOG_ADMIN_CREDENTIALS", "ops:admin")
+
+			creds := credentialsFromEnv()
+
+			Expect(creds.rolesOf("cc", "new")).To(Equal(roles{platformRole}))
+			Expect(creds.rolesOf("cc", "old")).To(Equal(roles{platformRole}))
+			Expect(creds.rolesOf("ops", "admin")).To(Equal(roles{catalogAdminRole}))
+		})
+
+		It("should let platform manage catalog when catalog admin credentials are not set", func() {
+			creds := credentialsFromEnv()
+
+			Expect(creds.rolesOf("cc", "old")).To(Equal(roles{platformRole, catalogAdminRole}))
+		})
+	})
+})
@@ -17,26 +17,47 @@
 package broker
 
 import (
+	"context"
 	"fmt"
 	log "github.com/cihub/seelog"
+	"github.com/trustedanalytics/application-broker/health"
 	"github.com/trustedanalytics/application-broker/service/extension"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 )
 
 // Broker represents a running CF Service Broker API
 type Broker struct {
-	router *router
+	router   *router
+	provider extension.ServiceProviderExtension
+	// stop is closed on shutdown, jobs are background jobs running until then
+	stop chan struct{}
+	jobs sync.WaitGroup
 }
 
-// New creates a loaded instance of the broker
-func New(p extension.ServiceProviderExtension) (*Broker, error) {
+// New creates a loaded instance of the broker. Checks of broker dependencies are run on readiness probe.
+func New(p extension.ServiceProviderExtension, checks []health.Check) (*Broker, error) {
 	return &Broker{
-		router: newRouter(newHandler(p)),
+		router:   newRouter(newHandler(p), checks),
+		provider: p,
+		stop:     make(chan struct{}),
 	}, nil
 }
 
+// Background runs job until the broker shuts down. Job has to return once stop channel is closed, after
+// operation it is in the middle of completes. Shutdown awaits jobs the same way as requests in progress.
+func (b *Broker) Background(job func(stop <-chan struct{})) {
+	b.jobs.Add(1)
+	go func() {
+		defer b.jobs.Done()
+		job(b.stop)
+	}()
+}
+
 // Start the broker
 func (b *Broker) Start(config Config) {
 
@@ -46,12 +67,27 @@ func (b *Broker) Start(config Config) {
 	sigCh := make(chan os.Signal, 1)
 
 	// make sure we can shutdown gracefully
-	signal.Notify(sigCh, os.Interrupt)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 
 	errCh := make(chan error, 1)
 
+	server := &http.Server{Addr: addr, Handler: b.router}
+	if config.TLSEnabled() {
+		certificates, err := newCertificateReloader(config.TLSCertFile, config.TLSKeyFile,
+			config.TLSClientCAFile, config.TLSReloadInterval)
+		if err != nil {
+			log.Errorf("cannot load TLS certificates: %v", err)
+			return
+		}
+		server.TLSConfig = certificates.tlsConfig()
+		log.Infof("serving HTTPS, client certificates required: %v", len(config.TLSClientCAFile) > 0)
+	}
 	go func() {
-		errCh <- http.ListenAndServe(addr, b.router)
+		if server.TLSConfig != nil {
+			errCh <- server.ListenAndServeTLS("", "")
+		} else {
+			errCh <- server.ListenAndServe()
+		}
 	}()
 
 	// non blocking as some of these cf ops are kind of lengthy
@@ -59,8 +95,34 @@ func (b *Broker) Start(config Config) {
 	case err := <-errCh:
 		log.Errorf("broker error: %v", err)
 	case sig := <-sigCh:
-		var _ = sig
+		log.Infof("received %v, shutting down", sig)
+		b.shutdown(server, config.ShutdownTimeout, config.ShutdownRollbackTimeout)
 		log.Info("broker done")
 	}
 
 }
+
+// shutdown stops accepting requests and background jobs, and lets those in progress complete within timeout.
+// Provisionings still running after it are aborted and have rollbackTimeout to remove what they created.
+// It returns false when requests or jobs did not complete in time.
+func (b *Broker) shutdown(server *http.Server, timeout, rollbackTimeout time.Duration) bool {
+	close(b.stop)
+	deadline := time.Now().Add(timeout)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	if err := server.Shutdown(ctx); err == nil && waitFor(&b.jobs, time.Until(deadline)) {
+		log.Info("all requests and background jobs completed")
+		return true
+	}
+
+	log.Warnf("requests or background jobs still in progress after %v, aborting provisionings", timeout)
+	b.provider.Abort()
+	rollbackDeadline := time.Now().Add(rollbackTimeout)
+	if !b.router.wait(rollbackTimeout) || !waitFor(&b.jobs, time.Until(rollbackDeadline)) {
+		log.Errorf("requests or background jobs still in progress after %v, components they created "+
+			"may be left in the space", rollbackTimeout)
+		return false
+	}
+	log.Info("aborted requests and background jobs completed")
+	return true
+}
@@ -0,0 +1,150 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package broker
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	"github.com/trustedanalytics/application-broker/dao"
+	"github.com/trustedanalytics/application-broker/messagebus"
+	"github.com/trustedanalytics/application-broker/service"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var _ = Describe("Broker", func() {
+
+	var (
+		sut      *Broker
+		cfMock   *service.CfMock
+		server   *http.Server
+		started  chan struct{}
+		release  func()
+		finished chan struct{}
+	)
+
+	// inFlight sends request to handler blocking until released. Goroutine uses channels of its own spec only.
+	inFlight := func() {
+		addr, done := server.Addr, finished
+		go func() {
+			req, _ := http.NewRequest("GET", "http://"+addr+"/slow", nil)
+			req.SetBasicAuth("", "")
+			resp, err := http.DefaultClient.Do(req)
+			if err == nil {
+				resp.Body.Close()
+			}
+			close(done)
+		}()
+		Eventually(started).Should(BeClosed())
+	}
+
+	BeforeEach(func() {
+		cfMock = new(service.CfMock)
+		svc := service.New(new(dao.FacadeMock), cfMock, new(messagebus.DevNullBus), service.CreationStatusFactory{})
+		b, err := New(svc, nil)
+		Expect(err).NotTo(HaveOccurred())
+		sut = b
+
+		started, finished = make(chan struct{}), make(chan struct{})
+		wasStarted, released := started, make(chan struct{})
+		var once sync.Once
+		release = func() { once.Do(func() { close(released) }) }
+		sut.router.m.Get("/slow", func() string {
+			close(wasStarted)
+			<-released
+			return "done"
+		})
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		server = &http.Server{Addr: listener.Addr().String(), Handler: sut.router}
+		go server.Serve(listener)
+	})
+
+	// Requests of the spec are completed and its server closed before the next spec starts
+	AfterEach(func() {
+		release()
+		select {
+		case <-started:
+			Eventually(finished).Should(BeClosed())
+		default:
+		}
+		server.Close()
+	})
+
+	It("should let requests in progress complete", func() {
+		inFlight()
+		go func(release func()) {
+			time.Sleep(50 * time.Millisecond)
+			release()
+		}(release)
+
+		Expect(sut.shutdown(server, time.Second, time.Second)).To(BeTrue())
+		Eventually(finished).Should(BeClosed())
+		cfMock.AssertNotCalled(GinkgoT(), "Abort")
+	})
+
+	It("should abort provisionings still in progress after timeout", func() {
+		release := release
+		cfMock.On("Abort").Return().Run(func(mock.Arguments) { release() })
+		inFlight()
+
+		Expect(sut.shutdown(server, 50*time.Millisecond, time.Second)).To(BeTrue())
+		cfMock.AssertCalled(GinkgoT(), "Abort")
+	})
+
+	It("should give up when aborted provisionings do not complete in time", func() {
+		cfMock.On("Abort").Return()
+		inFlight()
+
+		Expect(sut.shutdown(server, 50*time.Millisecond, 50*time.Millisecond)).To(BeFalse())
+	})
+
+	It("should stop accepting requests", func() {
+		Expect(sut.shutdown(server, time.Second, time.Second)).To(BeTrue())
+
+		_, err := http.Get("http://" + server.Addr + "/slow")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should stop background jobs and let their iteration complete", func() {
+		stopped := make(chan struct{})
+		sut.Background(func(stop <-chan struct{}) {
+			<-stop
+			time.Sleep(50 * time.Millisecond)
+			close(stopped)
+		})
+
+		Expect(sut.shutdown(server, time.Second, time.Second)).To(BeTrue())
+		Expect(stopped).To(BeClosed())
+		cfMock.AssertNotCalled(GinkgoT(), "Abort")
+	})
+
+	It("should give up when background jobs do not stop in time", func() {
+		cfMock.On("Abort").Return()
+		sut.Background(func(stop <-chan struct{}) {
+			<-stop
+			time.Sleep(time.Second)
+		})
+
+		Expect(sut.shutdown(server, 50*time.Millisecond, 50*time.Millisecond)).To(BeFalse())
+		cfMock.AssertCalled(GinkgoT(), "Abort")
+	})
+})
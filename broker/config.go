@@ -21,11 +21,28 @@ import (
 	"github.com/cloudfoundry-community/go-cfenv"
 	"github.com/trustedanalytics/application-broker/env"
 	"os"
+	"time"
 )
 
 // Config hold the broker configuration
 type Config struct {
 	CFEnv *cfenv.App
+	// Time given to requests in progress to complete on shutdown
+	ShutdownTimeout time.Duration
+	// Time given to aborted provisionings to roll back, Cloud Foundry kills the broker 10s after SIGTERM
+	ShutdownRollbackTimeout time.Duration
+	// Broker serves HTTPS when certificate and key files are set
+	TLSCertFile string
+	TLSKeyFile  string
+	// CA of client certificates, when set clients have to authenticate with a certificate it signed
+	TLSClientCAFile string
+	// How often certificate files are checked for changes
+	TLSReloadInterval time.Duration
+}
+
+// TLSEnabled tells whether broker serves HTTPS
+func (c *Config) TLSEnabled() bool {
+	return len(c.TLSCertFile) > 0
 }
 
 // Initialize config with values from environment variables
@@ -39,4 +56,10 @@ func (c *Config) Initialize(cfEnv *cfenv.App) {
 		cfEnv.TempDir = os.TempDir()
 	}
 	c.CFEnv = cfEnv
+	c.ShutdownTimeout = time.Duration(env.GetEnvVarAsInt("SHUTDOWN_TIMEOUT", 5)) * time.Second
+	c.ShutdownRollbackTimeout = time.Duration(env.GetEnvVarAsInt("SHUTDOWN_ROLLBACK_TIMEOUT", 4)) * time.Second
+	c.TLSCertFile = env.GetEnvVarAsString("TLS_CERT_FILE", "")
+	c.TLSKeyFile = env.GetEnvVarAsString("TLS_KEY_FILE", "")
+	c.TLSClientCAFile = env.GetEnvVarAsString("TLS_CLIENT_CA_FILE", "")
+	c.TLSReloadInterval = time.Duration(env.GetEnvVarAsInt("TLS_RELOAD_INTERVAL", 10)) * time.Second
 }
@@ -21,9 +21,17 @@ import (
 	log "github.com/cihub/seelog"
 	"github.com/cloudfoundry-community/types-cf"
 	"github.com/go-martini/martini"
+	"github.com/martini-contrib/auth"
+	"github.com/trustedanalytics/application-broker/logging"
+	"github.com/trustedanalytics/application-broker/misc"
+	"github.com/trustedanalytics/application-broker/oauth"
 	"github.com/trustedanalytics/application-broker/service/extension"
+	"github.com/trustedanalytics/application-broker/tracing"
 	"github.com/trustedanalytics/go-cf-lib/types"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"strings"
 	"github.com/signalfx/golib/errors"
 )
 
@@ -39,7 +47,7 @@ func newHandler(p extension.ServiceProviderExtension) *handler {
 //
 // Appends service to the catalog managed by this broker
 //
-// Privilege level: Consumer of this endpoint must login using basic authentication credentials (valid login and password)
+// Privilege level: Consumer of this endpoint must login using catalog admin basic authentication credentials or bearer token with catalog admin scopes
 //
 //     Responses:
 //       201: serviceExtensionResponse
@@ -47,18 +55,19 @@ func newHandler(p extension.ServiceProviderExtension) *handler {
 //       404: emptyBodyNotFound
 //       409: emptyBodyConflict
 //       500: brokerErrorResponse
-func (h *handler) append(req *http.Request, params martini.Params) (int, string) {
-	log.Infof("handler appending new service to catalog: [%v]", req.Body)
+func (h *handler) append(req *http.Request, params martini.Params, user auth.User) (int, string) {
 	toAdd := extension.NewAutogeneratedService()
 	err := json.NewDecoder(req.Body).Decode(&toAdd)
 	if err != nil {
 		return handleDecodingError(err)
 	}
+	log.Infof("handler appending new service to catalog: [%v]", toAdd.Name)
 	log.Debugf("handler provisioning request decoded: %+v", toAdd)
 
 	if err := h.provider.InsertToCatalog(toAdd); err != nil {
 		return handleServiceError(err)
 	}
+	catalogLogger(req, "append_service", toAdd.ID, user).Infof("Service %v appended to catalog", toAdd.Name)
 	return marshalEntity(responseEntity{http.StatusCreated, toAdd})
 }
 
@@ -66,7 +75,7 @@ func (h *handler) append(req *http.Request, params martini.Params) (int, string)
 //
 // Updates service in the catalog managed by this broker
 //
-// Privilege level: Consumer of this endpoint must login using basic authentication credentials (valid login and password)
+// Privilege level: Consumer of this endpoint must login using catalog admin basic authentication credentials or bearer token with catalog admin scopes
 //
 //     Responses:
 //       200: serviceExtensionResponse
@@ -74,9 +83,9 @@ func (h *handler) append(req *http.Request, params martini.Params) (int, string)
 //       404: emptyBodyNotFound
 //       409: emptyBodyConflict
 //       500: brokerErrorResponse
-func (h *handler) update(req *http.Request, params martini.Params) (int, string) {
+func (h *handler) update(req *http.Request, params martini.Params, user auth.User) (int, string) {
 	service_id := params["service_id"]
-	log.Infof("handler updating service id: [%v] in catalog: [%v]", service_id, req.Body)
+	log.Infof("handler updating service id: [%v] in catalog", service_id)
 	toUpdate := new(extension.ServiceExtension)
 
 	err := json.NewDecoder(req.Body).Decode(&toUpdate)
@@ -92,6 +101,7 @@ func (h *handler) update(req *http.Request, params martini.Params) (int, string)
 	if err := h.provider.UpdateCatalog(toUpdate); err != nil {
 		return handleServiceError(err)
 	}
+	catalogLogger(req, "update_service", toUpdate.ID, user).Infof("Service %v updated in catalog", toUpdate.Name)
 	log.Infof("ID: %v", toUpdate.ID)
 	return marshalEntity(responseEntity{http.StatusOK, toUpdate})
 }
@@ -100,7 +110,7 @@ func (h *handler) update(req *http.Request, params martini.Params) (int, string)
 //
 // Deletes service description from the catalog
 //
-// Privilege level: Consumer of this endpoint must login using basic authentication credentials (valid login and password)
+// Privilege level: Consumer of this endpoint must login using catalog admin basic authentication credentials or bearer token with catalog admin scopes
 //
 //     Responses:
 //       204: emptyBodyNoContent
@@ -108,12 +118,82 @@ func (h *handler) update(req *http.Request, params martini.Params) (int, string)
 //       404: emptyBodyNotFound
 //       409: emptyBodyConflict
 //       500: brokerErrorResponse
-func (h *handler) remove(req *http.Request, params martini.Params) (int, string) {
+func (h *handler) remove(req *http.Request, params martini.Params, user auth.User) (int, string) {
 	log.Info("handler removing service from catalog")
 	err := h.provider.DeleteFromCatalog(params["service_id"])
 	if err != nil {
 		return handleServiceError(err)
 	}
+	catalogLogger(req, "delete_service", params["service_id"], user).Infof("Service deleted from catalog")
+	return marshalEntity(responseEntity{http.StatusNoContent, emptyNoContent})
+}
+
+// swagger:route POST /v2/catalog/{service_id}/plans/{plan_id} addPlan
+//
+// Adds plan to service in the catalog managed by this broker. Plan is free unless stated otherwise.
+//
+// Privilege level: Consumer of this endpoint must login using catalog admin basic authentication credentials or bearer token with catalog admin scopes
+//
+//     Responses:
+//       201: planResponse
+//       400: brokerErrorResponse
+//       404: brokerErrorResponse
+//       409: brokerErrorResponse
+//       500: brokerErrorResponse
+func (h *handler) addPlan(req *http.Request, params martini.Params, user auth.User) (int, string) {
+	plan, status, body := decodePlan(req, params)
+	if plan == nil {
+		return status, body
+	}
+	if err := h.provider.AddPlan(params["service_id"], plan); err != nil {
+		return handleRequestError(err)
+	}
+	catalogLogger(req, "add_plan", params["service_id"], user).Infof("Plan %v (%v) added", plan.ID, plan.Name)
+	return marshalEntity(responseEntity{http.StatusCreated, plan})
+}
+
+// swagger:route PUT /v2/catalog/{service_id}/plans/{plan_id} updatePlan
+//
+// Updates plan of service in the catalog managed by this broker. Plan is free unless stated otherwise.
+//
+// Privilege level: Consumer of this endpoint must login using catalog admin basic authentication credentials or bearer token with catalog admin scopes
+//
+//     Responses:
+//       200: planResponse
+//       400: brokerErrorResponse
+//       404: brokerErrorResponse
+//       409: brokerErrorResponse
+//       500: brokerErrorResponse
+func (h *handler) updatePlan(req *http.Request, params martini.Params, user auth.User) (int, string) {
+	plan, status, body := decodePlan(req, params)
+	if plan == nil {
+		return status, body
+	}
+	if err := h.provider.UpdatePlan(params["service_id"], plan); err != nil {
+		return handleRequestError(err)
+	}
+	catalogLogger(req, "update_plan", params["service_id"], user).Infof("Plan %v (%v) updated", plan.ID, plan.Name)
+	return marshalEntity(responseEntity{http.StatusOK, plan})
+}
+
+// swagger:route DELETE /v2/catalog/{service_id}/plans/{plan_id} deletePlan
+//
+// Deletes plan from service in the catalog managed by this broker. Plan having instances cannot be deleted.
+//
+// Privilege level: Consumer of this endpoint must login using catalog admin basic authentication credentials or bearer token with catalog admin scopes
+//
+//     Responses:
+//       204: emptyBodyNoContent
+//       400: brokerErrorResponse
+//       404: brokerErrorResponse
+//       409: brokerErrorResponse
+//       500: brokerErrorResponse
+func (h *handler) removePlan(req *http.Request, params martini.Params, user auth.User) (int, string) {
+	log.Infof("handler removing plan %v of service %v", params["plan_id"], params["service_id"])
+	if err := h.provider.DeletePlan(params["service_id"], params["plan_id"]); err != nil {
+		return handleRequestError(err)
+	}
+	catalogLogger(req, "delete_plan", params["service_id"], user).Infof("Plan %v deleted", params["plan_id"])
 	return marshalEntity(responseEntity{http.StatusNoContent, emptyNoContent})
 }
 
@@ -141,6 +221,112 @@ func (h *handler) catalog(r *http.Request, params martini.Params) (int, string)
 	return marshalEntity(responseEntity{http.StatusOK, catalog})
 }
 
+// swagger:route GET /v2/catalog/export exportCatalog
+//
+// Exports the catalog with reference apps identified by org, space and name, so it can be imported in other environment
+//
+// Privilege level: Consumer of this endpoint must login using catalog admin basic authentication credentials or bearer token with catalog admin scopes
+//
+//     Produces:
+//     - application/json
+//     - application/x-yaml
+//
+//     Responses:
+//       200: catalogExportResponse
+//       400: brokerErrorResponse
+//       404: emptyBodyNotFound
+//       500: brokerErrorResponse
+func (h *handler) exportCatalog(w http.ResponseWriter, req *http.Request, params martini.Params) (int, string) {
+	log.Info("handler exporting catalog")
+	format := documentFormat(req, "Accept")
+	if _, ok := contentTypes[format]; !ok {
+		return handleDecodingError(errors.Errorf("Unsupported document format: %v", format))
+	}
+
+	doc, err := h.provider.ExportCatalog()
+	if err != nil {
+		return handleServiceError(err)
+	}
+	payload, err := misc.MarshalDocument(doc, format)
+	if err != nil {
+		log.Errorf("internal server error: %s", err)
+		return http.StatusInternalServerError, ""
+	}
+	w.Header().Set("Content-Type", contentTypes[format])
+	return http.StatusOK, string(payload)
+}
+
+// swagger:route POST /v2/catalog/import importCatalog
+//
+// Imports catalog document created by export. Reference apps are looked up by org, space and name.
+// Mode "merge" inserts and updates services, mode "replace" additionally deletes services absent in the document.
+//
+// Privilege level: Consumer of this endpoint must login using catalog admin basic authentication credentials or bearer token with catalog admin scopes
+//
+//     Consumes:
+//     - application/json
+//     - application/x-yaml
+//
+//     Responses:
+//       200: importReportResponse
+//       400: brokerErrorResponse
+//       500: brokerErrorResponse
+func (h *handler) importCatalog(req *http.Request, params martini.Params, user auth.User) (int, string) {
+	query := req.URL.Query()
+	mode := extension.ImportMode(query.Get("mode"))
+	if len(mode) == 0 {
+		mode = extension.ImportMerge
+	}
+	if !extension.IsValidImportMode(mode) {
+		return handleDecodingError(errors.Errorf("Unsupported import mode: %v", mode))
+	}
+	dryRun := query.Get("dry_run") == "true"
+	log.Infof("handler importing catalog, mode: [%v], dry run: [%v]", mode, dryRun)
+
+	raw, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return handleDecodingError(err)
+	}
+	doc := new(extension.CatalogExport)
+	if err := misc.UnmarshalDocument(raw, documentFormat(req, "Content-Type"), doc); err != nil {
+		return handleDecodingError(err)
+	}
+
+	report, err := h.provider.ImportCatalog(doc, mode, dryRun)
+	if err != nil {
+		return handleServiceError(err)
+	}
+	log.Debugf("handler catalog imported: %+v", report)
+	if !dryRun {
+		catalogLogger(req, "import_catalog", "", user).Infof("Catalog imported in %v mode", mode)
+	}
+	return marshalEntity(responseEntity{http.StatusOK, report})
+}
+
+// swagger:route POST /v2/catalog/sync syncCatalog
+//
+// Synchronizes the catalog with the document stored in file configured with CATALOG_SYNC_FILE.
+// Services missing in the document are deleted, unless they have instances.
+//
+// Privilege level: Consumer of this endpoint must login using catalog admin basic authentication credentials or bearer token with catalog admin scopes
+//
+//     Responses:
+//       200: importReportResponse
+//       400: emptyBodyBadRequest
+//       500: brokerErrorResponse
+func (h *handler) syncCatalog(req *http.Request, params martini.Params, user auth.User) (int, string) {
+	dryRun := req.URL.Query().Get("dry_run") == "true"
+	log.Infof("handler synchronizing catalog, dry run: [%v]", dryRun)
+	report, err := h.provider.SyncCatalog(dryRun)
+	if err != nil {
+		return handleRequestError(err)
+	}
+	if !dryRun {
+		catalogLogger(req, "sync_catalog", "", user).Infof("Catalog synchronized with file")
+	}
+	return marshalEntity(responseEntity{http.StatusOK, report})
+}
+
 // swagger:route PUT /v2/service_instances/{instance_id} provisionServiceInstance
 //
 // Implementation of Service Broker API method (for details check http://docs.cloudfoundry.org/services/api.html).
@@ -152,26 +338,75 @@ func (h *handler) catalog(r *http.Request, params martini.Params) (int, string)
 //     Responses:
 //       201: serviceCreationResponse
 //       400: emptyBodyBadRequest
+//       403: brokerErrorResponse
 //       404: emptyBodyNotFound
 //       409: emptyBodyConflict
 //       500: brokerErrorResponse
 func (h *handler) provision(req *http.Request, params martini.Params) (int, string) {
-	preq := &cf.ServiceCreationRequest{InstanceID: params["instance_id"]}
+	preq := new(extension.ServiceCreationRequest)
 	if err := json.NewDecoder(req.Body).Decode(&preq); err != nil {
 		return handleDecodingError(err)
 	}
+	preq.InstanceID = params["instance_id"]
 	if preq.Parameters == nil {
 		preq.Parameters = map[string]string{}
 	}
-	log.Debugf("handler provisioning request decoded: [%+v]", preq)
-	resp, err := h.provider.CreateService(preq)
+	preq.ApplyContext()
+	if header := req.Header.Get(extension.OriginatingIdentityHeader); len(header) > 0 {
+		identity, err := extension.ParseOriginatingIdentity(header)
+		if err != nil {
+			return handleRequestError(err)
+		}
+		preq.Identity = identity
+	}
+	logger := requestLogger(req, "provision", preq.ServiceID, preq.InstanceID)
+	logger.Debugf("handler provisioning request decoded: [%+v]", preq)
+	resp, err := h.provider.CreateService(preq, logger)
+	logger.Span().End(err)
 	if err != nil {
-		return handleServiceError(err)
+		return handleRequestError(err)
 	}
-	log.Debugf("handler request provisioned - response: [%+v]", resp)
+	logger.Debugf("handler request provisioned - response: [%+v]", resp)
 	return marshalEntity(responseEntity{http.StatusCreated, resp})
 }
 
+// swagger:route POST /v2/catalog/{service_id}/preview previewServiceInstance
+//
+// Describes apps, services, user provided services and bindings that provisioning of service instance would create.
+// Nothing is created. Body is the same as for provisioning. Name suffix is derived from instance_id query parameter,
+// random one is generated when it is missing.
+//
+// Privilege level: Consumer of this endpoint must login using basic authentication credentials (valid login and password)
+//
+//     Responses:
+//       200: provisioningPreviewResponse
+//       400: brokerErrorResponse
+//       404: brokerErrorResponse
+//       500: brokerErrorResponse
+func (h *handler) preview(req *http.Request, params martini.Params) (int, string) {
+	preq := new(extension.ServiceCreationRequest)
+	if err := json.NewDecoder(req.Body).Decode(&preq); err != nil {
+		return handleDecodingError(err)
+	}
+	preq.ServiceID = params["service_id"]
+	preq.InstanceID = req.URL.Query().Get("instance_id")
+	if len(preq.InstanceID) == 0 {
+		preq.InstanceID = misc.NewGUID()
+	}
+	if preq.Parameters == nil {
+		preq.Parameters = map[string]string{}
+	}
+	preq.ApplyContext()
+	logger := requestLogger(req, "preview", preq.ServiceID, preq.InstanceID)
+	logger.Debugf("handler preview request decoded: [%+v]", preq)
+	preview, err := h.provider.PreviewService(preq, logger)
+	logger.Span().End(err)
+	if err != nil {
+		return handleRequestError(err)
+	}
+	return marshalEntity(responseEntity{http.StatusOK, preview})
+}
+
 // swagger:route DELETE /v2/service_instances/{instance_id} deprovisionServiceInstance
 //
 // Implementation of Service Broker API method (for details check http://docs.cloudfoundry.org/services/api.html).
@@ -182,20 +417,192 @@ func (h *handler) provision(req *http.Request, params martini.Params) (int, stri
 //
 //     Responses:
 //       200: emptyBodyOk
+//       410: emptyBodyOk
 //       400: emptyBodyBadRequest
 //       404: emptyBodyNotFound
 //       409: emptyBodyConflict
 //       500: brokerErrorResponse
 func (h *handler) deprovision(req *http.Request, params martini.Params) (int, string) {
 	instID := params["instance_id"]
-	log.Infof("handler de-provisioning: %s", instID)
-	if err := h.provider.DeleteService(instID); err != nil {
+	logger := requestLogger(req, "deprovision", req.URL.Query().Get("service_id"), instID)
+	logger.Infof("handler de-provisioning: %s", instID)
+	err := h.provider.DeleteService(instID, logger)
+	logger.Span().End(err)
+	if err != nil {
+		// Instance may be already gone, e.g. expired. Cloud Controller treats 410 as successful deprovisioning.
+		if err == types.InstanceNotFoundError {
+			return marshalEntity(responseEntity{http.StatusGone, emptyOk})
+		}
 		return handleServiceError(err)
 	}
-	log.Debugf("handler de-provisioned: %v", instID)
+	logger.Debugf("handler de-provisioned: %v", instID)
 	return marshalEntity(responseEntity{http.StatusOK, emptyOk})
 }
 
+// swagger:route GET /v2/instances getInstances
+//
+// Lists service instances created by this broker
+//
+// Privilege level: Consumer of this endpoint must login using basic authentication credentials (valid login and password)
+//
+//     Responses:
+//       200: instancesResponse
+//       500: brokerErrorResponse
+func (h *handler) instances(req *http.Request, params martini.Params) (int, string) {
+	log.Info("handler listing service instances")
+	instances, err := h.provider.GetInstances()
+	if err != nil {
+		return handleServiceError(err)
+	}
+	exposed := make([]*extension.ServiceInstanceExtension, 0, len(instances))
+	for _, instance := range instances {
+		exposed = append(exposed, exposedInstance(instance))
+	}
+	return marshalEntity(responseEntity{http.StatusOK, exposed})
+}
+
+// swagger:route GET /v2/instances/{instance_id} getInstance
+//
+// Returns details of service instance created by this broker
+//
+// Privilege level: Consumer of this endpoint must login using basic authentication credentials (valid login and password)
+//
+//     Responses:
+//       200: instanceResponse
+//       404: emptyBodyNotFound
+//       500: brokerErrorResponse
+func (h *handler) instance(req *http.Request, params martini.Params) (int, string) {
+	instID := params["instance_id"]
+	log.Infof("handler getting service instance: %s", instID)
+	instance, err := h.provider.GetInstance(instID)
+	if err != nil {
+		return handleServiceError(err)
+	}
+	return marshalEntity(responseEntity{http.StatusOK, exposedInstance(instance)})
+}
+
+// swagger:route POST /v2/instances/{instance_id}/extend extendInstance
+//
+// Moves expiry of service instance TTL ahead from now. Without TTL given, TTL of the instance plan is used.
+//
+// Privilege level: Consumer of this endpoint must login using basic authentication credentials (valid login and password)
+// or bearer token of the user who created the instance
+//
+//     Responses:
+//       200: instanceResponse
+//       400: brokerErrorResponse
+//       403: emptyBodyForbidden
+//       404: emptyBodyNotFound
+//       500: brokerErrorResponse
+func (h *handler) extendInstance(req *http.Request, params martini.Params, claims *oauth.Claims) (int, string) {
+	instID := params["instance_id"]
+	extendReq := new(extension.ExtendRequest)
+	if err := json.NewDecoder(req.Body).Decode(extendReq); err != nil && err != io.EOF {
+		return handleDecodingError(err)
+	}
+	if claims != nil {
+		instance, err := h.provider.GetInstance(instID)
+		if err != nil {
+			return handleRequestError(err)
+		}
+		if len(instance.CreatedBy) == 0 || instance.CreatedBy != claims.UserID {
+			log.Warnf("Rejecting extension of instance %v by %v, who did not create it", instID, claims.Actor())
+			return marshalEntity(responseEntity{http.StatusForbidden, emptyForbidden})
+		}
+	}
+	log.Infof("handler extending service instance %v by [%v]", instID, extendReq.TTL)
+	instance, err := h.provider.ExtendInstance(instID, extendReq.TTL)
+	if err != nil {
+		return handleRequestError(err)
+	}
+	return marshalEntity(responseEntity{http.StatusOK, exposedInstance(instance)})
+}
+
+// swagger:route POST /v2/instances/{instance_id}/stop stopInstance
+//
+// Stops all applications of service instance
+//
+// Privilege level: Consumer of this endpoint must login using basic authentication credentials (valid login and password)
+//
+//     Responses:
+//       200: instanceResponse
+//       404: emptyBodyNotFound
+//       409: brokerErrorResponse
+//       500: brokerErrorResponse
+func (h *handler) stopInstance(req *http.Request, params martini.Params) (int, string) {
+	logger := requestLogger(req, "stop", "", params["instance_id"])
+	logger.Infof("handler stopping service instance: %s", params["instance_id"])
+	instance, err := h.provider.StopInstance(params["instance_id"], logger)
+	logger.Span().End(err)
+	if err != nil {
+		return handleRequestError(err)
+	}
+	return marshalEntity(responseEntity{http.StatusOK, exposedInstance(instance)})
+}
+
+// swagger:route POST /v2/instances/{instance_id}/start startInstance
+//
+// Starts applications of service instance in dependency order
+//
+// Privilege level: Consumer of this endpoint must login using basic authentication credentials (valid login and password)
+//
+//     Responses:
+//       200: instanceResponse
+//       404: emptyBodyNotFound
+//       409: brokerErrorResponse
+//       500: brokerErrorResponse
+func (h *handler) startInstance(req *http.Request, params martini.Params) (int, string) {
+	logger := requestLogger(req, "start", "", params["instance_id"])
+	logger.Infof("handler starting service instance: %s", params["instance_id"])
+	instance, err := h.provider.StartInstance(params["instance_id"], logger)
+	logger.Span().End(err)
+	if err != nil {
+		return handleRequestError(err)
+	}
+	return marshalEntity(responseEntity{http.StatusOK, exposedInstance(instance)})
+}
+
+// swagger:route PUT /v2/instances/{instance_id}/schedule setInstanceSchedule
+//
+// Sets working hours of service instance, outside of them instance applications are stopped
+//
+// Privilege level: Consumer of this endpoint must login using basic authentication credentials (valid login and password)
+//
+//     Responses:
+//       200: instanceResponse
+//       400: brokerErrorResponse
+//       404: emptyBodyNotFound
+//       500: brokerErrorResponse
+func (h *handler) setInstanceSchedule(req *http.Request, params martini.Params) (int, string) {
+	schedule := new(extension.Schedule)
+	if err := json.NewDecoder(req.Body).Decode(schedule); err != nil {
+		return handleDecodingError(err)
+	}
+	instance, err := h.provider.SetInstanceSchedule(params["instance_id"], schedule)
+	if err != nil {
+		return handleRequestError(err)
+	}
+	return marshalEntity(responseEntity{http.StatusOK, exposedInstance(instance)})
+}
+
+// swagger:route DELETE /v2/instances/{instance_id}/schedule deleteInstanceSchedule
+//
+// Removes working hours of service instance, so that schedule of its plan applies
+//
+// Privilege level: Consumer of this endpoint must login using basic authentication credentials (valid login and password)
+//
+//     Responses:
+//       200: instanceResponse
+//       404: emptyBodyNotFound
+//       500: brokerErrorResponse
+func (h *handler) deleteInstanceSchedule(req *http.Request, params martini.Params) (int, string) {
+	instance, err := h.provider.SetInstanceSchedule(params["instance_id"], nil)
+	if err != nil {
+		return handleServiceError(err)
+	}
+	return marshalEntity(responseEntity{http.StatusOK, exposedInstance(instance)})
+}
+
 // swagger:route PUT /service_instances/{instance_id}/service_bindings/{binding_id} bindService
 //
 // Implementation of Service Broker API method (for details check http://docs.cloudfoundry.org/services/api.html).
@@ -257,6 +664,67 @@ func (h *handler) unbind(req *http.Request, params martini.Params) (int, string)
 }
 
 // helpers
+var contentTypes = map[string]string{
+	misc.FormatJSON: "application/json; charset=utf-8",
+	misc.FormatYAML: "application/x-yaml; charset=utf-8",
+}
+
+// documentFormat picks format of a document exchanged in request, preferring explicit format query parameter
+func documentFormat(req *http.Request, header string) string {
+	if format := req.URL.Query().Get("format"); len(format) > 0 {
+		return strings.ToLower(format)
+	}
+	if strings.Contains(req.Header.Get(header), misc.FormatYAML) {
+		return misc.FormatYAML
+	}
+	return misc.FormatJSON
+}
+
+// decodePlan reads plan from request body. Plan without free flag is free, as Service Broker API defines.
+func decodePlan(req *http.Request, params martini.Params) (*cf.Plan, int, string) {
+	plan := &cf.Plan{Free: true}
+	if err := json.NewDecoder(req.Body).Decode(plan); err != nil {
+		status, body := handleDecodingError(err)
+		return nil, status, body
+	}
+	if len(plan.ID) > 0 && plan.ID != params["plan_id"] {
+		log.Warn("Plan id in URL different from plan id in body send")
+		status, body := handleServiceError(types.InvalidInputError)
+		return nil, status, body
+	}
+	plan.ID = params["plan_id"]
+	log.Debugf("handler plan request decoded: %+v", plan)
+	return plan, 0, ""
+}
+
+// requestLogger returns logger tagging entries with the request id assigned by the router
+// and with the operation performed on the service instance.
+// The operation is traced as a span continuing trace of the caller, handler has to end it.
+func requestLogger(req *http.Request, operation, serviceID, instanceID string) *logging.Logger {
+	logger := logging.NewLogger(logging.Fields{
+		RequestID:  req.Header.Get(logging.RequestIDHeader),
+		Operation:  operation,
+		ServiceID:  serviceID,
+		InstanceID: instanceID,
+	})
+	span := tracing.StartRemote(req.Header.Get(tracing.TraceParentHeader), operation, tracing.ServerKind)
+	span.SetAttribute("request_id", logger.Fields().RequestID)
+	span.SetAttribute("service_id", serviceID)
+	span.SetAttribute("instance_id", instanceID)
+	return logger.WithSpan(span)
+}
+
+// catalogLogger creates logger of catalog change recording who made it,
+// user of basic authentication credentials or of bearer token
+func catalogLogger(req *http.Request, operation, serviceID string, user auth.User) *logging.Logger {
+	return logging.NewLogger(logging.Fields{
+		RequestID: req.Header.Get(logging.RequestIDHeader),
+		Operation: operation,
+		ServiceID: serviceID,
+		Actor:     string(user),
+	})
+}
+
 func handleDecodingError(err error) (int, string) {
 	log.Errorf("decoding error: %v", err)
 	return marshalEntity(responseEntity{
@@ -266,10 +734,32 @@ func handleDecodingError(err error) (int, string) {
 }
 
 func handleServiceError(err error) (int, string) {
+	return handleErrorWithStatus(err, http.StatusInternalServerError)
+}
+
+// handleRequestError handles errors of endpoints which tell invalid requests apart. Annotated errors keep status
+// of the error they originate from, e.g. exceeded quota is forbidden, and are described in more detail.
+func handleRequestError(err error) (int, string) {
+	status := http.StatusInternalServerError
+	switch errors.Tail(err) {
+	case types.InvalidInputError:
+		status = http.StatusBadRequest
+	case types.InstanceNotFoundError, types.ServiceNotFoundError, types.EntityNotFoundError:
+		status = http.StatusNotFound
+	case types.ServiceAlreadyExistsError, types.ExistingInstancesError, extension.InstanceNotProvisionedError:
+		status = http.StatusConflict
+	case extension.QuotaExceededError:
+		status = http.StatusForbidden
+	}
+	return handleErrorWithStatus(err, status)
+}
+
+// handleErrorWithStatus responds to known errors with their status, to other ones with given status
+func handleErrorWithStatus(err error, status int) (int, string) {
 	log.Errorf("handler service error: %v", err)
 
 	switch err {
-	case types.ServiceAlreadyExistsError:
+	case types.ServiceAlreadyExistsError, types.InstanceAlreadyExistsError:
 		return marshalEntity(responseEntity{http.StatusConflict, emptyConflict})
 	case types.InvalidInputError:
 		return marshalEntity(responseEntity{http.StatusBadRequest, emptyBadRequest})
@@ -278,22 +768,21 @@ func handleServiceError(err error) (int, string) {
 	case types.InternalServerError:
 		return marshalEntity(responseEntity{http.StatusInternalServerError, err.Error()})
 	default:
-
-		var description string
-		chain, isChain := err.(*errors.ErrorChain)
-		if isChain {
-			description = chain.Head().Error()
-		} else {
-			description = err.Error()
-		}
-
 		return marshalEntity(responseEntity{
-			http.StatusInternalServerError,
-			cf.BrokerError{Description: description},
+			status,
+			cf.BrokerError{Description: errors.Message(err)},
 		})
 	}
 }
 
+// exposedInstance returns copy of instance safe to respond with, values of sensitive provisioning parameters
+// are masked the way they are masked in logs
+func exposedInstance(instance *extension.ServiceInstanceExtension) *extension.ServiceInstanceExtension {
+	exposed := *instance
+	exposed.Parameters = logging.MaskValues(instance.Parameters)
+	return &exposed
+}
+
 func marshalEntity(entity responseEntity) (int, string) {
 	payload, err := json.Marshal(entity.value)
 	if err != nil {
@@ -18,20 +18,29 @@ package broker
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	log "github.com/cihub/seelog"
 	"github.com/cloudfoundry-community/types-cf"
 	"github.com/go-martini/martini"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	sfxerrors "github.com/signalfx/golib/errors"
 	"github.com/stretchr/testify/mock"
 	"github.com/trustedanalytics/application-broker/dao"
+	"github.com/trustedanalytics/application-broker/logging"
 	"github.com/trustedanalytics/application-broker/messagebus"
+	"github.com/trustedanalytics/application-broker/misc"
+	"github.com/trustedanalytics/application-broker/oauth"
 	"github.com/trustedanalytics/application-broker/service"
 	"github.com/trustedanalytics/application-broker/service/extension"
+	"github.com/trustedanalytics/application-broker/tracing"
 	"github.com/trustedanalytics/go-cf-lib/types"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
+	"time"
 )
 
 var _ = Describe("Handler", func() {
@@ -69,7 +78,7 @@ var _ = Describe("Handler", func() {
 				unmarshallableBody := bytes.NewReader([]byte("123"))
 				req, _ := http.NewRequest("", "", unmarshallableBody)
 
-				code, _ := sut.append(req, nil)
+				code, _ := sut.append(req, nil, "")
 
 				Expect(code).To(Equal(http.StatusBadRequest))
 			})
@@ -87,7 +96,7 @@ var _ = Describe("Handler", func() {
 				correctBody := strings.NewReader(jsonString)
 				req, _ := http.NewRequest("", "", correctBody)
 
-				code, resp := sut.append(req, nil)
+				code, resp := sut.append(req, nil, "")
 
 				decoded := extension.ServiceExtension{}
 				json.NewDecoder(strings.NewReader(resp)).Decode(&decoded)
@@ -95,6 +104,33 @@ var _ = Describe("Handler", func() {
 				Expect(decoded.Plans).NotTo(BeNil())
 				Expect(code).To(Equal(http.StatusCreated))
 			})
+
+			It("should give service registered without plans single free plan", func() {
+				req, _ := http.NewRequest("", "", strings.NewReader(`{"name":"dummy", "description":"dummier", "app":{"metadata" : {"guid":"fake"}}}`))
+
+				_, resp := sut.append(req, nil, "")
+
+				decoded := extension.ServiceExtension{}
+				json.NewDecoder(strings.NewReader(resp)).Decode(&decoded)
+				Expect(decoded.Plans).To(HaveLen(1))
+				Expect(decoded.Plans[0].Name).To(Equal("Simple"))
+				Expect(decoded.Plans[0].Free).To(BeTrue())
+			})
+
+			It("should record who appended the service", func() {
+				output := new(bytes.Buffer)
+				logger, err := log.LoggerFromWriterWithMinLevelAndFormat(output, log.InfoLvl, "%RedactedMsg%n")
+				Expect(err).NotTo(HaveOccurred())
+				log.ReplaceLogger(logger)
+				defer log.ReplaceLogger(log.Disabled)
+				req, _ := http.NewRequest("POST", "", strings.NewReader(`{"name":"dummy", "description":"dummier", "app":{"metadata" : {"guid":"fake"}}}`))
+
+				sut.append(req, nil, "operator")
+				logger.Flush()
+
+				Expect(output.String()).To(ContainSubstring("actor=operator"))
+				Expect(output.String()).To(ContainSubstring("Service dummy appended to catalog"))
+			})
 		})
 	})
 
@@ -103,8 +139,9 @@ var _ = Describe("Handler", func() {
 			It("404 not found should be returned", func() {
 				params := martini.Params{"service_id": "not-existing-id"}
 				mongoMock.On("Find", "not-existing-id").Return(nil, types.ServiceNotFoundError)
+				req, _ := http.NewRequest("DELETE", "", nil)
 
-				code, _ := sut.remove(nil, params)
+				code, _ := sut.remove(req, params, "")
 				Expect(code).To(Equal(http.StatusNotFound))
 			})
 		})
@@ -118,13 +155,88 @@ var _ = Describe("Handler", func() {
 				mongoMock.On("HasInstancesOf", "existing-id").Return(false, nil)
 				mongoMock.On("Remove", "existing-id").Return(nil)
 				mongoMock.On("Get").Return([]*extension.ServiceExtension{new(extension.ServiceExtension), new(extension.ServiceExtension)})
+				req, _ := http.NewRequest("DELETE", "", nil)
 
-				code, _ := sut.remove(nil, params)
+				code, _ := sut.remove(req, params, "")
 				Expect(code).To(Equal(http.StatusNoContent))
 			})
 		})
 	})
 
+	Describe("when managing plans", func() {
+		var svcExt *extension.ServiceExtension
+
+		BeforeEach(func() {
+			svcExt = &extension.ServiceExtension{
+				ReferenceApp: types.CfAppResource{Meta: types.CfMeta{GUID: "app-guid"}},
+				Service: cf.Service{ID: "existing-id", Name: "svc", Description: "desc",
+					Plans: []*cf.Plan{{ID: "simple-id", Name: "Simple", Description: "SimplePlan", Free: true}}},
+			}
+			mongoMock.On("Find", "existing-id").Return(svcExt, nil)
+			mongoMock.On("Update", mock.Anything).Return(nil)
+		})
+
+		Context("when adding plan without free flag", func() {
+			It("should create free plan", func() {
+				params := martini.Params{"service_id": "existing-id", "plan_id": "new-id"}
+				req, _ := http.NewRequest("POST", "", strings.NewReader(`{"name":"premium","description":"Premium"}`))
+
+				code, body := sut.addPlan(req, params, "")
+
+				Expect(code).To(Equal(http.StatusCreated))
+				plan := new(cf.Plan)
+				Expect(json.Unmarshal([]byte(body), plan)).To(Succeed())
+				Expect(plan.ID).To(Equal("new-id"))
+				Expect(plan.Free).To(BeTrue())
+				Expect(svcExt.Plans).To(HaveLen(2))
+			})
+		})
+
+		Context("when free flag is not boolean", func() {
+			It("should return bad request", func() {
+				params := martini.Params{"service_id": "existing-id", "plan_id": "new-id"}
+				req, _ := http.NewRequest("POST", "", strings.NewReader(`{"name":"premium","description":"d","free":"no"}`))
+
+				code, _ := sut.addPlan(req, params, "")
+				Expect(code).To(Equal(http.StatusBadRequest))
+			})
+		})
+
+		Context("when plan name is taken", func() {
+			It("should return conflict with description", func() {
+				params := martini.Params{"service_id": "existing-id", "plan_id": "new-id"}
+				req, _ := http.NewRequest("POST", "", strings.NewReader(`{"name":"Simple","description":"d"}`))
+
+				code, body := sut.addPlan(req, params, "")
+				Expect(code).To(Equal(http.StatusConflict))
+				Expect(body).To(ContainSubstring("Plan named Simple already exists"))
+			})
+		})
+
+		Context("when plan has instances", func() {
+			It("should refuse to delete it with conflict", func() {
+				svcExt.Plans = append(svcExt.Plans, &cf.Plan{ID: "paid-id", Name: "paid", Description: "d"})
+				mongoMock.On("HasInstancesOfPlan", "existing-id", "paid-id").Return(true, nil)
+				params := martini.Params{"service_id": "existing-id", "plan_id": "paid-id"}
+				req, _ := http.NewRequest("DELETE", "", nil)
+
+				code, _ := sut.removePlan(req, params, "")
+				Expect(code).To(Equal(http.StatusConflict))
+			})
+		})
+
+		Context("when updated plan does not exist", func() {
+			It("should return not found with description", func() {
+				params := martini.Params{"service_id": "existing-id", "plan_id": "unknown-id"}
+				req, _ := http.NewRequest("PUT", "", strings.NewReader(`{"name":"premium","description":"d"}`))
+
+				code, body := sut.updatePlan(req, params, "")
+				Expect(code).To(Equal(http.StatusNotFound))
+				Expect(body).To(ContainSubstring("Plan unknown-id not found in service svc"))
+			})
+		})
+	})
+
 	Describe("when retrieving services from catalog", func() {
 		var (
 			fakeCatalog []*extension.ServiceExtension
@@ -150,6 +262,78 @@ var _ = Describe("Handler", func() {
 		})
 	})
 
+	Describe("when exporting catalog", func() {
+		BeforeEach(func() {
+			svc := &extension.ServiceExtension{
+				Service:      cf.Service{ID: "fakeId", Name: "fake"},
+				ReferenceApp: types.CfAppResource{Meta: types.CfMeta{GUID: "appGuid"}},
+			}
+			mongoMock.On("Get").Return([]*extension.ServiceExtension{svc})
+			cfMock.On("GetAppReference", "appGuid").Return(&extension.AppReference{Org: "o", Space: "s", Name: "app"}, nil)
+		})
+
+		Context("in yaml format", func() {
+			It("should return yaml document with app reference", func() {
+				recorder := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", "/v2/catalog/export?format=yaml", nil)
+
+				code, raw := sut.exportCatalog(recorder, req, nil)
+
+				Expect(code).To(Equal(http.StatusOK))
+				Expect(recorder.Header().Get("Content-Type")).To(ContainSubstring(misc.FormatYAML))
+				decoded := extension.CatalogExport{}
+				Expect(misc.UnmarshalDocument([]byte(raw), misc.FormatYAML, &decoded)).To(Succeed())
+				Expect(decoded.Services[0].ReferenceApp.Name).To(Equal("app"))
+				Expect(decoded.Services[0].ID).To(Equal("fakeId"))
+			})
+		})
+	})
+
+	Describe("when importing catalog", func() {
+		Context("with unknown mode", func() {
+			It("should return bad request", func() {
+				req, _ := http.NewRequest("POST", "/v2/catalog/import?mode=overwrite", strings.NewReader("{}"))
+
+				code, _ := sut.importCatalog(req, nil, "")
+
+				Expect(code).To(Equal(http.StatusBadRequest))
+			})
+		})
+
+		Context("with yaml document in dry run", func() {
+			It("should return report of planned changes", func() {
+				mongoMock.On("Get").Return([]*extension.ServiceExtension{})
+				cfMock.On("FindAppGUID", extension.AppReference{Org: "o", Space: "s", Name: "app"}).Return("appGuid", nil)
+				cfMock.On("CheckServiceName", "fake").Return(nil)
+				doc := "version: 1\nservices:\n- name: fake\n  description: desc\n  app: {org: o, space: s, name: app}\n"
+				req, _ := http.NewRequest("POST", "/v2/catalog/import?dry_run=true", strings.NewReader(doc))
+				req.Header.Set("Content-Type", "application/x-yaml")
+
+				code, raw := sut.importCatalog(req, nil, "")
+
+				report := extension.ImportReport{}
+				json.NewDecoder(strings.NewReader(raw)).Decode(&report)
+				Expect(code).To(Equal(http.StatusOK))
+				Expect(report.DryRun).To(BeTrue())
+				Expect(report.Inserted).To(Equal([]string{"fake"}))
+			})
+		})
+
+	})
+
+	Describe("when synchronizing catalog", func() {
+		Context("without sync file configured", func() {
+			It("should return bad request", func() {
+				req, _ := http.NewRequest("POST", "/v2/catalog/sync", nil)
+
+				code, body := sut.syncCatalog(req, nil, "")
+
+				Expect(code).To(Equal(http.StatusBadRequest))
+				Expect(body).To(ContainSubstring("Catalog sync file is not configured"))
+			})
+		})
+	})
+
 	Describe("when provisioning new service instance", func() {
 		var (
 			resp        extension.ServiceCreationResponse
@@ -163,8 +347,12 @@ var _ = Describe("Handler", func() {
 				ReferenceApp: types.CfAppResource{Entity: types.CfApp{Name: "appToClone"}},
 			}
 			mongoMock.On("Find", inner.ID).Return(&testService)
+			mongoMock.On("FindInstance", "").Return(nil)
+			mongoMock.On("FindInstance", "instanceId").Return(nil)
 			mongoMock.On("AppendInstance", mock.Anything).Return()
-			cfMock.On("Provision", testService.ReferenceApp.Meta.GUID, mock.Anything, mock.Anything).Return(&extension.ServiceCreationResponse{})
+			mongoMock.On("UpdateInstance", mock.Anything).Return(nil)
+			mongoMock.On("RemoveInstance", mock.Anything).Return(nil)
+			cfMock.On("Provision", testService.ReferenceApp.Meta.GUID, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&extension.ServiceCreationResponse{})
 		})
 
 		Context("and requested service type exists", func() {
@@ -180,6 +368,117 @@ var _ = Describe("Handler", func() {
 				Expect(code).To(Equal(http.StatusCreated))
 			})
 		})
+
+		Context("with request id", func() {
+			It("should log provisioning with fields of the request", func() {
+				body := `{"service_id":"fakeId","plan_id":"planId"}`
+				req, _ := http.NewRequest("PUT", "", strings.NewReader(body))
+				req.Header.Set(logging.RequestIDHeader, "req-42")
+
+				code, _ := sut.provision(req, martini.Params{"instance_id": "instanceId"})
+
+				Expect(code).To(Equal(http.StatusCreated))
+				cfMock.AssertCalled(GinkgoT(), "Provision", mock.Anything, mock.Anything, mock.Anything,
+					mock.Anything, mock.Anything, mock.MatchedBy(func(logger *logging.Logger) bool {
+						return logger.Fields() == logging.Fields{RequestID: "req-42", Operation: "provision",
+							ServiceID: "fakeId", InstanceID: "instanceId"}
+					}))
+			})
+		})
+
+		Context("with trace context", func() {
+			var (
+				exporter *tracing.ExporterMock
+			)
+
+			BeforeEach(func() {
+				exporter = &tracing.ExporterMock{}
+				tracing.SetExporter(exporter)
+			})
+
+			AfterEach(func() {
+				tracing.SetExporter(nil)
+			})
+
+			It("should trace provisioning in the trace of the caller", func() {
+				body := `{"service_id":"fakeId","plan_id":"planId"}`
+				req, _ := http.NewRequest("PUT", "", strings.NewReader(body))
+				req.Header.Set(tracing.TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+				code, _ := sut.provision(req, martini.Params{"instance_id": "instanceId"})
+
+				Expect(code).To(Equal(http.StatusCreated))
+				span, found := exporter.Find("provision")
+				Expect(found).To(BeTrue())
+				Expect(span.TraceID).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+				Expect(span.ParentSpanID).To(Equal("00f067aa0ba902b7"))
+				Expect(span.Kind).To(Equal(tracing.ServerKind))
+				Expect(span.Attributes["instance_id"]).To(Equal("instanceId"))
+				cfMock.AssertCalled(GinkgoT(), "Provision", mock.Anything, mock.Anything, mock.Anything,
+					mock.Anything, mock.Anything, mock.MatchedBy(func(logger *logging.Logger) bool {
+						return logger.Span().SpanID() == span.SpanID &&
+							logger.Fields().TraceID == "4bf92f3577b34da6a3ce929d0e0e4736"
+					}))
+			})
+		})
+
+		Context("with context object and originating identity", func() {
+			It("should record them on the instance", func() {
+				body := `{"service_id":"fakeId","plan_id":"planId","parameters":{"key":"value"},` +
+					`"context":{"platform":"cloudfoundry","organization_guid":"orgGuid","space_guid":"spaceGuid"}}`
+				req, _ := http.NewRequest("PUT", "", strings.NewReader(body))
+				req.Header.Set(extension.OriginatingIdentityHeader,
+					"cloudfoundry "+base64.StdEncoding.EncodeToString([]byte(`{"user_id":"userGuid"}`)))
+
+				code, _ := sut.provision(req, martini.Params{"instance_id": "instanceId"})
+
+				Expect(code).To(Equal(http.StatusCreated))
+				mongoMock.AssertCalled(GinkgoT(), "AppendInstance", mock.MatchedBy(
+					func(instance extension.ServiceInstanceExtension) bool {
+						return instance.ID == "instanceId" && instance.PlanID == "planId" &&
+							instance.OrganizationGUID == "orgGuid" && instance.SpaceGUID == "spaceGuid" &&
+							instance.Parameters["key"] == "value" && instance.Context["platform"] == "cloudfoundry" &&
+							instance.CreatedBy == "userGuid" && !instance.CreatedAt.IsZero()
+					}))
+			})
+		})
+
+		Context("when quota is exceeded", func() {
+			It("should return forbidden with quota description", func() {
+				testService.Quota = &extension.Quota{Total: 1}
+				mongoMock.On("CountInstances", mock.Anything).Return(2, nil)
+				req, _ := http.NewRequest("PUT", "", strings.NewReader(`{"service_id":"fakeId"}`))
+
+				code, body := sut.provision(req, martini.Params{"instance_id": "instanceId"})
+
+				Expect(code).To(Equal(http.StatusForbidden))
+				Expect(body).To(ContainSubstring("Quota of 1 instance(s)"))
+			})
+		})
+
+		Context("when instance ID is taken", func() {
+			It("should return conflict", func() {
+				mongoMock.On("FindInstance", "takenId").Return(&extension.ServiceInstanceExtension{ID: "takenId"})
+				req, _ := http.NewRequest("PUT", "", strings.NewReader(`{"service_id":"fakeId"}`))
+
+				code, _ := sut.provision(req, martini.Params{"instance_id": "takenId"})
+
+				Expect(code).To(Equal(http.StatusConflict))
+				mongoMock.AssertNotCalled(GinkgoT(), "AppendInstance", mock.Anything)
+			})
+		})
+
+		Context("with malformed originating identity", func() {
+			It("should return bad request", func() {
+				req, _ := http.NewRequest("PUT", "", strings.NewReader(`{"service_id":"fakeId"}`))
+				req.Header.Set(extension.OriginatingIdentityHeader, "cloudfoundry not-base64!")
+
+				code, _ := sut.provision(req, martini.Params{"instance_id": "instanceId"})
+
+				Expect(code).To(Equal(http.StatusBadRequest))
+				mongoMock.AssertNotCalled(GinkgoT(), "AppendInstance", mock.Anything)
+			})
+		})
 	})
 
 	Describe("when binding service instance", func() {
@@ -231,4 +530,156 @@ var _ = Describe("Handler", func() {
 			})
 		})
 	})
+
+	Describe("when previewing service instance", func() {
+		BeforeEach(func() {
+			testService := extension.ServiceExtension{Service: cf.Service{ID: "fakeId", Name: "stack"}}
+			testService.ReferenceApp.Meta.GUID = "refGuid"
+			mongoMock.On("Find", "fakeId").Return(&testService)
+			cfMock.On("Preview", "refGuid", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&extension.ProvisioningPreview{}, nil)
+		})
+
+		It("should pass service from path and naming of the instance to the cloud", func() {
+			req, _ := http.NewRequest("POST", "/v2/catalog/fakeId/preview?instance_id=abcd-1234",
+				strings.NewReader(`{"plan_id":"planId","parameters":{"name":"my stack"},"context":{"space_name":"dev"}}`))
+
+			code, _ := sut.preview(req, martini.Params{"service_id": "fakeId"})
+
+			Expect(code).To(Equal(http.StatusOK))
+			cfMock.AssertCalled(GinkgoT(), "Preview", "refGuid", mock.Anything, mock.MatchedBy(
+				func(naming *extension.Naming) bool {
+					return naming.Template == extension.DefaultNamingTemplate && naming.Instance == "my-stack" &&
+						naming.ID == "abcd" && naming.Space == "dev" && !naming.KeepMainName
+				}), mock.Anything, mock.MatchedBy(
+				func(r *cf.ServiceCreationRequest) bool {
+					return r.ServiceID == "fakeId" && r.InstanceID == "abcd-1234"
+				}), mock.Anything)
+			mongoMock.AssertNotCalled(GinkgoT(), "AppendInstance", mock.Anything)
+			cfMock.AssertNotCalled(GinkgoT(), "Provision", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+
+		It("should return bad request for invalid TTL", func() {
+			req, _ := http.NewRequest("POST", "/v2/catalog/fakeId/preview",
+				strings.NewReader(`{"plan_id":"planId","parameters":{"ttl":"soon"}}`))
+
+			code, body := sut.preview(req, martini.Params{"service_id": "fakeId"})
+
+			Expect(code).To(Equal(http.StatusBadRequest))
+			Expect(body).To(ContainSubstring("Invalid TTL soon"))
+		})
+	})
+
+	Describe("when retrieving service instance", func() {
+		It("should mask sensitive provisioning parameters", func() {
+			instance := &extension.ServiceInstanceExtension{ID: "instanceId",
+				Parameters: map[string]string{"name": "my stack", "admin_password": "p4ss"}}
+			mongoMock.On("FindInstance", "instanceId").Return(instance)
+			req, _ := http.NewRequest("GET", "/v2/instances/instanceId", nil)
+
+			code, body := sut.instance(req, martini.Params{"instance_id": "instanceId"})
+
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(body).To(ContainSubstring(`"admin_password":"` + logging.Mask + `"`))
+			Expect(body).To(ContainSubstring(`"name":"my stack"`))
+			Expect(body).NotTo(ContainSubstring("p4ss"))
+			Expect(instance.Parameters["admin_password"]).To(Equal("p4ss"))
+		})
+	})
+
+	Describe("when extending service instance", func() {
+		BeforeEach(func() {
+			testService := extension.ServiceExtension{Service: cf.Service{ID: "fakeId"},
+				PlanTTLs: map[string]string{"trialId": "72h"}}
+			expiresAt := time.Now().Add(time.Hour)
+			instance := &extension.ServiceInstanceExtension{ID: "instanceId", ServiceID: "fakeId", PlanID: "trialId",
+				CreatedBy: "userGuid", ExpiresAt: &expiresAt}
+			mongoMock.On("Find", "fakeId").Return(&testService)
+			mongoMock.On("FindInstance", "instanceId").Return(instance)
+			mongoMock.On("UpdateInstance", mock.Anything).Return(nil)
+		})
+
+		It("should let user who created the instance extend it", func() {
+			req, _ := http.NewRequest("POST", "/v2/instances/instanceId/extend", strings.NewReader("{}"))
+
+			code, _ := sut.extendInstance(req, martini.Params{"instance_id": "instanceId"},
+				&oauth.Claims{UserID: "userGuid", UserName: "owner"})
+
+			Expect(code).To(Equal(http.StatusOK))
+			mongoMock.AssertCalled(GinkgoT(), "UpdateInstance", mock.Anything)
+		})
+
+		It("should forbid other users to extend it", func() {
+			req, _ := http.NewRequest("POST", "/v2/instances/instanceId/extend", strings.NewReader("{}"))
+
+			code, _ := sut.extendInstance(req, martini.Params{"instance_id": "instanceId"},
+				&oauth.Claims{UserID: "otherGuid", UserName: "other"})
+
+			Expect(code).To(Equal(http.StatusForbidden))
+			mongoMock.AssertNotCalled(GinkgoT(), "UpdateInstance", mock.Anything)
+		})
+
+		It("should let platform extend it without checking owner", func() {
+			req, _ := http.NewRequest("POST", "/v2/instances/instanceId/extend", strings.NewReader("{}"))
+
+			code, _ := sut.extendInstance(req, martini.Params{"instance_id": "instanceId"}, nil)
+
+			Expect(code).To(Equal(http.StatusOK))
+		})
+
+		It("should return bad request for instance which does not expire", func() {
+			mongoMock.On("FindInstance", "eternalId").Return(&extension.ServiceInstanceExtension{ID: "eternalId"})
+			req, _ := http.NewRequest("POST", "/v2/instances/eternalId/extend", strings.NewReader("{}"))
+
+			code, body := sut.extendInstance(req, martini.Params{"instance_id": "eternalId"}, nil)
+
+			Expect(code).To(Equal(http.StatusBadRequest))
+			Expect(body).To(ContainSubstring("Instance eternalId does not expire"))
+		})
+	})
+
+	Describe("when hibernating service instance", func() {
+		BeforeEach(func() {
+			mongoMock.On("FindInstance", "instanceId").Return(&extension.ServiceInstanceExtension{ID: "instanceId",
+				State: extension.InstanceProvisioning})
+		})
+
+		It("should refuse to stop instance being provisioned with conflict", func() {
+			req, _ := http.NewRequest("POST", "/v2/instances/instanceId/stop", nil)
+
+			code, _ := sut.stopInstance(req, martini.Params{"instance_id": "instanceId"})
+
+			Expect(code).To(Equal(http.StatusConflict))
+		})
+
+		It("should refuse to start instance being provisioned with conflict", func() {
+			req, _ := http.NewRequest("POST", "/v2/instances/instanceId/start", nil)
+
+			code, _ := sut.startInstance(req, martini.Params{"instance_id": "instanceId"})
+
+			Expect(code).To(Equal(http.StatusConflict))
+		})
+
+		It("should return bad request for invalid schedule", func() {
+			req, _ := http.NewRequest("PUT", "/v2/instances/instanceId/schedule",
+				strings.NewReader(`{"start":"25:00","stop":"18:00"}`))
+
+			code, _ := sut.setInstanceSchedule(req, martini.Params{"instance_id": "instanceId"})
+
+			Expect(code).To(Equal(http.StatusBadRequest))
+			mongoMock.AssertNotCalled(GinkgoT(), "UpdateInstance", mock.Anything)
+		})
+	})
+
+	Describe("when deprovisioning service instance", func() {
+		It("should keep internal server error for errors annotating missing components", func() {
+			mongoMock.On("FindInstance", "instanceId").Return(&extension.ServiceInstanceExtension{ID: "instanceId"})
+			cfMock.On("Deprovision", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+				Return(sfxerrors.Annotate(types.EntityNotFoundError, "Route is missing"))
+			req, _ := http.NewRequest("DELETE", "/v2/service_instances/instanceId", nil)
+
+			code, _ := sut.deprovision(req, martini.Params{"instance_id": "instanceId"})
+
+			Expect(code).To(Equal(http.StatusInternalServerError))
+		})
+	})
 })
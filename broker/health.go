@@ -0,0 +1,57 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package broker
+
+import (
+	"encoding/json"
+	"github.com/trustedanalytics/application-broker/env"
+	"github.com/trustedanalytics/application-broker/health"
+	"net/http"
+	"time"
+)
+
+const (
+	livenessURLPattern  = "/healthz"
+	readinessURLPattern = "/readyz"
+)
+
+// newLivenessHandler tells that broker process is able to serve requests, without looking at dependencies
+func newLivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeHealth(w, http.StatusOK, health.Report{Status: health.UpStatus, Dependencies: []health.DependencyStatus{}})
+	})
+}
+
+// newReadinessHandler checks dependencies and responds with 503 when any required one is down
+func newReadinessHandler(checks []health.Check) http.Handler {
+	timeout := time.Duration(env.GetEnvVarAsInt("HEALTH_CHECK_TIMEOUT", 5)) * time.Second
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := health.Run(checks, timeout)
+		status := http.StatusOK
+		if report.Status != health.UpStatus {
+			status = http.StatusServiceUnavailable
+		}
+		writeHealth(w, status, report)
+	})
+}
+
+func writeHealth(w http.ResponseWriter, status int, report health.Report) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,79 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/trustedanalytics/application-broker/health"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("Health", func() {
+
+	var (
+		checks []health.Check
+	)
+
+	get := func(path string) (*httptest.ResponseRecorder, health.Report) {
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		newRouter(nil, checks).ServeHTTP(recorder, req)
+		report := health.Report{}
+		Expect(json.NewDecoder(recorder.Body).Decode(&report)).To(Succeed())
+		return recorder, report
+	}
+
+	BeforeEach(func() {
+		checks = []health.Check{
+			{Name: "mongodb", Required: true, Probe: func() error { return nil }},
+			{Name: "nats", Probe: func() error { return errors.New("Not connected to NATS") }},
+		}
+	})
+
+	It("should answer liveness probe without broker credentials", func() {
+		checks[0].Probe = func() error { return errors.New("no reachable servers") }
+
+		recorder, report := get(livenessURLPattern)
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(report.Status).To(Equal(health.UpStatus))
+	})
+
+	It("should be ready when required dependencies are up", func() {
+		recorder, report := get(readinessURLPattern)
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(report.Status).To(Equal(health.UpStatus))
+		Expect(report.Dependencies).To(HaveLen(2))
+		Expect(report.Dependencies[1].Status).To(Equal(health.DownStatus))
+		Expect(report.Dependencies[1].Error).To(Equal("Not connected to NATS"))
+	})
+
+	It("should not be ready when required dependency is down", func() {
+		checks[0].Probe = func() error { return errors.New("no reachable servers") }
+
+		recorder, report := get(readinessURLPattern)
+
+		Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(report.Status).To(Equal(health.DownStatus))
+		Expect(report.Dependencies[0].Error).To(Equal("no reachable servers"))
+	})
+})
@@ -0,0 +1,127 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package broker
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/auth"
+	"github.com/trustedanalytics/application-broker/metrics"
+	"github.com/trustedanalytics/application-broker/service/extension"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const metricsURLPattern = "/metrics"
+
+// unknownLabel replaces service and plan IDs not found in the catalog, so callers cannot add label values at will
+const unknownLabel = "unknown"
+
+// operationLabels tells service and plan an operation is performed on
+type operationLabels func(req *http.Request, params martini.Params) (serviceID, planID string)
+
+// instrumented records metrics of Service Broker API operation handled by fn
+func instrumented(operation string, labels operationLabels, fn responseHandler) responseHandler {
+	return func(req *http.Request, params martini.Params) (int, string) {
+		metrics.OperationsInFlight.Inc(operation)
+		defer metrics.OperationsInFlight.Dec(operation)
+
+		serviceID, planID := labels(req, params)
+		start := time.Now()
+		status, body := fn(req, params)
+		outcome := metrics.Outcome(status)
+		metrics.Operations.Inc(operation, serviceID, planID, outcome)
+		metrics.OperationDuration.ObserveSince(start, operation, serviceID, planID, outcome)
+		return status, body
+	}
+}
+
+// noLabels is used for operations not related to single service, like catalog
+func noLabels(req *http.Request, params martini.Params) (string, string) {
+	return "", ""
+}
+
+// bodyLabels reads service and plan from request body, leaving the body for the handler
+func bodyLabels(req *http.Request, params martini.Params) (string, string) {
+	if req.Body == nil {
+		return "", ""
+	}
+	raw, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(raw))
+	if err != nil {
+		return "", ""
+	}
+	ids := struct {
+		ServiceID string `json:"service_id"`
+		PlanID    string `json:"plan_id"`
+	}{}
+	json.Unmarshal(raw, &ids)
+	return ids.ServiceID, ids.PlanID
+}
+
+// queryLabels reads service and plan from query parameters, as sent on deprovisioning and unbinding
+func queryLabels(req *http.Request, params martini.Params) (string, string) {
+	return req.URL.Query().Get("service_id"), req.URL.Query().Get("plan_id")
+}
+
+// catalogLabels passes only service and plan IDs found in the catalog, replacing others with unknownLabel
+func catalogLabels(catalog func() (*extension.CatalogExtension, error), labels operationLabels) operationLabels {
+	return func(req *http.Request, params martini.Params) (string, string) {
+		serviceID, planID := labels(req, params)
+		services, err := catalog()
+		if err != nil {
+			return unknownLabel, unknownLabel
+		}
+		for _, svc := range services.Services {
+			if svc.ID != serviceID {
+				continue
+			}
+			for _, plan := range svc.Plans {
+				if plan.ID == planID {
+					return serviceID, planID
+				}
+			}
+			return serviceID, unknownLabel
+		}
+		return unknownLabel, unknownLabel
+	}
+}
+
+// labels limits labels of operation handled by h to the catalog of its provider
+func (h *handler) labels(labels operationLabels) operationLabels {
+	return catalogLabels(func() (*extension.CatalogExtension, error) {
+		return h.provider.GetCatalog()
+	}, labels)
+}
+
+// newMetricsHandler serves metrics without broker credentials, unless separate metrics credentials are set
+func newMetricsHandler(username, password string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if len(username) > 0 {
+			givenUsername, givenPassword, _ := req.BasicAuth()
+			if !auth.SecureCompare(givenUsername, username) || !auth.SecureCompare(givenPassword, password) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="Metrics"`)
+				http.Error(w, "Not Authorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		metrics.Default.ServeHTTP(w, req)
+	})
+}
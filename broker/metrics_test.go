@@ -0,0 +1,142 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package broker
+
+import (
+	"bytes"
+	"github.com/cloudfoundry-community/types-cf"
+	"github.com/go-martini/martini"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/trustedanalytics/application-broker/metrics"
+	"github.com/trustedanalytics/application-broker/service/extension"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+)
+
+var _ = Describe("Metrics", func() {
+
+	exposition := func() string {
+		output := new(bytes.Buffer)
+		metrics.Default.WriteTo(output)
+		return output.String()
+	}
+
+	Describe("instrumented operation", func() {
+		It("should count operation by service, plan and outcome, leaving body for the handler", func() {
+			var body string
+			sut := instrumented("test-provision", bodyLabels, func(req *http.Request, params martini.Params) (int, string) {
+				raw, _ := ioutil.ReadAll(req.Body)
+				body = string(raw)
+				return http.StatusCreated, "{}"
+			})
+			req, _ := http.NewRequest("PUT", "/v2/service_instances/1",
+				strings.NewReader(`{"service_id":"svc","plan_id":"plan"}`))
+
+			code, _ := sut(req, martini.Params{})
+
+			Expect(code).To(Equal(http.StatusCreated))
+			Expect(body).To(Equal(`{"service_id":"svc","plan_id":"plan"}`))
+			Expect(exposition()).To(ContainSubstring(
+				`appbroker_operations_total{operation="test-provision",service="svc",plan="plan",outcome="success"} 1`))
+			Expect(exposition()).To(ContainSubstring(
+				`appbroker_operation_duration_seconds_count{operation="test-provision",service="svc",plan="plan",outcome="success"} 1`))
+			Expect(exposition()).To(ContainSubstring(`appbroker_operations_in_flight{operation="test-provision"} 0`))
+		})
+
+		It("should label operation from query parameters", func() {
+			sut := instrumented("test-deprovision", queryLabels, func(req *http.Request, params martini.Params) (int, string) {
+				return http.StatusInternalServerError, ""
+			})
+			req, _ := http.NewRequest("DELETE", "/v2/service_instances/1?service_id=svc&plan_id=plan", nil)
+
+			sut(req, martini.Params{})
+
+			Expect(exposition()).To(ContainSubstring(
+				`appbroker_operations_total{operation="test-deprovision",service="svc",plan="plan",outcome="server_error"} 1`))
+		})
+	})
+
+	Describe("catalog labels", func() {
+		catalog := func() (*extension.CatalogExtension, error) {
+			svc := &extension.ServiceExtension{}
+			svc.ID = "svc"
+			svc.Plans = []*cf.Plan{{ID: "plan"}}
+			return &extension.CatalogExtension{Services: []*extension.ServiceExtension{svc}}, nil
+		}
+
+		label := func(query string) (string, string) {
+			req, _ := http.NewRequest("DELETE", "/v2/service_instances/1?"+query, nil)
+			return catalogLabels(catalog, queryLabels)(req, martini.Params{})
+		}
+
+		It("should pass service and plan found in the catalog", func() {
+			serviceID, planID := label("service_id=svc&plan_id=plan")
+			Expect(serviceID).To(Equal("svc"))
+			Expect(planID).To(Equal("plan"))
+		})
+
+		It("should replace plan not found in the service", func() {
+			serviceID, planID := label("service_id=svc&plan_id=other")
+			Expect(serviceID).To(Equal("svc"))
+			Expect(planID).To(Equal("unknown"))
+		})
+
+		It("should replace service not found in the catalog", func() {
+			serviceID, planID := label("service_id=random&plan_id=plan")
+			Expect(serviceID).To(Equal("unknown"))
+			Expect(planID).To(Equal("unknown"))
+		})
+	})
+
+	Describe("metrics endpoint", func() {
+		AfterEach(func() {
+			os.Unsetenv("METRICS_USER")
+			os.Unsetenv("METRICS_PASS")
+		})
+
+		It("should be served without broker credentials", func() {
+			recorder := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", metricsURLPattern, nil)
+
+			newRouter(nil, nil).ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(ContainSubstring("# TYPE appbroker_operations_total counter"))
+		})
+
+		It("should require metrics credentials when they are set", func() {
+			os.Setenv("METRICS_USER", "prometheus")
+			os.Setenv("METRICS_PASS", "scrape")
+			sut := newRouter(nil, nil)
+
+			unauthorized := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", metricsURLPattern, nil)
+			sut.ServeHTTP(unauthorized, req)
+
+			authorized := httptest.NewRecorder()
+			req.SetBasicAuth("prometheus", "scrape")
+			sut.ServeHTTP(authorized, req)
+
+			Expect(unauthorized.Code).To(Equal(http.StatusUnauthorized))
+			Expect(authorized.Code).To(Equal(http.StatusOK))
+		})
+	})
+})
@@ -46,6 +46,12 @@ type emptyBodyBadRequest struct{}
 
 var emptyBadRequest = emptyBodyBadRequest{}
 
+// Forbidden
+// swagger:response emptyBodyForbidden
+type emptyBodyForbidden struct{}
+
+var emptyForbidden = emptyBodyForbidden{}
+
 // Not Found
 // swagger:response emptyBodyNotFound
 type emptyBodyNotFound struct{}
@@ -87,6 +93,48 @@ type CatalogExtensionResponse struct {
 	Body extension.CatalogExtension
 }
 
+// CatalogExportResponse
+// swagger:response catalogExportResponse
+type CatalogExportResponse struct {
+	// in: body
+	Body extension.CatalogExport
+}
+
+// ImportReportResponse
+// swagger:response importReportResponse
+type ImportReportResponse struct {
+	// in: body
+	Body extension.ImportReport
+}
+
+// InstancesResponse
+// swagger:response instancesResponse
+type InstancesResponse struct {
+	// in: body
+	Body []extension.ServiceInstanceExtension
+}
+
+// InstanceResponse
+// swagger:response instanceResponse
+type InstanceResponse struct {
+	// in: body
+	Body extension.ServiceInstanceExtension
+}
+
+// ProvisioningPreviewResponse
+// swagger:response provisioningPreviewResponse
+type ProvisioningPreviewResponse struct {
+	// in: body
+	Body extension.ProvisioningPreview
+}
+
+// PlanResponse
+// swagger:response planResponse
+type PlanResponse struct {
+	// in: body
+	Body cf.Plan
+}
+
 // ServiceBindingResponse
 // swagger:response serviceBindingResponse
 type ServiceBindingResponse struct {
@@ -94,7 +142,7 @@ type ServiceBindingResponse struct {
 	Body types.ServiceBindingResponse
 }
 
-// swagger:parameters updateService deleteService
+// swagger:parameters updateService deleteService addPlan updatePlan deletePlan previewServiceInstance
 type ServiceIdParam struct {
 	// Service GUID
 	// in: path
@@ -102,7 +150,7 @@ type ServiceIdParam struct {
 	ServiceId string `json:"service_id"`
 }
 
-// swagger:parameters provisionServiceInstance deprovisionServiceInstance bindService unbindService
+// swagger:parameters provisionServiceInstance deprovisionServiceInstance bindService unbindService getInstance extendInstance stopInstance startInstance setInstanceSchedule deleteInstanceSchedule
 type InstanceIdParam struct {
 	// Service instance GUID
 	// in: path
@@ -110,6 +158,55 @@ type InstanceIdParam struct {
 	InstanceId string `json:"instance_id"`
 }
 
+// swagger:parameters addPlan updatePlan deletePlan
+type PlanIdParam struct {
+	// Plan GUID
+	// in: path
+	// required: true
+	PlanId string `json:"plan_id"`
+}
+
+// swagger:parameters addPlan updatePlan
+type PlanParams struct {
+	// Plan description, free flag defaults to true
+	// in: body
+	Body cf.Plan
+}
+
+// swagger:parameters provisionServiceInstance
+type ProvisionServiceInstanceParams struct {
+	// Platform name and base64 encoded JSON identifying user that requested the instance
+	// in: header
+	OriginatingIdentity string `json:"X-Broker-API-Originating-Identity"`
+	// Provisioning request with optional context object
+	// in: body
+	Body extension.ServiceCreationRequest
+}
+
+// swagger:parameters previewServiceInstance
+type PreviewServiceInstanceParams struct {
+	// Service instance GUID the name suffix is derived from
+	// in: query
+	InstanceId string `json:"instance_id"`
+	// Provisioning request with optional context object
+	// in: body
+	Body extension.ServiceCreationRequest
+}
+
+// swagger:parameters extendInstance
+type ExtendInstanceParams struct {
+	// TTL in Go duration notation, e.g. 24h
+	// in: body
+	Body extension.ExtendRequest
+}
+
+// swagger:parameters setInstanceSchedule
+type ScheduleParams struct {
+	// Working hours as HH:MM start and stop, optional days (mon, tue, ...) and timezone (UTC by default)
+	// in: body
+	Body extension.Schedule
+}
+
 // swagger:parameters bindService unbindService
 type BindingIdParam struct {
 	// Service binding GUID
@@ -117,3 +214,30 @@ type BindingIdParam struct {
 	// required: true
 	BindingId string `json:"binding_id"`
 }
+
+// swagger:parameters exportCatalog importCatalog
+type DocumentFormatParam struct {
+	// Document format: json (default) or yaml
+	// in: query
+	Format string `json:"format"`
+}
+
+// swagger:parameters syncCatalog
+type SyncCatalogParams struct {
+	// When true, nothing is changed but report of planned changes is returned
+	// in: query
+	DryRun bool `json:"dry_run"`
+}
+
+// swagger:parameters importCatalog
+type ImportCatalogParams struct {
+	// Import mode: merge (default) or replace
+	// in: query
+	Mode string `json:"mode"`
+	// When true, nothing is changed but report of planned changes is returned
+	// in: query
+	DryRun bool `json:"dry_run"`
+	// Catalog document created by export
+	// in: body
+	Body extension.CatalogExport
+}
@@ -18,13 +18,15 @@ package broker
 
 import (
 	"fmt"
-	log "github.com/cihub/seelog"
 	"github.com/go-martini/martini"
-	"github.com/martini-contrib/auth"
 	"github.com/martini-contrib/sessions"
 	"github.com/trustedanalytics/application-broker/env"
+	"github.com/trustedanalytics/application-broker/health"
+	"github.com/trustedanalytics/application-broker/logging"
 	"net/http"
 	"net/http/httputil"
+	"sync"
+	"time"
 )
 
 const (
@@ -34,43 +36,116 @@ const (
 var (
 	catalogURLPattern          = fmt.Sprintf("/%v/catalog", apiVersion)
 	catalogServiceIdURLPattern = fmt.Sprintf("/%v/catalog/:service_id", apiVersion)
+	catalogPlanURLPattern      = fmt.Sprintf("/%v/catalog/:service_id/plans/:plan_id", apiVersion)
+	catalogPreviewURLPattern   = fmt.Sprintf("/%v/catalog/:service_id/preview", apiVersion)
+	catalogExportURLPattern    = fmt.Sprintf("/%v/catalog/export", apiVersion)
+	catalogImportURLPattern    = fmt.Sprintf("/%v/catalog/import", apiVersion)
+	catalogSyncURLPattern      = fmt.Sprintf("/%v/catalog/sync", apiVersion)
 	provisioningURLPattern     = fmt.Sprintf("/%v/service_instances/:instance_id", apiVersion)
 	bindingURLPattern          = fmt.Sprintf("/%v/service_instances/:instance_id/service_bindings/:binding_id", apiVersion)
+	instancesURLPattern        = fmt.Sprintf("/%v/instances", apiVersion)
+	instanceURLPattern         = fmt.Sprintf("/%v/instances/:instance_id", apiVersion)
+	instanceExtendURLPattern   = fmt.Sprintf("/%v/instances/:instance_id/extend", apiVersion)
+	instanceStopURLPattern     = fmt.Sprintf("/%v/instances/:instance_id/stop", apiVersion)
+	instanceStartURLPattern    = fmt.Sprintf("/%v/instances/:instance_id/start", apiVersion)
+	instanceScheduleURLPattern = fmt.Sprintf("/%v/instances/:instance_id/schedule", apiVersion)
 )
 
 type router struct {
 	m *martini.ClassicMartini
+	// Handlers of metrics and health checks, served without broker credentials
+	probes map[string]http.Handler
+	// Requests in progress, awaited on shutdown
+	active sync.WaitGroup
 }
 
-func newRouter(h *handler) *router {
+func newRouter(h *handler, checks []health.Check) *router {
 
 	m := martini.Classic()
 
-	m.Use(auth.Basic(env.GetEnvVarAsString("AUTH_USER", ""), env.GetEnvVarAsString("AUTH_PASS", "")))
+	m.Use(authenticate(credentialsFromEnv(), tokenAuthenticatorFromEnv()))
+	platform := authorize(platformRole)
+	catalogAdmin := authorize(catalogAdminRole)
+	anyRole := authorize(platformRole, catalogAdminRole)
+	instanceOwner := authorize(platformRole, instanceOwnerRole)
 
 	m.Use(sessions.Sessions("app_launcher", sessions.NewCookieStore([]byte("appsecretlauncher"))))
-	m.Post(catalogURLPattern, responseHandler(h.append))
-	m.Delete(catalogServiceIdURLPattern, responseHandler(h.remove))
-	m.Put(catalogServiceIdURLPattern, responseHandler(h.update))
-	m.Get(catalogURLPattern, responseHandler(h.catalog))
-	m.Put(provisioningURLPattern, responseHandler(h.provision))
-	m.Delete(provisioningURLPattern, responseHandler(h.deprovision))
-	m.Put(bindingURLPattern, responseHandler(h.bind))
-	m.Delete(bindingURLPattern, responseHandler(h.unbind))
-	return &router{m}
+	m.Get(catalogExportURLPattern, catalogAdmin, h.exportCatalog)
+	m.Post(catalogImportURLPattern, catalogAdmin, h.importCatalog)
+	m.Post(catalogSyncURLPattern, catalogAdmin, h.syncCatalog)
+	m.Post(catalogURLPattern, catalogAdmin, h.append)
+	m.Delete(catalogServiceIdURLPattern, catalogAdmin, h.remove)
+	m.Put(catalogServiceIdURLPattern, catalogAdmin, h.update)
+	m.Get(catalogURLPattern, anyRole, instrumented("catalog", noLabels, h.catalog))
+	m.Post(catalogPlanURLPattern, catalogAdmin, h.addPlan)
+	m.Put(catalogPlanURLPattern, catalogAdmin, h.updatePlan)
+	m.Delete(catalogPlanURLPattern, catalogAdmin, h.removePlan)
+	m.Post(catalogPreviewURLPattern, anyRole, responseHandler(h.preview))
+	m.Put(provisioningURLPattern, platform, instrumented("provision", h.labels(bodyLabels), h.provision))
+	m.Delete(provisioningURLPattern, platform, instrumented("deprovision", h.labels(queryLabels), h.deprovision))
+	m.Get(instancesURLPattern, platform, responseHandler(h.instances))
+	m.Get(instanceURLPattern, platform, responseHandler(h.instance))
+	m.Post(instanceExtendURLPattern, instanceOwner, h.extendInstance)
+	m.Post(instanceStopURLPattern, platform, responseHandler(h.stopInstance))
+	m.Post(instanceStartURLPattern, platform, responseHandler(h.startInstance))
+	m.Put(instanceScheduleURLPattern, platform, responseHandler(h.setInstanceSchedule))
+	m.Delete(instanceScheduleURLPattern, platform, responseHandler(h.deleteInstanceSchedule))
+	m.Put(bindingURLPattern, platform, instrumented("bind", h.labels(bodyLabels), h.bind))
+	m.Delete(bindingURLPattern, platform, instrumented("unbind", h.labels(queryLabels), h.unbind))
+
+	probes := map[string]http.Handler{
+		metricsURLPattern:   newMetricsHandler(env.GetEnvVarAsString("METRICS_USER", ""), env.GetEnvVarAsString("METRICS_PASS", "")),
+		livenessURLPattern:  newLivenessHandler(),
+		readinessURLPattern: newReadinessHandler(checks),
+	}
+	return &router{m: m, probes: probes}
 }
 
-// ServeHTTP logs all requests and dispatches to the appropriate handler
+// ServeHTTP logs all requests and dispatches to the appropriate handler.
+// Requests without a valid X-Request-ID header get a generated one, echoed back in the response.
+// Metrics and health checks are served before broker authentication.
 func (r *router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if probe, ok := r.probes[req.URL.Path]; ok && req.Method == "GET" {
+		probe.ServeHTTP(w, req)
+		return
+	}
+
+	r.active.Add(1)
+	defer r.active.Done()
+
+	requestID := logging.ValidRequestID(req.Header.Get(logging.RequestIDHeader))
+	req.Header.Set(logging.RequestIDHeader, requestID)
+	w.Header().Set(logging.RequestIDHeader, requestID)
 
+	logger := logging.NewLogger(logging.Fields{RequestID: requestID})
 	if dump, err := httputil.DumpRequest(req, true); err != nil {
-		log.Tracef("Cannot log incoming request: %v", err)
+		logger.Tracef("Cannot log incoming request: %v", err)
 	} else {
-		log.Tracef(string(dump))
+		logger.Tracef("%s", dump)
 	}
 	r.m.ServeHTTP(w, req)
 }
 
+// wait blocks until requests in progress complete, returns false when they do not within timeout
+func (r *router) wait(timeout time.Duration) bool {
+	return waitFor(&r.active, timeout)
+}
+
+// waitFor blocks until wait group is done, returns false when it is not within timeout
+func waitFor(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
 type responseEntity struct {
 	status int
 	value  interface{}
@@ -17,10 +17,16 @@
 package broker
 
 import (
+	"bytes"
+	log "github.com/cihub/seelog"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
+
+	"github.com/trustedanalytics/application-broker/logging"
 )
 
 var _ = Describe("Router", func() {
@@ -34,10 +40,75 @@ var _ = Describe("Router", func() {
 			recorder := httptest.NewRecorder()
 			r, _ := http.NewRequest("GET", "/v2/catalog", nil)
 
-			sut = newRouter(nil)
+			sut = newRouter(nil, nil)
 			sut.ServeHTTP(recorder, r)
 
 			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
 		})
+
+		It("should not let platform credentials modify catalog", func() {
+			os.Setenv("AUTH_USER", "cc")
+			os.Setenv("AUTH_PASS", "secret")
+			os.Setenv("CATALOG_ADMIN_CREDENTIALS", "ops:admin")
+			defer os.Unsetenv("AUTH_USER")
+			defer os.Unsetenv("AUTH_PASS")
+			defer os.Unsetenv("CATALOG_ADMIN_CREDENTIALS")
+			recorder := httptest.NewRecorder()
+			r, _ := http.NewRequest("DELETE", "/v2/catalog/service-id", nil)
+			r.SetBasicAuth("cc", "secret")
+
+			sut = newRouter(nil, nil)
+			sut.ServeHTTP(recorder, r)
+
+			Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		})
+	})
+
+	Describe("request ids", func() {
+		It("should echo request id sent by client", func() {
+			recorder := httptest.NewRecorder()
+			r, _ := http.NewRequest("GET", "/v2/catalog", nil)
+			r.Header.Set(logging.RequestIDHeader, "client-id-1")
+
+			sut = newRouter(nil, nil)
+			sut.ServeHTTP(recorder, r)
+
+			Expect(recorder.Header().Get(logging.RequestIDHeader)).To(Equal("client-id-1"))
+		})
+
+		It("should replace missing or malformed request id with generated one", func() {
+			recorder := httptest.NewRecorder()
+			r, _ := http.NewRequest("GET", "/v2/catalog", nil)
+			r.Header.Set(logging.RequestIDHeader, "bad id\n")
+
+			sut = newRouter(nil, nil)
+			sut.ServeHTTP(recorder, r)
+
+			Expect(recorder.Header().Get(logging.RequestIDHeader)).NotTo(BeEmpty())
+			Expect(recorder.Header().Get(logging.RequestIDHeader)).NotTo(Equal("bad id\n"))
+			Expect(r.Header.Get(logging.RequestIDHeader)).To(Equal(recorder.Header().Get(logging.RequestIDHeader)))
+		})
+	})
+
+	Describe("logging requests", func() {
+		It("should not log credentials", func() {
+			output := new(bytes.Buffer)
+			logger, err := log.LoggerFromWriterWithMinLevelAndFormat(output, log.TraceLvl, "%RedactedMsg%n")
+			Expect(err).NotTo(HaveOccurred())
+			log.ReplaceLogger(logger)
+			defer log.ReplaceLogger(log.Disabled)
+
+			body := `{"name": "stack", "configuration": [], "password": "body-secret"}`
+			r, _ := http.NewRequest("POST", "/v2/catalog", strings.NewReader(body))
+			r.SetBasicAuth("admin", "basic-secret")
+
+			sut = newRouter(nil, nil)
+			sut.ServeHTTP(httptest.NewRecorder(), r)
+			logger.Flush()
+
+			Expect(output.String()).To(ContainSubstring("POST /v2/catalog"))
+			Expect(output.String()).NotTo(ContainSubstring("body-secret"))
+			Expect(output.String()).NotTo(ContainSubstring(strings.TrimPrefix(r.Header.Get("Authorization"), "Basic ")))
+		})
 	})
 })
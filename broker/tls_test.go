@@ -0,0 +1,219 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package broker
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// testCertificate is certificate with its key, signed by parent or self-signed
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCertificate(name string, parent *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	Expect(err).NotTo(HaveOccurred())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+	return &testCertificate{cert: cert, key: key, der: der}
+}
+
+func (c *testCertificate) certPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der})
+}
+
+func (c *testCertificate) keyPEM() []byte {
+	der, err := x509.MarshalECPrivateKey(c.key)
+	Expect(err).NotTo(HaveOccurred())
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func (c *testCertificate) tlsCertificate() tls.Certificate {
+	cert, err := tls.X509KeyPair(c.certPEM(), c.keyPEM())
+	Expect(err).NotTo(HaveOccurred())
+	return cert
+}
+
+var _ = Describe("TLS", func() {
+
+	var (
+		dir          string
+		ca           *testCertificate
+		certFile     string
+		keyFile      string
+		clientCAFile string
+		address      string
+		server       *http.Server
+	)
+
+	write := func(file string, content []byte) {
+		Expect(ioutil.WriteFile(file, content, 0600)).To(Succeed())
+		// makes change visible regardless of file system timestamp resolution
+		later := time.Now().Add(time.Minute)
+		Expect(os.Chtimes(file, later, later)).To(Succeed())
+	}
+
+	writeServerCertificate := func(name string) {
+		cert := newTestCertificate(name, ca)
+		write(certFile, cert.certPEM())
+		write(keyFile, cert.keyPEM())
+	}
+
+	serve := func(caFile string) {
+		reloader, err := newCertificateReloader(certFile, keyFile, caFile, 0)
+		Expect(err).NotTo(HaveOccurred())
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		address = listener.Addr().String()
+		server = &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})}
+		go server.Serve(tls.NewListener(listener, reloader.tlsConfig()))
+	}
+
+	// get calls the server and returns common name of certificate it presented
+	get := func(clientCerts ...tls.Certificate) (string, error) {
+		roots := x509.NewCertPool()
+		roots.AddCert(ca.cert)
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: clientCerts},
+			DisableKeepAlives: true,
+		}}
+		resp, err := client.Get("https://" + address)
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].Subject.CommonName, nil
+	}
+
+	BeforeEach(func() {
+		var err error
+		server = nil
+		dir, err = ioutil.TempDir("", "broker-tls")
+		Expect(err).NotTo(HaveOccurred())
+		ca = newTestCertificate("ca", nil)
+		certFile = filepath.Join(dir, "broker.crt")
+		keyFile = filepath.Join(dir, "broker.key")
+		clientCAFile = filepath.Join(dir, "client-ca.crt")
+		writeServerCertificate("broker")
+		write(clientCAFile, ca.certPEM())
+	})
+
+	AfterEach(func() {
+		if server != nil {
+			server.Close()
+		}
+		os.RemoveAll(dir)
+	})
+
+	It("should serve certificate from files", func() {
+		serve("")
+
+		name, err := get()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("broker"))
+	})
+
+	It("should serve renewed certificate without restart", func() {
+		serve("")
+		writeServerCertificate("renewed")
+
+		name, err := get()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("renewed"))
+	})
+
+	It("should keep serving previous certificate when new files are broken", func() {
+		serve("")
+		write(keyFile, []byte("broken"))
+
+		name, err := get()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("broker"))
+	})
+
+	It("should fail to start without certificate", func() {
+		os.Remove(keyFile)
+
+		_, err := newCertificateReloader(certFile, keyFile, "", 0)
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("with client CA", func() {
+		It("should reject clients without certificate", func() {
+			serve(clientCAFile)
+
+			_, err := get()
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should reject clients with certificate of other CA", func() {
+			serve(clientCAFile)
+
+			_, err := get(newTestCertificate("intruder", nil).tlsCertificate())
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should accept clients with certificate signed by the CA", func() {
+			serve(clientCAFile)
+
+			_, err := get(newTestCertificate("cloud-controller", ca).tlsCertificate())
+
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,55 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cloud
+
+import (
+	"github.com/signalfx/golib/errors"
+	"github.com/trustedanalytics/go-cf-lib/types"
+	"sync"
+)
+
+// abortSignal is shared by CloudAPI and its traced copies
+type abortSignal struct {
+	once    sync.Once
+	aborted chan struct{}
+}
+
+func newAbortSignal() *abortSignal {
+	return &abortSignal{aborted: make(chan struct{})}
+}
+
+// Abort makes provisionings in progress stop before their next step and roll back what they created.
+// It is used on broker shutdown, later provisionings are aborted right away.
+func (cloud *CloudAPI) Abort() {
+	if cloud.abort == nil {
+		return
+	}
+	cloud.abort.once.Do(func() { close(cloud.abort.aborted) })
+}
+
+// aborted returns error when provisioning has to stop
+func (cloud *CloudAPI) aborted() error {
+	if cloud.abort == nil {
+		return nil
+	}
+	select {
+	case <-cloud.abort.aborted:
+		return errors.Annotate(types.InternalServerError, "Provisioning aborted, broker is shutting down")
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,44 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cloud
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/trustedanalytics/application-broker/tracing"
+	"net/http"
+)
+
+var _ = Describe("Abort", func() {
+
+	It("should not abort provisioning by default", func() {
+		sut := NewCloudAPIForClient("http://cc", http.DefaultClient)
+
+		Expect(sut.aborted()).To(Succeed())
+	})
+
+	It("should abort provisionings of traced copies", func() {
+		sut := NewCloudAPIForClient("http://cc", http.DefaultClient)
+		traced := sut.traced(&tracing.Span{})
+
+		sut.Abort()
+		sut.Abort()
+
+		Expect(sut.aborted()).To(HaveOccurred())
+		Expect(traced.aborted()).To(HaveOccurred())
+	})
+})
@@ -18,14 +18,30 @@ package cloud
 
 import (
 	"github.com/cloudfoundry-community/types-cf"
+	"github.com/trustedanalytics/application-broker/logging"
 	"github.com/trustedanalytics/application-broker/service/extension"
 )
 
 type API interface {
 	Provision(sourceAppGUID string,
 		servicesConfiguration []*extension.ServiceConfiguration,
-		request *cf.ServiceCreationRequest) (*extension.ServiceCreationResponse, error)
-	Deprovision(appGUID string) error
+		naming *extension.Naming,
+		mainRoutes []*extension.Route,
+		request *cf.ServiceCreationRequest,
+		logger *logging.Logger) (*extension.ServiceCreationResponse, error)
+	Preview(sourceAppGUID string,
+		servicesConfiguration []*extension.ServiceConfiguration,
+		naming *extension.Naming,
+		mainRoutes []*extension.Route,
+		request *cf.ServiceCreationRequest,
+		logger *logging.Logger) (*extension.ProvisioningPreview, error)
+	Deprovision(appGUID string, routeGUIDs []string, sharedServiceGUIDs []string, logger *logging.Logger) error
+	StopInstance(appGUID string, logger *logging.Logger) error
+	StartInstance(appGUID string, logger *logging.Logger) error
 	UpdateBroker(brokerName string, brokerURL string, username string, password string) error
 	CheckIfServiceExists(serviceName string) error
+	CheckServiceName(serviceName string) error
+	GetAppReference(appGUID string) (*extension.AppReference, error)
+	FindAppGUID(ref extension.AppReference) (string, error)
+	Abort()
 }
@@ -0,0 +1,134 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cloud
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/cihub/seelog"
+	"github.com/signalfx/golib/errors"
+	"github.com/trustedanalytics/application-broker/service/extension"
+	"github.com/trustedanalytics/go-cf-lib/helpers"
+	"github.com/trustedanalytics/go-cf-lib/types"
+	"net/http"
+	"net/url"
+)
+
+type cfOrg struct {
+	Name string `json:"name"`
+}
+
+type cfOrgResource struct {
+	Meta   types.CfMeta `json:"metadata"`
+	Entity cfOrg        `json:"entity"`
+}
+
+type cfOrgsResponse struct {
+	Count     int             `json:"total_results"`
+	Resources []cfOrgResource `json:"resources"`
+}
+
+type cfSpacesResponse struct {
+	Count     int                     `json:"total_results"`
+	Resources []types.CfSpaceResource `json:"resources"`
+}
+
+// GetAppReference translates application GUID to org, space and name of that application
+func (cloud *CloudAPI) GetAppReference(appGUID string) (*extension.AppReference, error) {
+	summary, err := cloud.cf.GetAppSummary(appGUID)
+	if err != nil {
+		return nil, err
+	}
+
+	space := new(types.CfSpaceResource)
+	address := fmt.Sprintf("%v/v2/spaces/%v", cloud.cf.BaseAddress, summary.SpaceGUID)
+	if err := cloud.getCfResource(address, "space", space); err != nil {
+		return nil, err
+	}
+
+	org := new(cfOrgResource)
+	address = fmt.Sprintf("%v/v2/organizations/%v", cloud.cf.BaseAddress, space.Entity.OrgGUID)
+	if err := cloud.getCfResource(address, "organization", org); err != nil {
+		return nil, err
+	}
+
+	return &extension.AppReference{
+		Org:   org.Entity.Name,
+		Space: space.Entity.Name,
+		Name:  summary.Name,
+	}, nil
+}
+
+// FindAppGUID looks for application of given name in given org and space
+func (cloud *CloudAPI) FindAppGUID(ref extension.AppReference) (string, error) {
+	orgs := new(cfOrgsResponse)
+	address := fmt.Sprintf("%v/v2/organizations?q=%v", cloud.cf.BaseAddress, nameQuery(ref.Org))
+	if err := cloud.getCfResource(address, "organizations", orgs); err != nil {
+		return "", err
+	}
+	if len(orgs.Resources) == 0 {
+		return "", errors.Annotatef(types.EntityNotFoundError, "Organization %v not found", ref.Org)
+	}
+
+	spaces := new(cfSpacesResponse)
+	address = fmt.Sprintf("%v/v2/organizations/%v/spaces?q=%v",
+		cloud.cf.BaseAddress, orgs.Resources[0].Meta.GUID, nameQuery(ref.Space))
+	if err := cloud.getCfResource(address, "spaces", spaces); err != nil {
+		return "", err
+	}
+	if len(spaces.Resources) == 0 {
+		return "", errors.Annotatef(types.EntityNotFoundError, "Space %v not found in organization %v", ref.Space, ref.Org)
+	}
+
+	apps := new(types.CfAppsResponse)
+	address = fmt.Sprintf("%v/v2/spaces/%v/apps?q=%v",
+		cloud.cf.BaseAddress, spaces.Resources[0].Meta.GUID, nameQuery(ref.Name))
+	if err := cloud.getCfResource(address, "apps", apps); err != nil {
+		return "", err
+	}
+	if len(apps.Resources) == 0 {
+		return "", errors.Annotatef(types.EntityNotFoundError, "Application %v not found in %v/%v", ref.Name, ref.Org, ref.Space)
+	}
+	return apps.Resources[0].Meta.GUID, nil
+}
+
+func (cloud *CloudAPI) getCfResource(address string, entityName string, target interface{}) error {
+	log.Debugf("Getting %s: %v", entityName, address)
+
+	response, err := cloud.cf.Get(address)
+	if err != nil {
+		msg := fmt.Sprintf("Could not get %s: [%v]", entityName, err)
+		log.Error(msg)
+		return errors.Annotate(types.InternalServerError, msg)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return types.EntityNotFoundError
+	}
+	if response.StatusCode != http.StatusOK {
+		msg := fmt.Sprintf("Get %s failed. Response from CC: (%d) [%v]",
+			entityName, response.StatusCode, helpers.ReaderToString(response.Body))
+		log.Error(msg)
+		return errors.Annotate(types.InternalServerError, msg)
+	}
+	return json.NewDecoder(response.Body).Decode(target)
+}
+
+func nameQuery(name string) string {
+	return url.QueryEscape("name:" + name)
+}
@@ -0,0 +1,98 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cloud
+
+import (
+	"github.com/jarcoal/httpmock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/signalfx/golib/errors"
+	"github.com/trustedanalytics/application-broker/service/extension"
+	"github.com/trustedanalytics/go-cf-lib/types"
+	"net/http"
+)
+
+const fakeCcAddress = "http://cc.example.com"
+
+var _ = Describe("App references", func() {
+
+	var (
+		sut *CloudAPI
+		ref extension.AppReference
+	)
+
+	BeforeEach(func() {
+		httpmock.Activate()
+		sut = NewCloudAPIForClient(fakeCcAddress, http.DefaultClient)
+		ref = extension.AppReference{Org: "org", Space: "space", Name: "app"}
+
+		orgs := cfOrgsResponse{Count: 1, Resources: []cfOrgResource{{Meta: types.CfMeta{GUID: "org-guid"}}}}
+		httpmock.RegisterResponder("GET", fakeCcAddress+"/v2/organizations", responderGenerator(200, orgs))
+		httpmock.RegisterResponder("GET", fakeCcAddress+"/v2/organizations/org-guid",
+			responderGenerator(200, cfOrgResource{Entity: cfOrg{Name: "org"}}))
+
+		spaces := cfSpacesResponse{Count: 1, Resources: []types.CfSpaceResource{{Meta: types.CfMeta{GUID: "space-guid"}}}}
+		httpmock.RegisterResponder("GET", fakeCcAddress+"/v2/organizations/org-guid/spaces", responderGenerator(200, spaces))
+		httpmock.RegisterResponder("GET", fakeCcAddress+"/v2/spaces/space-guid",
+			responderGenerator(200, types.CfSpaceResource{Entity: types.CfSpace{Name: "space", OrgGUID: "org-guid"}}))
+
+		summary := types.CfAppSummary{GUID: "app-guid"}
+		summary.Name = "app"
+		summary.SpaceGUID = "space-guid"
+		httpmock.RegisterResponder("GET", fakeCcAddress+"/v2/apps/app-guid/summary", responderGenerator(200, summary))
+	})
+
+	AfterEach(func() {
+		httpmock.DeactivateAndReset()
+	})
+
+	Describe("find app GUID", func() {
+		Context("when application exists", func() {
+			It("should return its GUID", func() {
+				apps := types.CfAppsResponse{Count: 1, Resources: []types.CfAppResource{{Meta: types.CfMeta{GUID: "app-guid"}}}}
+				httpmock.RegisterResponder("GET", fakeCcAddress+"/v2/spaces/space-guid/apps", responderGenerator(200, apps))
+
+				guid, err := sut.FindAppGUID(ref)
+
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(guid).To(Equal("app-guid"))
+			})
+		})
+
+		Context("when application does not exist", func() {
+			It("should return not found error", func() {
+				httpmock.RegisterResponder("GET", fakeCcAddress+"/v2/spaces/space-guid/apps",
+					responderGenerator(200, types.CfAppsResponse{}))
+
+				_, err := sut.FindAppGUID(ref)
+
+				Expect(err).Should(HaveOccurred())
+				Expect(errors.Message(err)).To(ContainSubstring("Application app not found"))
+				Expect(errors.Tail(err)).To(Equal(types.EntityNotFoundError))
+			})
+		})
+	})
+
+	Describe("get app reference", func() {
+		It("should return org, space and name of application", func() {
+			result, err := sut.GetAppReference("app-guid")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*result).To(Equal(ref))
+		})
+	})
+})
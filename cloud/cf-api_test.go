@@ -72,6 +72,9 @@ var _ = Describe("Cf api", func() {
 		positiveGetInstancesResponder = responderGenerator(200, instances)
 
 		negativeResponder = responderGenerator(400, nil)
+		// Current Go compilers reject variables which are only assigned
+		_, _, _, _ = positiveCreateAppResponder, positiveStatusCreatedResponder, positiveCopyBitsResponder, positiveRestageResponder
+		_, _, _, _ = positiveGetInstancesResponder, queuedCopyBitsResponder, negativeResponder, failedJobResponder
 	})
 
 	AfterEach(func() {
@@ -80,13 +83,12 @@ var _ = Describe("Cf api", func() {
 
 	Describe("service deprovision", func() {
 		var (
-			sut            *CloudAPI
-			app            types.CfAppSummary
-			appGUID        string
-			appURL         string
-			appSummaryURL  string
-			appBindingsURL string
-			bindings       types.CfBindingsResources
+			sut           *CloudAPI
+			app           types.CfAppSummary
+			appGUID       string
+			appURL        string
+			appSummaryURL string
+			bindings      types.CfBindingsResources
 		)
 
 		BeforeEach(func() {
@@ -98,7 +100,7 @@ var _ = Describe("Cf api", func() {
 			app = types.CfAppSummary{}
 			app.GUID = appGUID
 
-			bindings, appBindingsURL = registerServiceCleanupRequests(appGUID)
+			bindings, _ = registerServiceCleanupRequests(appGUID)
 			app.Routes = registerRoutesCleanupRequests(appGUID)
 
 			appURL = fmt.Sprintf("/v2/apps/%v", appGUID)
@@ -117,7 +119,7 @@ var _ = Describe("Cf api", func() {
 			It("should process as normal", func() {
 				httpmock.RegisterResponder("GET", appSummaryURL, responderGenerator(404, nil))
 
-				err := sut.Deprovision(appGUID)
+				err := sut.Deprovision(appGUID, nil, nil, nil)
 				Expect(err).ShouldNot(HaveOccurred())
 			})
 		})
@@ -126,7 +128,7 @@ var _ = Describe("Cf api", func() {
 			It("should continue silently", func() {
 				registerServiceUnbind(appGUID, bindings.Resources[1].Meta.GUID, responderGenerator(404, nil))
 
-				err := sut.Deprovision(appGUID)
+				err := sut.Deprovision(appGUID, nil, nil, nil)
 				Expect(err).ShouldNot(HaveOccurred())
 			})
 		})
@@ -135,7 +137,7 @@ var _ = Describe("Cf api", func() {
 			It("should continue silently", func() {
 				registerRouteUnbind(appGUID, app.Routes[1].GUID, responderGenerator(404, nil))
 
-				err := sut.Deprovision(appGUID)
+				err := sut.Deprovision(appGUID, nil, nil, nil)
 				Expect(err).ShouldNot(HaveOccurred())
 			})
 		})
@@ -144,7 +146,7 @@ var _ = Describe("Cf api", func() {
 			It("should forward error", func() {
 				registerRouteUnbind(appGUID, app.Routes[1].GUID, responderGenerator(500, nil))
 
-				err := sut.Deprovision(appGUID)
+				err := sut.Deprovision(appGUID, nil, nil, nil)
 				Expect(err).ShouldNot(HaveOccurred())
 			})
 		})
@@ -153,7 +155,7 @@ var _ = Describe("Cf api", func() {
 			It("should continue silently", func() {
 				registerRouteDelete(app.Routes[1].GUID, responderGenerator(404, nil))
 
-				err := sut.Deprovision(appGUID)
+				err := sut.Deprovision(appGUID, nil, nil, nil)
 				Expect(err).ShouldNot(HaveOccurred())
 			})
 		})
@@ -162,7 +164,7 @@ var _ = Describe("Cf api", func() {
 			It("should forward error", func() {
 				registerRouteDelete(app.Routes[1].GUID, responderGenerator(500, nil))
 
-				err := sut.Deprovision(appGUID)
+				err := sut.Deprovision(appGUID, nil, nil, nil)
 				Expect(err).ShouldNot(HaveOccurred())
 			})
 		})
@@ -171,14 +173,14 @@ var _ = Describe("Cf api", func() {
 			It("Should continue silently", func() {
 				registerServiceDelete(bindings.Resources[1].Entity.ServiceInstanceGUID, responderGenerator(404, nil))
 
-				err := sut.Deprovision(appGUID)
+				err := sut.Deprovision(appGUID, nil, nil, nil)
 				Expect(err).ShouldNot(HaveOccurred())
 			})
 		})
 
 		Context("Everything ok", func() {
 			It("should return OK", func() {
-				err := sut.Deprovision(appGUID)
+				err := sut.Deprovision(appGUID, nil, nil, nil)
 				Expect(err).ShouldNot(HaveOccurred())
 			})
 		})
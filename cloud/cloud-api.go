@@ -17,97 +17,168 @@
 package cloud
 
 import (
-	"fmt"
 	log "github.com/cihub/seelog"
 	"github.com/cloudfoundry-community/go-cfenv"
 	"github.com/cloudfoundry-community/types-cf"
 	"github.com/signalfx/golib/errors"
 	"github.com/trustedanalytics/application-broker/client"
 	"github.com/trustedanalytics/application-broker/env"
+	"github.com/trustedanalytics/application-broker/logging"
 	"github.com/trustedanalytics/application-broker/misc"
 	"github.com/trustedanalytics/application-broker/service/extension"
 	"github.com/trustedanalytics/go-cf-lib/api"
 	"github.com/trustedanalytics/go-cf-lib/types"
-	"strings"
+	"net/http"
 	"sync"
 )
 
 type CloudAPI struct {
 	cf            *api.CfAPI
 	appDepDiscUps *client.AppDependencyDiscovererUPS
+	abort         *abortSignal
 }
 
 func NewCloudAPI(envs *cfenv.App) *CloudAPI {
 	toReturn := new(CloudAPI)
 	toReturn.cf = api.NewCfAPI()
+	toReturn.cf.Client.Transport = newInstrumentedTransport(toReturn.cf.Client.Transport)
 	toReturn.appDepDiscUps = client.NewAppDependencyDiscovererUPS(envs)
+	toReturn.abort = newAbortSignal()
 
 	return toReturn
 }
 
+// NewCloudAPIForClient creates CloudAPI talking to Cloud Controller at given address using already authorized client.
+// It is meant for tools that only look up Cloud Controller entities and do not use app dependency discoverer.
+func NewCloudAPIForClient(address string, client *http.Client) *CloudAPI {
+	return &CloudAPI{cf: &api.CfAPI{BaseAddress: address, Client: client}, abort: newAbortSignal()}
+}
+
 // Provision instantiates service of given type
 func (cloud *CloudAPI) Provision(sourceAppGUID string,
 	servicesConfiguration []*extension.ServiceConfiguration,
-	r *cf.ServiceCreationRequest) (*extension.ServiceCreationResponse, error) {
+	naming *extension.Naming,
+	mainRoutes []*extension.Route,
+	r *cf.ServiceCreationRequest,
+	logger *logging.Logger) (response *extension.ServiceCreationResponse, err error) {
 
-	order, _ := cloud.Discovery(sourceAppGUID)
-	log.Infof("Discovery: [%v]", order)
-	log.Infof("%v components to spawn:", len(order))
+	// Each step is traced as a child span of the request, with Cloud Controller calls made within it
+	steps := newStepTracer(logger)
+	defer func() { steps.end(err) }()
 
+	cloud = steps.start(cloud, "discovery")
+	order, err := cloud.Discovery(sourceAppGUID)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("Discovery: [%v]", order)
+	logger.Infof("%v components to spawn:", len(order))
+	if err := validateStack(sourceAppGUID, order); err != nil {
+		logger.Errorf("%v", errors.Message(err))
+		return nil, err
+	}
+
+	cloud = steps.start(cloud, "plan")
 	cloud.logParameters(r.Parameters, servicesConfiguration)
 
 	componentsToSpawn := cloud.groupComponentsByType(order)
+	shared := applySharingPolicies(componentsToSpawn, servicesConfiguration)
 
-	suffix := strings.Split(r.InstanceID, "-")[0]
+	if err := cloud.resolveNamingLocation(naming, r.SpaceGUID); err != nil {
+		return nil, err
+	}
+	names, err := nameClones(naming, sourceAppGUID, componentsToSpawn)
+	if err != nil {
+		return nil, err
+	}
+	routes, sourceRoutes, err := cloud.planRoutes(sourceAppGUID, names, mainRoutes)
+	if err != nil {
+		return nil, err
+	}
+	if err := cloud.checkRoutesAvailable(routes, r.SpaceGUID); err != nil {
+		return nil, err
+	}
+
+	if err := cloud.aborted(); err != nil {
+		return nil, err
+	}
 
+	cloud = steps.start(cloud, "create_applications")
 	destAppsResources := make(map[string]*types.CfAppResource)
-	transaction := NewTransaction()
+	transaction := NewTransaction(logger)
 
-	log.Infof("Creating main application")
+	logger.Infof("Creating main application")
 	paramsWithoutNS, err := cloud.removeParametersNamespaces(r.Parameters)
 	if err != nil {
 		return nil, err
 	}
-	destApp, err := cloud.cf.CreateApplicationClone(sourceAppGUID, r.SpaceGUID, paramsWithoutNS)
+	if paramsWithoutNS == nil {
+		paramsWithoutNS = make(map[string]string)
+	}
+	paramsWithoutNS["name"] = names.apps[sourceAppGUID]
+	destApp, err := cloud.createApplicationClone(sourceAppGUID, r.SpaceGUID, paramsWithoutNS)
 	if err != nil {
 		return nil, err
 	}
 	destAppsResources[sourceAppGUID] = destApp
 	transaction.AddApplication(destApp)
+	if err := cloud.createRoutes(destApp, routes[sourceAppGUID], r.SpaceGUID, transaction); err != nil {
+		transaction.Rollback(cloud)
+		return nil, err
+	}
 
-	log.Infof("Creating dependent applications")
+	logger.Infof("Creating dependent applications")
 	for _, app := range componentsToSpawn[types.ComponentApp] {
 		if _, ok := destAppsResources[app.GUID]; !ok {
-			name := fmt.Sprintf("%v-%v", app.Name, suffix)
-			paramsWithoutNS["name"] = name
-			appRes, err := cloud.cf.CreateApplicationClone(app.GUID, r.SpaceGUID, paramsWithoutNS)
+			if err := cloud.aborted(); err != nil {
+				transaction.Rollback(cloud)
+				return nil, err
+			}
+			paramsWithoutNS["name"] = names.apps[app.GUID]
+			appRes, err := cloud.createApplicationClone(app.GUID, r.SpaceGUID, paramsWithoutNS)
 			if err != nil {
 				transaction.Rollback(cloud)
 				return nil, err
 			}
 			destAppsResources[app.GUID] = appRes
 			transaction.AddApplication(appRes)
+			if err := cloud.createRoutes(appRes, routes[app.GUID], r.SpaceGUID, transaction); err != nil {
+				transaction.Rollback(cloud)
+				return nil, err
+			}
 		}
 	}
 
-	log.Infof("Copying applications data")
+	if err := cloud.aborted(); err != nil {
+		transaction.Rollback(cloud)
+		return nil, err
+	}
+	logger.Infof("Copying applications data")
+	copying, copySpan := steps.startParallel(cloud, "copy_bits")
+	// Ends the span when provisioning fails before copying is awaited
+	defer copySpan.End(nil)
 	copyBitsAsyncErrors := make(chan error, len(destAppsResources))
 	for _, appRes := range destAppsResources {
-		go cloud.cf.CopyBits(sourceAppGUID, appRes.Meta.GUID, copyBitsAsyncErrors)
+		go copying.cf.CopyBits(sourceAppGUID, appRes.Meta.GUID, copyBitsAsyncErrors)
 	}
 
+	if err := cloud.aborted(); err != nil {
+		transaction.Rollback(cloud)
+		return nil, err
+	}
 	wg := sync.WaitGroup{}
 	wg.Add(len(componentsToSpawn[types.ComponentService]))
 	errors := make(chan error, len(componentsToSpawn[types.ComponentService]))
 	results := make(chan types.ComponentClone, len(componentsToSpawn[types.ComponentService]))
 	// Create dependent services
-	log.Infof("Creating dependent services")
+	cloud = steps.start(cloud, "create_services")
+	logger.Infof("Creating dependent services")
 	required_bindings := 0
 	for _, comp := range componentsToSpawn[types.ComponentService] {
 		required_bindings += len(comp.DependencyOf)
-		go cloud.cf.CreateServiceClone(destApp.Entity.SpaceGUID,
+		go cloud.CreateServiceClone(destApp.Entity.SpaceGUID,
 			cloud.selectAcceptedServiceParams(comp.Name, r.Parameters, servicesConfiguration),
-			comp, suffix, results, errors, &wg)
+			comp, names.services[comp.GUID], logger, results, errors, &wg)
 	}
 	wg.Wait()
 	close(errors)
@@ -119,16 +190,38 @@ func (cloud *CloudAPI) Provision(sourceAppGUID string,
 		transaction.Rollback(cloud)
 		return nil, err
 	}
-	log.Infof("Required bindings: %v", required_bindings)
+	// Clones are rolled back together with bindings made to them
+	serviceClones := []types.ComponentClone{}
+	for clone := range results {
+		serviceClones = append(serviceClones, clone)
+		transaction.AddComponentClone(&clone)
+	}
+	sharedGUIDs := []string{}
+	for _, comp := range shared {
+		required_bindings += len(comp.DependencyOf)
+		sharedGUIDs = append(sharedGUIDs, comp.GUID)
+	}
+	logger.Infof("Required bindings: %v", required_bindings)
+	if err := cloud.aborted(); err != nil {
+		transaction.Rollback(cloud)
+		return nil, err
+	}
 	wg.Add(required_bindings)
 	errorsBind := make(chan error, required_bindings)
 	// Bind services
-	log.Infof("Binding dependent services")
-	for clone := range results {
+	cloud = steps.start(cloud, "bind_services")
+	logger.Infof("Binding dependent services")
+	for _, clone := range serviceClones {
 		for _, dependent := range clone.Component.DependencyOf {
 			go cloud.cf.BindService(destAppsResources[dependent].Meta.GUID, clone.CloneGUID, errorsBind, &wg)
 		}
 	}
+	// Shared service instances are bound as they are, they never become part of the transaction
+	for _, comp := range shared {
+		for _, dependent := range comp.DependencyOf {
+			go cloud.cf.BindService(destAppsResources[dependent].Meta.GUID, comp.GUID, errorsBind, &wg)
+		}
+	}
 	wg.Wait()
 	close(errorsBind)
 	if err := misc.FirstNonEmpty(errorsBind, required_bindings); err != nil {
@@ -136,24 +229,27 @@ func (cloud *CloudAPI) Provision(sourceAppGUID string,
 		return nil, err
 	}
 
+	if err := cloud.aborted(); err != nil {
+		transaction.Rollback(cloud)
+		return nil, err
+	}
 	wg.Add(len(componentsToSpawn[types.ComponentUPS]))
 	errorsUPS := make(chan error, len(componentsToSpawn[types.ComponentUPS]))
 	resultsUPS := make(chan types.ComponentClone, len(componentsToSpawn[types.ComponentUPS]))
 	// Create dependent UPSes
-	log.Infof("Creating dependent user provided services")
+	cloud = steps.start(cloud, "create_user_provided_services")
+	logger.Infof("Creating dependent user provided services")
 	required_bindings = 0
+	linker := newCredentialLinker(sourceRoutes, routes)
+	placeholders := misc.NewPlaceholders(r.InstanceID, naming.Instance, r.SpaceGUID)
 	for _, comp := range componentsToSpawn[types.ComponentUPS] {
 		required_bindings += len(comp.DependencyOf)
 		url := ""
-		for _, app := range componentsToSpawn[types.ComponentApp] {
-			for _, dep := range app.DependencyOf {
-				if comp.GUID == dep {
-					url = destAppsResources[app.GUID].Meta.URL
-				}
-			}
+		if linked := linkedApp(comp, componentsToSpawn[types.ComponentApp]); len(linked) > 0 {
+			url = destAppsResources[linked].Meta.URL
 		}
-		go cloud.CreateUserProvidedServiceClone(destApp.Entity.SpaceGUID, comp, suffix, url,
-			resultsUPS, errorsUPS, &wg)
+		go cloud.CreateUserProvidedServiceClone(destApp.Entity.SpaceGUID, comp, names.upses[comp.GUID], url,
+			linker, placeholders, logger, resultsUPS, errorsUPS, &wg)
 	}
 	wg.Wait()
 	close(errorsUPS)
@@ -165,12 +261,22 @@ func (cloud *CloudAPI) Provision(sourceAppGUID string,
 		transaction.Rollback(cloud)
 		return nil, err
 	}
-	log.Infof("Required bindings: %v", required_bindings)
+	upsClones := []types.ComponentClone{}
+	for clone := range resultsUPS {
+		upsClones = append(upsClones, clone)
+		transaction.AddComponentClone(&clone)
+	}
+	logger.Infof("Required bindings: %v", required_bindings)
+	if err := cloud.aborted(); err != nil {
+		transaction.Rollback(cloud)
+		return nil, err
+	}
 	wg.Add(required_bindings)
 	errorsBindUPS := make(chan error, required_bindings)
 	// Bind UPSes
-	log.Infof("Binding dependent user provided services")
-	for clone := range resultsUPS {
+	cloud = steps.start(cloud, "bind_user_provided_services")
+	logger.Infof("Binding dependent user provided services")
+	for _, clone := range upsClones {
 		for _, dependent := range clone.Component.DependencyOf {
 			go cloud.cf.BindService(destAppsResources[dependent].Meta.GUID, clone.CloneGUID, errorsBindUPS, &wg)
 		}
@@ -183,47 +289,67 @@ func (cloud *CloudAPI) Provision(sourceAppGUID string,
 	}
 
 	//Waiting for copy_bits finish
-	log.Infof("Waiting for copy bits completion")
-	if err := misc.FirstNonEmpty(copyBitsAsyncErrors, len(destAppsResources)); err != nil {
+	steps.end(nil)
+	logger.Infof("Waiting for copy bits completion")
+	err = misc.FirstNonEmpty(copyBitsAsyncErrors, len(destAppsResources))
+	copySpan.End(err)
+	if err != nil {
 		transaction.Rollback(cloud)
 		return nil, err
 	}
 
-	// Starting applications one by one, not in parallel
-	log.Infof("Starting applications")
-	for _, comp := range componentsToSpawn[types.ComponentApp] {
+	if err := cloud.aborted(); err != nil {
+		transaction.Rollback(cloud)
+		return nil, err
+	}
+	// Starting applications once their dependencies are running, independent ones in parallel
+	cloud = steps.start(cloud, "start_applications")
+	logger.Infof("Starting applications")
+	err = startInDependencyOrder(componentsToSpawn[types.ComponentApp], func(comp types.Component) error {
 		if err := cloud.cf.StartApp(destAppsResources[comp.GUID]); err != nil {
-			transaction.Rollback(cloud)
-			return nil, err
+			return err
 		}
-		log.Infof("Application %v started", destAppsResources[comp.GUID].Entity.Name)
+		logger.Infof("Application %v started", destAppsResources[comp.GUID].Entity.Name)
+		return nil
+	})
+	if err != nil {
+		transaction.Rollback(cloud)
+		return nil, err
 	}
 
-	log.Infof("Service instance [%v] created", destApp.Entity.Name)
+	logger.Infof("Service instance [%v] created", destApp.Entity.Name)
 
 	toReturn := extension.ServiceCreationResponse{
 		App: *destApp,
 		ServiceCreationResponse: cf.ServiceCreationResponse{DashboardURL: ""},
+		Routes: transaction.Routes(),
+		SharedServices: sharedGUIDs,
 	}
 	return &toReturn, nil
 }
 
-// Deprovision remove instance of given application (that stands behind service instance though)
-func (cloud *CloudAPI) Deprovision(appGUID string) error {
+// Deprovision remove instance of given application (that stands behind service instance though).
+// Only routes listed in routeGUIDs are deleted, all routes of the applications are deleted when it is nil.
+// Service instances listed in sharedServiceGUIDs are only unbound.
+func (cloud *CloudAPI) Deprovision(appGUID string, routeGUIDs []string, sharedServiceGUIDs []string,
+	logger *logging.Logger) error {
+	cloud = cloud.traced(logger.Span())
 	order, _ := cloud.Discovery(appGUID)
-	log.Infof("Discovery: [%v]", order)
-	log.Infof("%v components to remove:", len(order))
+	logger.Infof("Discovery: [%v]", order)
+	order = withoutComponents(order, sharedServiceGUIDs)
+	logger.Infof("%v components to remove:", len(order))
 
-	return cloud.deprovisionComponents(order)
+	return cloud.deprovisionComponents(order, routeGUIDs, logger)
 }
 
-func (cloud *CloudAPI) deprovisionComponents(order []types.Component) error {
+func (cloud *CloudAPI) deprovisionComponents(order []types.Component, routeGUIDs []string,
+	logger *logging.Logger) error {
 	componentsToRemove := cloud.groupComponentsByType(order)
 
 	wg := sync.WaitGroup{}
 
 	// Unbind services and UPSes
-	log.Infof("Unbinding services and user provided services")
+	logger.Infof("Unbinding services and user provided services")
 	results := make(chan error, len(componentsToRemove[types.ComponentApp]))
 	wg.Add(len(componentsToRemove[types.ComponentApp]))
 	for _, app := range componentsToRemove[types.ComponentApp] {
@@ -233,11 +359,11 @@ func (cloud *CloudAPI) deprovisionComponents(order []types.Component) error {
 	close(results)
 	for err := range results {
 		if !cloud.isErrorAcceptedDuringDeprovision(err) {
-			log.Errorf("Error occured when unbinding services and upses: %v", err.Error())
+			logger.Errorf("Error occured when unbinding services and upses: %v", err.Error())
 		}
 	}
 
-	log.Infof("Removing service instances without bindings")
+	logger.Infof("Removing service instances without bindings")
 	resultsSvc := make(chan error, len(componentsToRemove[types.ComponentService]))
 	wg.Add(len(componentsToRemove[types.ComponentService]))
 	for _, svc := range componentsToRemove[types.ComponentService] {
@@ -247,11 +373,11 @@ func (cloud *CloudAPI) deprovisionComponents(order []types.Component) error {
 	close(resultsSvc)
 	for err := range resultsSvc {
 		if !cloud.isErrorAcceptedDuringDeprovision(err) {
-			log.Errorf("Error occured when removing service instances: %v", err.Error())
+			logger.Errorf("Error occured when removing service instances: %v", err.Error())
 		}
 	}
 
-	log.Infof("Removing user provided service instances without bindings")
+	logger.Infof("Removing user provided service instances without bindings")
 	resultsUPS := make(chan error, len(componentsToRemove[types.ComponentUPS]))
 	wg.Add(len(componentsToRemove[types.ComponentUPS]))
 	for _, ups := range componentsToRemove[types.ComponentUPS] {
@@ -261,25 +387,31 @@ func (cloud *CloudAPI) deprovisionComponents(order []types.Component) error {
 	close(resultsUPS)
 	for err := range resultsUPS {
 		if !cloud.isErrorAcceptedDuringDeprovision(err) {
-			log.Errorf("Error occured when removing user provided service instances: %v", err.Error())
+			logger.Errorf("Error occured when removing user provided service instances: %v", err.Error())
 		}
 	}
 
-	log.Infof("Unbinding and deleting application routes")
-	resultsRoutes := make(chan error, len(componentsToRemove[types.ComponentApp]))
-	wg.Add(len(componentsToRemove[types.ComponentApp]))
-	for _, app := range componentsToRemove[types.ComponentApp] {
-		go cloud.cf.DeleteRoutes(app.GUID, resultsRoutes, &wg)
-	}
-	wg.Wait()
-	close(resultsRoutes)
-	for err := range resultsRoutes {
-		if !cloud.isErrorAcceptedDuringDeprovision(err) {
-			log.Errorf("Error occured when unbinding and deleting application routes: %v", err.Error())
+	if routeGUIDs != nil {
+		logger.Infof("Unbinding and deleting routes created by the broker")
+		cloud.deleteCreatedRoutes(componentsToRemove[types.ComponentApp], routeGUIDs)
+	} else {
+		// Instances created before routes were recorded
+		logger.Infof("Unbinding and deleting application routes")
+		resultsRoutes := make(chan error, len(componentsToRemove[types.ComponentApp]))
+		wg.Add(len(componentsToRemove[types.ComponentApp]))
+		for _, app := range componentsToRemove[types.ComponentApp] {
+			go cloud.cf.DeleteRoutes(app.GUID, resultsRoutes, &wg)
+		}
+		wg.Wait()
+		close(resultsRoutes)
+		for err := range resultsRoutes {
+			if !cloud.isErrorAcceptedDuringDeprovision(err) {
+				logger.Errorf("Error occured when unbinding and deleting application routes: %v", err.Error())
+			}
 		}
 	}
 
-	log.Infof("Deleting applications")
+	logger.Infof("Deleting applications")
 	for _, app := range componentsToRemove[types.ComponentApp] {
 		_ = cloud.cf.DeleteApp(app.GUID)
 	}
@@ -301,19 +433,30 @@ func (cloud *CloudAPI) UpdateBroker(brokerName string, brokerURL string, usernam
 }
 
 func (cloud *CloudAPI) CheckIfServiceExists(serviceName string) error {
+	duplicate, err := cloud.findOwnService(serviceName)
+	if err != nil || duplicate == nil {
+		return err
+	}
+	log.Infof("Service name was registered in CF for THIS broker but was missing in internal DB, purging...", serviceName)
+	return cloud.cf.PurgeService(duplicate.Meta.GUID, duplicate.Entity.Name, duplicate.Entity.PlansURL)
+}
+
+// CheckServiceName fails when CheckIfServiceExists would, but purges nothing. It is meant for dry runs.
+func (cloud *CloudAPI) CheckServiceName(serviceName string) error {
+	_, err := cloud.findOwnService(serviceName)
+	return err
+}
+
+// findOwnService returns service of given name registered in CF by this broker, it fails when different broker registered it
+func (cloud *CloudAPI) findOwnService(serviceName string) (*types.CfServiceResource, error) {
 	myData := env.GetVcapApplication()
 	broker, err := cloud.cf.GetBrokers(myData.Name)
 	duplicate, err := cloud.cf.GetServiceOfName(serviceName)
-	if err != nil {
-		return err
+	if err != nil || duplicate == nil {
+		return nil, err
 	}
-	if duplicate != nil {
-		if broker.TotalResults == 0 || broker.Resources[0].Meta.GUID != duplicate.Entity.BrokerGUID {
-			return errors.Annotate(types.InternalServerError, "Service name already registered in different CF broker!")
-		} else if broker.TotalResults > 0 && broker.Resources[0].Meta.GUID == duplicate.Entity.BrokerGUID {
-			log.Infof("Service name was registered in CF for THIS broker but was missing in internal DB, purging...", serviceName)
-			return cloud.cf.PurgeService(duplicate.Meta.GUID, duplicate.Entity.Name, duplicate.Entity.PlansURL)
-		}
+	if broker.TotalResults == 0 || broker.Resources[0].Meta.GUID != duplicate.Entity.BrokerGUID {
+		return nil, errors.Annotate(types.InternalServerError, "Service name already registered in different CF broker!")
 	}
-	return nil
+	return duplicate, nil
 }
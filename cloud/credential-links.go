@@ -0,0 +1,146 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cloud
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/cihub/seelog"
+)
+
+// routeLink points route of source application to route of its clone
+type routeLink struct {
+	source string
+	clone  string
+}
+
+// routeLinksByLength puts longer routes first, so that route is not taken for part of longer one
+type routeLinksByLength []routeLink
+
+func (l routeLinksByLength) Len() int           { return len(l) }
+func (l routeLinksByLength) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l routeLinksByLength) Less(i, j int) bool { return len(l[i].source) > len(l[j].source) }
+
+// credentialLinker rewrites references to routes of source applications found in credentials
+// of cloned user provided services, so that they point to routes of cloned applications
+type credentialLinker struct {
+	links []routeLink
+	// dryRun linker rewrites credentials of previewed clones, without writing the audit log
+	dryRun bool
+}
+
+// newCredentialLinker links every route of source application with the first route planned for its clone.
+// Only host and domain are linked, path of the planned route is not added, as references are rewritten
+// in place and may stand for a host, e.g. in host:port, as well as for URL prefix.
+func newCredentialLinker(sources map[string][]string, planned map[string][]plannedRoute) *credentialLinker {
+	linker := &credentialLinker{links: []routeLink{}}
+	for appGUID, routes := range sources {
+		if len(planned[appGUID]) == 0 {
+			continue
+		}
+		clone := fmt.Sprintf("%v.%v", planned[appGUID][0].host, planned[appGUID][0].domain.Name)
+		for _, source := range routes {
+			if source != clone {
+				linker.links = append(linker.links, routeLink{source: source, clone: clone})
+			}
+		}
+	}
+	sort.Sort(routeLinksByLength(linker.links))
+	return linker
+}
+
+// rewrite replaces routes of source applications in string values of credentials at any depth.
+// Every rewrite is written to the audit log.
+func (l *credentialLinker) rewrite(upsName string, credentials map[string]interface{}) {
+	if l == nil {
+		return
+	}
+	for key, value := range credentials {
+		credentials[key] = l.rewriteValue(upsName, key, value)
+	}
+}
+
+func (l *credentialLinker) rewriteValue(upsName, path string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		rewritten, applied := rewriteRouteReferences(v, l.links)
+		if l.dryRun {
+			return rewritten
+		}
+		for _, link := range applied {
+			log.Infof("Audit: credential %v of user provided service %v rewritten from %v to %v",
+				path, upsName, link.source, link.clone)
+		}
+		return rewritten
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = l.rewriteValue(upsName, path+"."+key, item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = l.rewriteValue(upsName, fmt.Sprintf("%v[%d]", path, i), item)
+		}
+	}
+	return value
+}
+
+// rewriteRouteReferences replaces routes standing alone in value, e.g. in URL or host:port,
+// but not the ones being part of longer host name
+func rewriteRouteReferences(value string, links []routeLink) (string, []routeLink) {
+	var buffer bytes.Buffer
+	applied := []routeLink{}
+	for i := 0; i < len(value); {
+		link, ok := routeLinkAt(value, i, links)
+		if !ok {
+			buffer.WriteByte(value[i])
+			i++
+			continue
+		}
+		buffer.WriteString(link.clone)
+		applied = append(applied, link)
+		i += len(link.source)
+	}
+	return buffer.String(), applied
+}
+
+func routeLinkAt(value string, i int, links []routeLink) (routeLink, bool) {
+	if i > 0 && (isHostnameChar(value[i-1]) || value[i-1] == '.') {
+		return routeLink{}, false
+	}
+	for _, link := range links {
+		if !strings.HasPrefix(value[i:], link.source) {
+			continue
+		}
+		end := i + len(link.source)
+		if end < len(value) && isHostnameChar(value[end]) {
+			continue
+		}
+		// Dot ending a sentence is fine, dot followed by another label is not
+		if end+1 < len(value) && value[end] == '.' && isHostnameChar(value[end+1]) {
+			continue
+		}
+		return link, true
+	}
+	return routeLink{}, false
+}
+
+func isHostnameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+}
@@ -0,0 +1,100 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cloud
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+var _ = Describe("Credential links", func() {
+
+	var sut *credentialLinker
+
+	BeforeEach(func() {
+		domain := types.CfDomain{GUID: "d", Name: "example.com"}
+		sut = newCredentialLinker(
+			map[string][]string{
+				"api":  {"api.example.com"},
+				"auth": {"auth.example.com", "login.example.com"},
+			},
+			map[string][]plannedRoute{
+				"api":  {{host: "api-abcd", domain: domain}},
+				"auth": {{host: "auth-abcd", domain: domain}},
+			})
+	})
+
+	It("should rewrite routes of source apps in any key and at any depth", func() {
+		credentials := map[string]interface{}{
+			"host": "api.example.com",
+			"oauth": map[string]interface{}{
+				"callback": "https://login.example.com:8443/callback?next=http://api.example.com/",
+				"hosts":    []interface{}{"auth.example.com", 8080.0},
+			},
+		}
+
+		sut.rewrite("stack-ups", credentials)
+
+		Expect(credentials).To(Equal(map[string]interface{}{
+			"host": "api-abcd.example.com",
+			"oauth": map[string]interface{}{
+				"callback": "https://auth-abcd.example.com:8443/callback?next=http://api-abcd.example.com/",
+				"hosts":    []interface{}{"auth-abcd.example.com", 8080.0},
+			},
+		}))
+	})
+
+	It("should leave routes being part of longer host names intact", func() {
+		credentials := map[string]interface{}{
+			"other": "myapi.example.com v2.api.example.com api.example.com.evil.org api.example.community",
+			"end":   "served by api.example.com.",
+		}
+
+		sut.rewrite("stack-ups", credentials)
+
+		Expect(credentials["other"]).To(Equal("myapi.example.com v2.api.example.com api.example.com.evil.org api.example.community"))
+		Expect(credentials["end"]).To(Equal("served by api-abcd.example.com."))
+	})
+
+	It("should point references at host and domain of clone route, leaving paths unchanged", func() {
+		domain := types.CfDomain{GUID: "d", Name: "example.com"}
+		sut = newCredentialLinker(map[string][]string{"api": {"api.example.com"}},
+			map[string][]plannedRoute{"api": {{host: "shop", domain: domain, path: "/api"}}})
+		credentials := map[string]interface{}{
+			"url":  "https://api.example.com/v1/orders",
+			"host": "api.example.com:443",
+		}
+
+		sut.rewrite("stack-ups", credentials)
+
+		Expect(credentials).To(Equal(map[string]interface{}{
+			"url":  "https://shop.example.com/v1/orders",
+			"host": "shop.example.com:443",
+		}))
+	})
+
+	It("should report applied rewrites", func() {
+		rewritten, applied := rewriteRouteReferences("api.example.com,auth.example.com", sut.links)
+
+		Expect(rewritten).To(Equal("api-abcd.example.com,auth-abcd.example.com"))
+		Expect(applied).To(Equal([]routeLink{
+			{source: "api.example.com", clone: "api-abcd.example.com"},
+			{source: "auth.example.com", clone: "auth-abcd.example.com"},
+		}))
+	})
+})
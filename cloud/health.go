@@ -0,0 +1,55 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cloud
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// PingCloudController checks that Cloud Controller answers info call
+func (cloud *CloudAPI) PingCloudController() error {
+	response, err := cloud.cf.Get(cloud.cf.BaseAddress + "/v2/info")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("Cloud Controller info call returned status %v", response.StatusCode)
+	}
+	return nil
+}
+
+// PingDiscoverer checks that app dependency discoverer accepts broker credentials.
+// Discoverer has no dedicated health endpoint, so any response other than server error or authorization failure counts.
+func (cloud *CloudAPI) PingDiscoverer() error {
+	request, err := http.NewRequest("GET", cloud.appDepDiscUps.Url+"/v1/discover/", nil)
+	if err != nil {
+		return err
+	}
+	request.SetBasicAuth(cloud.appDepDiscUps.AuthUser, cloud.appDepDiscUps.AuthPass)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusInternalServerError || response.StatusCode == http.StatusUnauthorized ||
+		response.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("App dependency discoverer returned status %v", response.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cloud
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("Health", func() {
+
+	var (
+		server *httptest.Server
+		status int
+		path   string
+	)
+
+	BeforeEach(func() {
+		status = http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			path = req.URL.Path
+			w.WriteHeader(status)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should call Cloud Controller info endpoint", func() {
+		sut := NewCloudAPIForClient(server.URL, http.DefaultClient)
+
+		Expect(sut.PingCloudController()).To(Succeed())
+		Expect(path).To(Equal("/v2/info"))
+	})
+
+	It("should fail when Cloud Controller does not answer info call", func() {
+		status = http.StatusBadGateway
+		sut := NewCloudAPIForClient(server.URL, http.DefaultClient)
+
+		Expect(sut.PingCloudController()).To(MatchError(ContainSubstring("502")))
+	})
+})
@@ -0,0 +1,113 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cloud
+
+import (
+	"fmt"
+	"github.com/trustedanalytics/application-broker/logging"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+// StopInstance stops all applications of the stack behind service instance, dependent applications first
+func (cloud *CloudAPI) StopInstance(appGUID string, logger *logging.Logger) error {
+	apps, err := cloud.instanceApps(appGUID)
+	if err != nil {
+		return err
+	}
+	for i := len(apps) - 1; i >= 0; i-- {
+		app, err := cloud.getApp(apps[i].GUID)
+		if err != nil {
+			return err
+		}
+		app.Entity.State = types.AppStopped
+		if err := cloud.cf.UpdateApp(app); err != nil {
+			return err
+		}
+		logger.Infof("Application %v stopped", app.Entity.Name)
+	}
+	return nil
+}
+
+// StartInstance starts applications of the stack behind service instance in dependency order.
+// Every application has to be running before applications depending on it are started.
+func (cloud *CloudAPI) StartInstance(appGUID string, logger *logging.Logger) error {
+	apps, err := cloud.instanceApps(appGUID)
+	if err != nil {
+		return err
+	}
+	return startInDependencyOrder(apps, func(comp types.Component) error {
+		app, err := cloud.getApp(comp.GUID)
+		if err != nil {
+			return err
+		}
+		if err := cloud.cf.StartApp(app); err != nil {
+			return err
+		}
+		logger.Infof("Application %v started", app.Entity.Name)
+		return nil
+	})
+}
+
+func (cloud *CloudAPI) instanceApps(appGUID string) ([]types.Component, error) {
+	order, err := cloud.Discovery(appGUID)
+	if err != nil {
+		return nil, err
+	}
+	return orderByDependencies(cloud.groupComponentsByType(order)[types.ComponentApp]), nil
+}
+
+func (cloud *CloudAPI) getApp(appGUID string) (*types.CfAppResource, error) {
+	app := new(types.CfAppResource)
+	address := fmt.Sprintf("%v/v2/apps/%v", cloud.cf.BaseAddress, appGUID)
+	if err := cloud.getCfResource(address, "application", app); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// orderByDependencies sorts components so that each one precedes components listed in its DependencyOf.
+// Discovery order is kept otherwise. Components forming a cycle are appended in discovery order.
+func orderByDependencies(components []types.Component) []types.Component {
+	pending := make(map[string]int)
+	for _, comp := range components {
+		for _, dependent := range comp.DependencyOf {
+			pending[dependent]++
+		}
+	}
+
+	ordered := []types.Component{}
+	done := make(map[string]bool)
+	for progress := true; progress; {
+		progress = false
+		for _, comp := range components {
+			if done[comp.GUID] || pending[comp.GUID] > 0 {
+				continue
+			}
+			done[comp.GUID] = true
+			progress = true
+			ordered = append(ordered, comp)
+			for _, dependent := range comp.DependencyOf {
+				pending[dependent]--
+			}
+		}
+	}
+	for _, comp := range components {
+		if !done[comp.GUID] {
+			ordered = append(ordered, comp)
+		}
+	}
+	return ordered
+}
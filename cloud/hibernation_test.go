@@ -0,0 +1,59 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cloud
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+var _ = Describe("Hibernation", func() {
+
+	guids := func(components []types.Component) []string {
+		result := []string{}
+		for _, comp := range components {
+			result = append(result, comp.GUID)
+		}
+		return result
+	}
+
+	Describe("order by dependencies", func() {
+		Context("when dependents are discovered before their dependencies", func() {
+			It("should put dependencies first", func() {
+				components := []types.Component{
+					{GUID: "main"},
+					{GUID: "api", DependencyOf: []string{"main"}},
+					{GUID: "db-proxy", DependencyOf: []string{"api", "main"}},
+				}
+
+				Expect(guids(orderByDependencies(components))).To(Equal([]string{"db-proxy", "api", "main"}))
+			})
+		})
+
+		Context("when components form a cycle", func() {
+			It("should keep all of them in discovery order", func() {
+				components := []types.Component{
+					{GUID: "standalone"},
+					{GUID: "a", DependencyOf: []string{"b"}},
+					{GUID: "b", DependencyOf: []string{"a"}},
+				}
+
+				Expect(guids(orderByDependencies(components))).To(Equal([]string{"standalone", "a", "b"}))
+			})
+		})
+	})
+})
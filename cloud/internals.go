@@ -21,6 +21,8 @@ import (
 	"fmt"
 	log "github.com/cihub/seelog"
 	"github.com/signalfx/golib/errors"
+	"github.com/trustedanalytics/application-broker/logging"
+	"github.com/trustedanalytics/application-broker/metrics"
 	"github.com/trustedanalytics/application-broker/misc"
 	"github.com/trustedanalytics/application-broker/service/extension"
 	"github.com/trustedanalytics/go-cf-lib/helpers"
@@ -28,15 +30,60 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 )
 
+// Clones service instance into the same plan, under given name
+func (cloud *CloudAPI) CreateServiceClone(spaceGUID string, params map[string]interface{}, comp types.Component,
+	serviceName string, logger *logging.Logger, results chan types.ComponentClone, errorsCh chan error, wg *sync.WaitGroup) {
+
+	defer wg.Done()
+
+	if len(comp.DependencyOf) == 0 {
+		errorsCh <- errors.New("Service not attached to any application")
+		return
+	}
+	parentApp, err := cloud.cf.GetAppSummary(comp.DependencyOf[0])
+	if err != nil {
+		errorsCh <- err
+		return
+	}
+
+	var svc types.CfAppSummaryService
+	for _, s := range parentApp.Services {
+		if s.GUID == comp.GUID {
+			svc = s
+		}
+	}
+	logger.Debugf("Create dependent service: service=[%v] ([%v], [%v])",
+		serviceName, svc.Plan.Service.Label, svc.Plan.Name)
+
+	svcInstanceReq := types.NewCfServiceInstanceRequest(serviceName, spaceGUID, svc.Plan)
+	if params != nil {
+		logger.Infof("Passing additional params for service %v: %v", serviceName, params)
+		svcInstanceReq.Params = params
+	}
+	response, err := cloud.cf.CreateServiceInstance(svcInstanceReq)
+	if err != nil {
+		errorsCh <- err
+		return
+	}
+	logger.Debugf("Dependent service created: Service Instance GUID=[%v]", response.Meta.GUID)
+
+	results <- types.ComponentClone{
+		Component: comp,
+		CloneGUID: response.Meta.GUID,
+	}
+	errorsCh <- nil
+	return
+}
+
 // Clones user provided service with additional replacements of its content
-func (cloud *CloudAPI) CreateUserProvidedServiceClone(spaceGUID string, comp types.Component, suffix, url string,
-	results chan types.ComponentClone, errorsCh chan error, wg *sync.WaitGroup) {
+func (cloud *CloudAPI) CreateUserProvidedServiceClone(spaceGUID string, comp types.Component, serviceName, url string,
+	linker *credentialLinker, placeholders *misc.Placeholders, logger *logging.Logger, results chan types.ComponentClone, errorsCh chan error, wg *sync.WaitGroup) {
 
 	defer wg.Done()
-	serviceName := comp.Name + "-" + suffix
-	log.Debugf("Create dependent user provided service: service=[%v])", serviceName)
+	logger.Debugf("Create dependent user provided service: service=[%v])", serviceName)
 
 	// Retrieve UPS
 	response, err := cloud.cf.GetUserProvidedService(comp.GUID)
@@ -44,7 +91,7 @@ func (cloud *CloudAPI) CreateUserProvidedServiceClone(spaceGUID string, comp typ
 		errorsCh <- err
 		return
 	}
-	log.Infof("Dependent user provided service retrieved: %+v", response)
+	logger.Debugf("Dependent user provided service retrieved: name=[%v], GUID=[%v]", response.Entity.Name, response.Meta.GUID)
 
 	// Create UPS
 	response.Entity.Name = serviceName
@@ -53,10 +100,14 @@ func (cloud *CloudAPI) CreateUserProvidedServiceClone(spaceGUID string, comp typ
 	// Replace url to match clone application route
 	if len(url) > 0 {
 		response.Entity.Credentials["url"] = fmt.Sprintf("http://%v", url)
-		response.Entity.Name = fmt.Sprintf("%v-ups", strings.Split(url, ".")[0])
 	}
+	// Point other references to source applications at their clones
+	linker.rewrite(serviceName, response.Entity.Credentials)
 	// Generate random values where needed
-	_ = cloud.applyAdditionalReplacementsInUPSCredentials(response)
+	if err := cloud.applyAdditionalReplacementsInUPSCredentials(response, placeholders); err != nil {
+		errorsCh <- err
+		return
+	}
 
 	response, err = cloud.cf.CreateUserProvidedServiceInstance(&response.Entity)
 	if err != nil {
@@ -64,7 +115,7 @@ func (cloud *CloudAPI) CreateUserProvidedServiceClone(spaceGUID string, comp typ
 		return
 	}
 	spawnedServiceInstanceGUID := response.Meta.GUID
-	log.Debugf("Dependent user provided service created. Service Instance GUID=[%v]", spawnedServiceInstanceGUID)
+	logger.Debugf("Dependent user provided service created. Service Instance GUID=[%v]", spawnedServiceInstanceGUID)
 
 	results <- types.ComponentClone{
 		Component: comp,
@@ -78,6 +129,10 @@ func (cloud *CloudAPI) Discovery(sourceAppGUID string) ([]types.Component, error
 	address := fmt.Sprintf("%v/v1/discover/%v", cloud.appDepDiscUps.Url, sourceAppGUID)
 	log.Infof("Getting application stack components: %v", address)
 
+	start := time.Now()
+	outcome := metrics.ServerErrorOutcome
+	defer func() { metrics.DiscoveryDuration.ObserveSince(start, outcome) }()
+
 	client := &http.Client{}
 	request, err := http.NewRequest("GET", address, nil)
 	request.SetBasicAuth(cloud.appDepDiscUps.AuthUser, cloud.appDepDiscUps.AuthPass)
@@ -87,6 +142,7 @@ func (cloud *CloudAPI) Discovery(sourceAppGUID string) ([]types.Component, error
 		log.Error(msg)
 		return nil, errors.Annotate(types.InternalServerError, msg)
 	}
+	outcome = metrics.Outcome(response.StatusCode)
 
 	if response.StatusCode == http.StatusNotFound {
 		return nil, types.EntityNotFoundError
@@ -221,15 +277,13 @@ func (cloud *CloudAPI) isErrorAcceptedDuringDeprovision(err error) bool {
 	return false
 }
 
-func (cloud *CloudAPI) applyAdditionalReplacementsInUPSCredentials(response *types.CfUserProvidedServiceResource) error {
-	credentials, err := json.Marshal(response.Entity.Credentials)
-	if err != nil {
-		return err
+func (cloud *CloudAPI) applyAdditionalReplacementsInUPSCredentials(response *types.CfUserProvidedServiceResource,
+	placeholders *misc.Placeholders) error {
+
+	if _, err := placeholders.ReplaceInValue(response.Entity.Credentials); err != nil {
+		return errors.Annotatef(err, "Cannot replace placeholders in user provided service %v", response.Entity.Name)
 	}
-	credentialsStr := string(credentials)
-	credentialsStr = misc.ReplaceWithRandom(credentialsStr)
-	log.Infof("Final UPS %v content %v", response.Entity.Name, credentialsStr)
-	json.Unmarshal([]byte(credentialsStr), &response.Entity.Credentials)
+	log.Debugf("Final UPS %v credentials prepared", response.Entity.Name)
 	return nil
 }
 
@@ -0,0 +1,64 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cloud
+
+import (
+	"github.com/trustedanalytics/application-broker/metrics"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var guidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// instrumentedTransport measures Cloud Controller calls made by go-cf-lib
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+func newInstrumentedTransport(next http.RoundTripper) *instrumentedTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &instrumentedTransport{next: next}
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	operation := ccOperation(req)
+	start := time.Now()
+	response, err := t.next.RoundTrip(req)
+	metrics.CloudControllerDuration.ObserveSince(start, operation)
+	if err != nil {
+		metrics.CloudControllerErrors.Inc(operation, "transport")
+	} else if response.StatusCode >= http.StatusBadRequest {
+		metrics.CloudControllerErrors.Inc(operation, strconv.Itoa(response.StatusCode))
+	}
+	return response, err
+}
+
+// ccOperation names Cloud Controller call by its method and path with GUIDs replaced, e.g. "GET /v2/apps/:guid/summary"
+func ccOperation(req *http.Request) string {
+	segments := strings.Split(req.URL.Path, "/")
+	for i, segment := range segments {
+		if guidPattern.MatchString(segment) {
+			segments[i] = ":guid"
+		}
+	}
+	return req.Method + " " + strings.Join(segments, "/")
+}
@@ -0,0 +1,70 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cloud
+
+import (
+	"bytes"
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/trustedanalytics/application-broker/metrics"
+	"net/http"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+var _ = Describe("Metrics", func() {
+
+	exposition := func() string {
+		output := new(bytes.Buffer)
+		metrics.Default.WriteTo(output)
+		return output.String()
+	}
+
+	It("should name Cloud Controller operations without GUIDs and query", func() {
+		req, _ := http.NewRequest("GET",
+			"http://cc/v2/apps/5c2b3e8a-1d4f-4a6b-9e2d-7f8a9b0c1d2e/summary?inline-relations-depth=1", nil)
+
+		Expect(ccOperation(req)).To(Equal("GET /v2/apps/:guid/summary"))
+	})
+
+	It("should measure latency and count failed Cloud Controller calls", func() {
+		sut := newInstrumentedTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method == "DELETE" {
+				return nil, errors.New("connection refused")
+			}
+			return &http.Response{StatusCode: http.StatusNotFound}, nil
+		}))
+		get, _ := http.NewRequest("GET", "http://cc/v2/metrics_test_routes/5c2b3e8a-1d4f-4a6b-9e2d-7f8a9b0c1d2e", nil)
+		del, _ := http.NewRequest("DELETE", "http://cc/v2/metrics_test_routes/5c2b3e8a-1d4f-4a6b-9e2d-7f8a9b0c1d2e", nil)
+
+		sut.RoundTrip(get)
+		_, err := sut.RoundTrip(del)
+
+		Expect(err).To(HaveOccurred())
+		Expect(exposition()).To(ContainSubstring(
+			`appbroker_cloud_controller_request_duration_seconds_count{operation="GET /v2/metrics_test_routes/:guid"} 1`))
+		Expect(exposition()).To(ContainSubstring(
+			`appbroker_cloud_controller_errors_total{operation="GET /v2/metrics_test_routes/:guid",code="404"} 1`))
+		Expect(exposition()).To(ContainSubstring(
+			`appbroker_cloud_controller_errors_total{operation="DELETE /v2/metrics_test_routes/:guid",code="transport"} 1`))
+	})
+})
@@ -0,0 +1,137 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cloud
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/signalfx/golib/errors"
+	"github.com/trustedanalytics/application-broker/service/extension"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+// cloneNames holds names of clones keyed by GUID of their source components
+type cloneNames struct {
+	apps     map[string]string
+	services map[string]string
+	upses    map[string]string
+}
+
+// nameClones renders names of all clones and checks them against Cloud Foundry limits.
+// User provided service linking an application is named <application name>-ups.
+func nameClones(naming *extension.Naming, sourceAppGUID string,
+	components map[types.ComponentType][]types.Component) (*cloneNames, error) {
+
+	names := &cloneNames{
+		apps:     make(map[string]string),
+		services: make(map[string]string),
+		upses:    make(map[string]string),
+	}
+
+	var err error
+	if names.apps[sourceAppGUID], err = naming.MainName(); err != nil {
+		return nil, err
+	}
+	for _, app := range components[types.ComponentApp] {
+		if _, ok := names.apps[app.GUID]; ok {
+			continue
+		}
+		if names.apps[app.GUID], err = naming.Name(app.Name); err != nil {
+			return nil, err
+		}
+	}
+	for _, comp := range components[types.ComponentService] {
+		if names.services[comp.GUID], err = naming.Name(comp.Name); err != nil {
+			return nil, err
+		}
+	}
+	for _, comp := range components[types.ComponentUPS] {
+		if linked := linkedApp(comp, components[types.ComponentApp]); len(linked) > 0 {
+			names.upses[comp.GUID] = fmt.Sprintf("%v-ups", names.apps[linked])
+		} else if names.upses[comp.GUID], err = naming.Name(comp.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	problems := []string{}
+	problems = append(problems, checkNames(names.apps, extension.MaxHostnameLength, "application")...)
+	serviceNames := make(map[string]string)
+	for guid, name := range names.services {
+		serviceNames[guid] = name
+	}
+	for guid, name := range names.upses {
+		serviceNames[guid] = name
+	}
+	problems = append(problems, checkNames(serviceNames, extension.MaxServiceInstanceNameLength, "service instance")...)
+	if len(problems) > 0 {
+		return nil, errors.Annotatef(types.InvalidInputError, "Invalid names of cloned components: %v",
+			strings.Join(problems, "; "))
+	}
+	return names, nil
+}
+
+func checkNames(names map[string]string, maxLength int, kind string) []string {
+	problems := []string{}
+	used := make(map[string]bool)
+	for _, name := range sortedValues(names) {
+		if len(name) > maxLength {
+			problems = append(problems, fmt.Sprintf("%v name %v is longer than %d characters", kind, name, maxLength))
+		}
+		if used[name] {
+			problems = append(problems, fmt.Sprintf("%v name %v is used more than once", kind, name))
+		}
+		used[name] = true
+	}
+	return problems
+}
+
+func sortedValues(m map[string]string) []string {
+	values := []string{}
+	for _, v := range m {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// resolveNamingLocation fills names of org and space the instance is created in, when template uses them
+func (cloud *CloudAPI) resolveNamingLocation(naming *extension.Naming, spaceGUID string) error {
+	needsOrg := len(naming.Org) == 0 && strings.Contains(naming.Template, ".Org")
+	needsSpace := len(naming.Space) == 0 && strings.Contains(naming.Template, ".Space")
+	if !needsOrg && !needsSpace {
+		return nil
+	}
+
+	space := new(types.CfSpaceResource)
+	address := fmt.Sprintf("%v/v2/spaces/%v", cloud.cf.BaseAddress, spaceGUID)
+	if err := cloud.getCfResource(address, "space", space); err != nil {
+		return err
+	}
+	naming.Space = space.Entity.Name
+	if !needsOrg {
+		return nil
+	}
+
+	org := new(cfOrgResource)
+	address = fmt.Sprintf("%v/v2/organizations/%v", cloud.cf.BaseAddress, space.Entity.OrgGUID)
+	if err := cloud.getCfResource(address, "organization", org); err != nil {
+		return err
+	}
+	naming.Org = org.Entity.Name
+	return nil
+}
@@ -0,0 +1,83 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cloud
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/signalfx/golib/errors"
+	"github.com/trustedanalytics/application-broker/service/extension"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+var _ = Describe("Naming clones", func() {
+
+	var (
+		naming     *extension.Naming
+		components map[types.ComponentType][]types.Component
+	)
+
+	BeforeEach(func() {
+		naming = &extension.Naming{Template: "{{.Space}}-{{.Component}}-{{.ID}}"}
+		naming.Instance, naming.Space, naming.ID = "stack", "dev", "abcd"
+		components = map[types.ComponentType][]types.Component{
+			types.ComponentApp: {
+				{GUID: "main", Name: "main"},
+				{GUID: "api", Name: "api", DependencyOf: []string{"main", "ups"}},
+			},
+			types.ComponentService: {{GUID: "db", Name: "db", DependencyOf: []string{"api"}}},
+			types.ComponentUPS:     {{GUID: "ups", Name: "api-ups", DependencyOf: []string{"main"}}},
+		}
+	})
+
+	It("should render template for every component", func() {
+		names, err := nameClones(naming, "main", components)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names.apps).To(Equal(map[string]string{"main": "dev-stack-abcd", "api": "dev-api-abcd"}))
+		Expect(names.services).To(Equal(map[string]string{"db": "dev-db-abcd"}))
+		Expect(names.upses).To(Equal(map[string]string{"ups": "dev-api-abcd-ups"}))
+	})
+
+	It("should keep instance name of main application when requested", func() {
+		naming.KeepMainName = true
+
+		names, err := nameClones(naming, "main", components)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names.apps["main"]).To(Equal("stack"))
+	})
+
+	It("should reject names exceeding Cloud Foundry limits", func() {
+		naming.Instance = strings.Repeat("x", 60)
+
+		_, err := nameClones(naming, "main", components)
+
+		Expect(errors.Tail(err)).To(Equal(types.InvalidInputError))
+		Expect(errors.Message(err)).To(ContainSubstring("is longer than 63 characters"))
+	})
+
+	It("should reject template giving the same name to different components", func() {
+		naming.Template = "{{.Instance}}-{{.ID}}"
+
+		_, err := nameClones(naming, "main", components)
+
+		Expect(errors.Tail(err)).To(Equal(types.InvalidInputError))
+		Expect(errors.Message(err)).To(ContainSubstring("application name stack-abcd is used more than once"))
+	})
+})
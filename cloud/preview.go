@@ -0,0 +1,195 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cloud
+
+import (
+	"fmt"
+
+	"github.com/cloudfoundry-community/types-cf"
+	"github.com/trustedanalytics/application-broker/logging"
+	"github.com/trustedanalytics/application-broker/service/extension"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+// Preview describes components Provision would create for the request.
+// Cloud Controller is only read to plan routes and rewrite credentials, nothing is created.
+func (cloud *CloudAPI) Preview(sourceAppGUID string,
+	servicesConfiguration []*extension.ServiceConfiguration,
+	naming *extension.Naming,
+	mainRoutes []*extension.Route,
+	r *cf.ServiceCreationRequest,
+	logger *logging.Logger) (*extension.ProvisioningPreview, error) {
+
+	order, err := cloud.Discovery(sourceAppGUID)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debugf("Discovery: [%v]", order)
+	if err := validateStack(sourceAppGUID, order); err != nil {
+		return nil, err
+	}
+	componentsToSpawn := cloud.groupComponentsByType(order)
+	shared := applySharingPolicies(componentsToSpawn, servicesConfiguration)
+
+	// Names of org and space are not looked up in Cloud Controller, GUIDs are shown unless context has them
+	if len(naming.Org) == 0 {
+		naming.Org = r.OrganizationGUID
+	}
+	if len(naming.Space) == 0 {
+		naming.Space = r.SpaceGUID
+	}
+	names, err := nameClones(naming, sourceAppGUID, componentsToSpawn)
+	if err != nil {
+		return nil, err
+	}
+	routes, sourceRoutes, err := cloud.planRoutes(sourceAppGUID, names, mainRoutes)
+	if err != nil {
+		return nil, err
+	}
+
+	paramsWithoutNS, err := cloud.removeParametersNamespaces(r.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string)
+	for k, v := range paramsWithoutNS {
+		if k != "name" {
+			env[k] = v
+		}
+	}
+
+	preview := &extension.ProvisioningPreview{
+		InstanceID:           r.InstanceID,
+		ServiceID:            r.ServiceID,
+		PlanID:               r.PlanID,
+		Apps:                 []extension.AppPreview{},
+		Services:             []extension.ServicePreview{},
+		UserProvidedServices: []extension.UserProvidedServicePreview{},
+		Bindings:             []extension.BindingPreview{},
+	}
+
+	preview.Apps = append(preview.Apps, extension.AppPreview{
+		SourceGUID: sourceAppGUID,
+		Name:       names.apps[sourceAppGUID],
+		Main:       true,
+		Env:        env,
+		Routes:     routeNames(routes[sourceAppGUID]),
+	})
+	for _, app := range componentsToSpawn[types.ComponentApp] {
+		if app.GUID == sourceAppGUID {
+			continue
+		}
+		preview.Apps = append(preview.Apps, extension.AppPreview{
+			SourceGUID: app.GUID,
+			Name:       names.apps[app.GUID],
+			Env:        env,
+			Routes:     routeNames(routes[app.GUID]),
+		})
+	}
+
+	for _, comp := range componentsToSpawn[types.ComponentService] {
+		svc := extension.ServicePreview{
+			SourceGUID: comp.GUID,
+			Name:       names.services[comp.GUID],
+			Parameters: cloud.selectAcceptedServiceParams(comp.Name, r.Parameters, servicesConfiguration),
+		}
+		preview.Services = append(preview.Services, svc)
+		preview.Bindings = append(preview.Bindings, previewBindings(svc.Name, comp, names.apps)...)
+	}
+	for _, comp := range shared {
+		svc := extension.ServicePreview{SourceGUID: comp.GUID, Name: comp.Name, Shared: true}
+		preview.Services = append(preview.Services, svc)
+		preview.Bindings = append(preview.Bindings, previewBindings(svc.Name, comp, names.apps)...)
+	}
+
+	linker := newCredentialLinker(sourceRoutes, routes)
+	linker.dryRun = true
+	for _, comp := range componentsToSpawn[types.ComponentUPS] {
+		ups := extension.UserProvidedServicePreview{
+			SourceGUID: comp.GUID,
+			Name:       names.upses[comp.GUID],
+		}
+		source, err := cloud.cf.GetUserProvidedService(comp.GUID)
+		if err != nil {
+			return nil, err
+		}
+		credentials := source.Entity.Credentials
+		if credentials == nil {
+			credentials = make(map[string]interface{})
+		}
+		// The same as CreateUserProvidedServiceClone does, except for placeholders generating secrets
+		if linked := linkedApp(comp, componentsToSpawn[types.ComponentApp]); len(linked) > 0 {
+			ups.LinkedApp = names.apps[linked]
+			credentials["url"] = fmt.Sprintf("http://%v", routes[linked][0])
+		}
+		linker.rewrite(ups.Name, credentials)
+		ups.Credentials = maskCredentials(credentials)
+		preview.UserProvidedServices = append(preview.UserProvidedServices, ups)
+		preview.Bindings = append(preview.Bindings, previewBindings(ups.Name, comp, names.apps)...)
+	}
+
+	return preview, nil
+}
+
+func routeNames(routes []plannedRoute) []string {
+	names := []string{}
+	for _, route := range routes {
+		names = append(names, route.String())
+	}
+	return names
+}
+
+// maskCredentials masks values of sensitive keys at any depth, the way they are masked in logs
+func maskCredentials(credentials map[string]interface{}) map[string]interface{} {
+	for key, value := range credentials {
+		if logging.IsSensitiveKey(key) {
+			credentials[key] = logging.Mask
+			continue
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			maskCredentials(v)
+		case []interface{}:
+			for _, item := range v {
+				if nested, ok := item.(map[string]interface{}); ok {
+					maskCredentials(nested)
+				}
+			}
+		}
+	}
+	return credentials
+}
+
+func previewBindings(serviceName string, comp types.Component, appNames map[string]string) []extension.BindingPreview {
+	bindings := []extension.BindingPreview{}
+	for _, dependent := range comp.DependencyOf {
+		bindings = append(bindings, extension.BindingPreview{Service: serviceName, App: appNames[dependent]})
+	}
+	return bindings
+}
+
+// linkedApp returns GUID of application that user provided service points to, i.e. the application being its dependency
+func linkedApp(ups types.Component, apps []types.Component) string {
+	linked := ""
+	for _, app := range apps {
+		for _, dep := range app.DependencyOf {
+			if ups.GUID == dep {
+				linked = app.GUID
+			}
+		}
+	}
+	return linked
+}
@@ -0,0 +1,130 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cloud
+
+import (
+	"net/http"
+
+	"github.com/cloudfoundry-community/types-cf"
+	"github.com/jarcoal/httpmock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/trustedanalytics/application-broker/client"
+	"github.com/trustedanalytics/application-broker/logging"
+	"github.com/trustedanalytics/application-broker/service/extension"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+var _ = Describe("Preview", func() {
+
+	var sut *CloudAPI
+
+	domain := types.CfDomain{GUID: "domain", Name: "apps.example.com"}
+
+	appSummary := func(appGUID, host string) {
+		summary := types.CfAppSummary{Routes: []types.CfAppSummaryRoute{{Host: host, Domain: domain}}}
+		httpmock.RegisterResponder("GET", "/v2/apps/"+appGUID+"/summary", responderGenerator(200, summary))
+	}
+
+	BeforeEach(func() {
+		httpmock.Activate()
+		sut = NewCloudAPIForClient("", &http.Client{})
+		sut.appDepDiscUps = &client.AppDependencyDiscovererUPS{Url: "http://discoverer"}
+		appSummary("main", "main")
+	})
+
+	AfterEach(func() {
+		httpmock.DeactivateAndReset()
+	})
+
+	It("should describe clones of all components with their routes and credentials", func() {
+		components := []types.Component{
+			{GUID: "main", Name: "main", Type: types.ComponentApp},
+			{GUID: "api", Name: "api", Type: types.ComponentApp, DependencyOf: []string{"main", "api-ups-guid"}},
+			{GUID: "db", Name: "db", Type: types.ComponentService, DependencyOf: []string{"api"}},
+			{GUID: "api-ups-guid", Name: "api-ups", Type: types.ComponentUPS, DependencyOf: []string{"main"}},
+		}
+		httpmock.RegisterResponder("GET", "http://discoverer/v1/discover/main", responderGenerator(200, components))
+		appSummary("api", "api")
+		ups := types.CfUserProvidedServiceResource{Entity: types.CfUserProvidedService{Credentials: map[string]interface{}{
+			"url":      "http://api.apps.example.com",
+			"callback": "https://main.apps.example.com/login",
+			"password": "$PASSWORD16",
+			"oauth":    map[string]interface{}{"client_secret": "s3cret", "host": "api.apps.example.com"},
+		}}}
+		httpmock.RegisterResponder("GET", "/v2/user_provided_service_instances/api-ups-guid", responderGenerator(200, ups))
+		request := &cf.ServiceCreationRequest{
+			InstanceID: "abcd-1234",
+			ServiceID:  "service",
+			PlanID:     "plan",
+			Parameters: map[string]string{"name": "my-stack", "db.size": "10", "LOG_LEVEL": "debug"},
+		}
+		naming := &extension.Naming{Template: extension.DefaultNamingTemplate}
+		naming.Instance, naming.ID = "my-stack", "abcd"
+		configuration := []*extension.ServiceConfiguration{{ServiceName: "db", Params: []string{"size"}}}
+		routes := []*extension.Route{{Hostname: "shop"}}
+
+		preview, err := sut.Preview("main", configuration, naming, routes, request, nil)
+
+		Expect(err).NotTo(HaveOccurred())
+		env := map[string]string{"size": "10", "LOG_LEVEL": "debug"}
+		Expect(preview.Apps).To(Equal([]extension.AppPreview{
+			{SourceGUID: "main", Name: "my-stack-abcd", Main: true, Env: env, Routes: []string{"shop.apps.example.com"}},
+			{SourceGUID: "api", Name: "api-abcd", Env: env, Routes: []string{"api-abcd.apps.example.com"}},
+		}))
+		Expect(preview.Services).To(Equal([]extension.ServicePreview{
+			{SourceGUID: "db", Name: "db-abcd", Parameters: map[string]interface{}{"size": "10"}},
+		}))
+		Expect(preview.UserProvidedServices).To(Equal([]extension.UserProvidedServicePreview{
+			{SourceGUID: "api-ups-guid", Name: "api-abcd-ups", LinkedApp: "api-abcd", Credentials: map[string]interface{}{
+				"url":      "http://api-abcd.apps.example.com",
+				"callback": "https://shop.apps.example.com/login",
+				"password": logging.Mask,
+				"oauth":    map[string]interface{}{"client_secret": logging.Mask, "host": "api-abcd.apps.example.com"},
+			}},
+		}))
+		Expect(preview.Bindings).To(Equal([]extension.BindingPreview{
+			{Service: "db-abcd", App: "api-abcd"},
+			{Service: "api-abcd-ups", App: "my-stack-abcd"},
+		}))
+	})
+
+	It("should bind apps to shared service instances and leave skipped ones out", func() {
+		components := []types.Component{
+			{GUID: "main", Name: "main", Type: types.ComponentApp},
+			{GUID: "kafka-guid", Name: "kafka", Type: types.ComponentService, DependencyOf: []string{"main"}},
+			{GUID: "auth-guid", Name: "auth", Type: types.ComponentService, DependencyOf: []string{"main"}},
+		}
+		httpmock.RegisterResponder("GET", "http://discoverer/v1/discover/main", responderGenerator(200, components))
+		request := &cf.ServiceCreationRequest{InstanceID: "abcd-1234", ServiceID: "service", PlanID: "plan"}
+		naming := &extension.Naming{Template: extension.DefaultNamingTemplate}
+		naming.Instance, naming.ID = "my-stack", "abcd"
+		configuration := []*extension.ServiceConfiguration{
+			{ServiceName: "kafka", Policy: extension.SharePolicy},
+			{ServiceName: "auth", Policy: extension.SkipPolicy},
+		}
+
+		preview, err := sut.Preview("main", configuration, naming, nil, request, nil)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(preview.Services).To(Equal([]extension.ServicePreview{
+			{SourceGUID: "kafka-guid", Name: "kafka", Shared: true},
+		}))
+		Expect(preview.Bindings).To(Equal([]extension.BindingPreview{
+			{Service: "kafka", App: "my-stack-abcd"},
+		}))
+	})
+})
@@ -0,0 +1,268 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cloud
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/cihub/seelog"
+	"github.com/signalfx/golib/errors"
+	"github.com/trustedanalytics/application-broker/service/extension"
+	"github.com/trustedanalytics/go-cf-lib/helpers"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+type cfRoute struct {
+	Host       string `json:"host"`
+	DomainGUID string `json:"domain_guid"`
+	SpaceGUID  string `json:"space_guid"`
+	Path       string `json:"path,omitempty"`
+}
+
+type cfRouteResource struct {
+	Meta   types.CfMeta `json:"metadata"`
+	Entity cfRoute      `json:"entity"`
+}
+
+type cfDomainResource struct {
+	Meta   types.CfMeta   `json:"metadata"`
+	Entity types.CfDomain `json:"entity"`
+}
+
+type cfDomainsResponse struct {
+	Count     int                `json:"total_results"`
+	Resources []cfDomainResource `json:"resources"`
+}
+
+// plannedRoute is a route to be created for cloned application
+type plannedRoute struct {
+	host   string
+	domain types.CfDomain
+	path   string
+}
+
+func (r plannedRoute) String() string {
+	return fmt.Sprintf("%v.%v%v", r.host, r.domain.Name, r.path)
+}
+
+// planRoutes resolves routes of cloned applications, keyed by GUID of source application.
+// Main application gets configured routes, others get single route in the domain of their source application.
+// Routes of source applications (host.domain) are returned as well, keyed the same way.
+func (cloud *CloudAPI) planRoutes(sourceAppGUID string, names *cloneNames,
+	mainRoutes []*extension.Route) (map[string][]plannedRoute, map[string][]string, error) {
+
+	planned := make(map[string][]plannedRoute)
+	sources := make(map[string][]string)
+	domains := make(map[string]types.CfDomain)
+	used := make(map[string]bool)
+	for appGUID, name := range names.apps {
+		summary, err := cloud.cf.GetAppSummary(appGUID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := cloud.cf.AssertAppHasRoutes(summary); err != nil {
+			return nil, nil, err
+		}
+		for _, route := range summary.Routes {
+			if len(route.Host) > 0 {
+				sources[appGUID] = append(sources[appGUID], fmt.Sprintf("%v.%v", route.Host, route.Domain.Name))
+			}
+		}
+
+		routes := []*extension.Route{{}}
+		if appGUID == sourceAppGUID && len(mainRoutes) > 0 {
+			routes = mainRoutes
+		}
+		for _, route := range routes {
+			toCreate := plannedRoute{host: route.Hostname, domain: summary.Routes[0].Domain, path: route.Path}
+			if len(toCreate.host) == 0 {
+				toCreate.host = name
+			}
+			if len(route.Domain) > 0 {
+				if _, ok := domains[route.Domain]; !ok {
+					if domains[route.Domain], err = cloud.findDomain(route.Domain); err != nil {
+						return nil, nil, err
+					}
+				}
+				toCreate.domain = domains[route.Domain]
+			}
+			if used[toCreate.String()] {
+				return nil, nil, errors.Annotatef(types.InvalidInputError, "Route %v is requested more than once", toCreate)
+			}
+			used[toCreate.String()] = true
+			planned[appGUID] = append(planned[appGUID], toCreate)
+		}
+	}
+	return planned, sources, nil
+}
+
+func (cloud *CloudAPI) findDomain(name string) (types.CfDomain, error) {
+	domains := new(cfDomainsResponse)
+	address := fmt.Sprintf("%v/v2/domains?q=%v", cloud.cf.BaseAddress, nameQuery(name))
+	if err := cloud.getCfResource(address, "domains", domains); err != nil {
+		return types.CfDomain{}, err
+	}
+	if len(domains.Resources) == 0 {
+		return types.CfDomain{}, errors.Annotatef(types.InvalidInputError, "Domain %v does not exist", name)
+	}
+	return types.CfDomain{GUID: domains.Resources[0].Meta.GUID, Name: domains.Resources[0].Entity.Name}, nil
+}
+
+// checkRoutesAvailable makes sure that none of planned routes already exists in the space
+func (cloud *CloudAPI) checkRoutesAvailable(planned map[string][]plannedRoute, spaceGUID string) error {
+	taken := []string{}
+	for _, routes := range planned {
+		for _, toCreate := range routes {
+			existing, err := cloud.cf.GetSpaceRoutesForHostname(spaceGUID, toCreate.host)
+			if err != nil {
+				return err
+			}
+			for _, route := range existing.Resources {
+				if route.Entity.DomainGUID != toCreate.domain.GUID {
+					continue
+				}
+				// Routes of go-cf-lib carry no path, so details are needed to tell path routes apart
+				details := new(cfRouteResource)
+				address := fmt.Sprintf("%v/v2/routes/%v", cloud.cf.BaseAddress, route.Meta.GUID)
+				if err := cloud.getCfResource(address, "route", details); err != nil {
+					return err
+				}
+				if details.Entity.Path == toCreate.path {
+					taken = append(taken, toCreate.String())
+				}
+			}
+		}
+	}
+	if len(taken) > 0 {
+		return errors.Annotatef(types.InvalidInputError, "Routes already exist: %v", strings.Join(taken, ", "))
+	}
+	return nil
+}
+
+// createApplicationClone creates stopped copy of application without routes
+func (cloud *CloudAPI) createApplicationClone(sourceAppGUID, spaceGUID string,
+	parameters map[string]string) (*types.CfAppResource, error) {
+
+	sourceAppSummary, err := cloud.cf.GetAppSummary(sourceAppGUID)
+	if err != nil {
+		return nil, err
+	}
+	requestedName := parameters["name"]
+	delete(parameters, "name")
+
+	destApp := types.NewCfAppResource(*sourceAppSummary, requestedName, spaceGUID)
+	if destApp.Entity.Envs == nil && len(parameters) > 0 {
+		destApp.Entity.Envs = map[string]interface{}{}
+	}
+	for k, v := range parameters {
+		log.Debugf("Setting additional env: %v:%v", k, v)
+		if _, ok := destApp.Entity.Envs[k]; ok {
+			log.Warnf("Env %v already exists (overriding)", k)
+		}
+		destApp.Entity.Envs[k] = v
+	}
+	return cloud.cf.CreateApp(destApp.Entity)
+}
+
+// createRoutes creates planned routes and maps them to the application, URL of the app is set to the first one
+func (cloud *CloudAPI) createRoutes(app *types.CfAppResource, routes []plannedRoute, spaceGUID string,
+	transaction *Transaction) error {
+
+	for i, toCreate := range routes {
+		route, err := cloud.createRoute(cfRoute{
+			Host:       toCreate.host,
+			DomainGUID: toCreate.domain.GUID,
+			SpaceGUID:  spaceGUID,
+			Path:       toCreate.path,
+		})
+		if err != nil {
+			return err
+		}
+		transaction.AddRoute(route.Meta.GUID)
+		if err := cloud.cf.AssociateRoute(app.Meta.GUID, route.Meta.GUID); err != nil {
+			return err
+		}
+		if i == 0 {
+			app.Meta.URL = toCreate.String()
+		}
+	}
+	return nil
+}
+
+func (cloud *CloudAPI) createRoute(route cfRoute) (*cfRouteResource, error) {
+	address := cloud.cf.BaseAddress + "/v2/routes"
+	log.Infof("Requesting route creation: %v", address)
+	marshalled, err := json.Marshal(route)
+	if err != nil {
+		return nil, errors.Annotate(types.InternalServerError, "Could not marshal route")
+	}
+	response, err := cloud.cf.Post(address, "application/json", bytes.NewReader(marshalled))
+	if err != nil {
+		msg := fmt.Sprintf("Could not create route: [%v]", err)
+		log.Error(msg)
+		return nil, errors.Annotate(types.InternalServerError, msg)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusCreated {
+		msg := fmt.Sprintf("Create route failed. Response from CC: (%d) [%v]",
+			response.StatusCode, helpers.ReaderToString(response.Body))
+		log.Error(msg)
+		return nil, errors.Annotate(types.InternalServerError, msg)
+	}
+
+	created := new(cfRouteResource)
+	if err := json.NewDecoder(response.Body).Decode(created); err != nil {
+		return nil, errors.Annotate(types.InternalServerError, "Could not decode created route")
+	}
+	return created, nil
+}
+
+// deleteCreatedRoutes unmaps routes created by the broker from applications and deletes them.
+// Routes mapped to the applications by someone else are left intact.
+func (cloud *CloudAPI) deleteCreatedRoutes(apps []types.Component, routeGUIDs []string) {
+	created := make(map[string]bool)
+	for _, guid := range routeGUIDs {
+		created[guid] = true
+	}
+
+	for _, app := range apps {
+		routes, err := cloud.cf.GetAppRoutes(app.GUID)
+		if err != nil {
+			if !cloud.isErrorAcceptedDuringDeprovision(err) {
+				log.Errorf("Error occured when getting routes of application %v: %v", app.GUID, err.Error())
+			}
+			continue
+		}
+		for _, route := range routes.Resources {
+			if !created[route.Meta.GUID] {
+				continue
+			}
+			if err := cloud.cf.UnassociateRoute(app.GUID, route.Meta.GUID); !cloud.isErrorAcceptedDuringDeprovision(err) {
+				log.Errorf("Error occured when unbinding route %v: %v", route.Meta.GUID, err.Error())
+			}
+		}
+	}
+
+	for _, guid := range routeGUIDs {
+		if err := cloud.cf.DeleteRoute(guid); !cloud.isErrorAcceptedDuringDeprovision(err) {
+			log.Errorf("Error occured when deleting route %v: %v", guid, err.Error())
+		}
+	}
+}
@@ -0,0 +1,138 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cloud
+
+import (
+	"net/http"
+
+	"github.com/jarcoal/httpmock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/signalfx/golib/errors"
+	"github.com/trustedanalytics/application-broker/service/extension"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+var _ = Describe("Routes", func() {
+
+	var (
+		sut     *CloudAPI
+		deleted []string
+	)
+
+	recordDelete := func(path string) {
+		httpmock.RegisterResponder("DELETE", path, func(req *http.Request) (*http.Response, error) {
+			deleted = append(deleted, path)
+			return httpmock.NewStringResponse(204, ""), nil
+		})
+	}
+
+	routesResponse := func(routes ...types.CfRouteResource) types.CfRoutesResponse {
+		return types.CfRoutesResponse{Count: len(routes), Resources: routes}
+	}
+
+	route := func(guid, host, domainGUID string) types.CfRouteResource {
+		return types.CfRouteResource{Meta: types.CfMeta{GUID: guid}, Entity: types.CfRoute{Host: host, DomainGUID: domainGUID}}
+	}
+
+	BeforeEach(func() {
+		httpmock.Activate()
+		deleted = []string{}
+		sut = NewCloudAPIForClient("", &http.Client{})
+	})
+
+	AfterEach(func() {
+		httpmock.DeactivateAndReset()
+	})
+
+	Describe("deleting routes on deprovision", func() {
+		It("should delete only routes created by the broker", func() {
+			httpmock.RegisterResponder("GET", "/v2/apps/app/routes",
+				responderGenerator(200, routesResponse(route("created", "app", "d"), route("foreign", "shared", "d"))))
+			recordDelete("/v2/apps/app/routes/created")
+			recordDelete("/v2/routes/created")
+			recordDelete("/v2/apps/app/routes/foreign")
+			recordDelete("/v2/routes/foreign")
+
+			sut.deleteCreatedRoutes([]types.Component{{GUID: "app"}}, []string{"created"})
+
+			Expect(deleted).To(Equal([]string{"/v2/apps/app/routes/created", "/v2/routes/created"}))
+		})
+	})
+
+	Describe("planning routes", func() {
+		var names *cloneNames
+
+		BeforeEach(func() {
+			names = &cloneNames{apps: map[string]string{"main": "stack-abcd"}}
+			summary := types.CfAppSummary{Routes: []types.CfAppSummaryRoute{
+				{Domain: types.CfDomain{GUID: "default-domain", Name: "apps.example.com"}},
+			}}
+			httpmock.RegisterResponder("GET", "/v2/apps/main/summary", responderGenerator(200, summary))
+		})
+
+		It("should use name of the app in domain of the source app by default", func() {
+			planned, _, err := sut.planRoutes("main", names, nil)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(planned["main"]).To(HaveLen(1))
+			Expect(planned["main"][0].String()).To(Equal("stack-abcd.apps.example.com"))
+		})
+
+		It("should resolve requested domain, hostname and path", func() {
+			domains := cfDomainsResponse{Count: 1, Resources: []cfDomainResource{
+				{Meta: types.CfMeta{GUID: "custom-domain"}, Entity: types.CfDomain{Name: "example.org"}},
+			}}
+			httpmock.RegisterResponder("GET", "/v2/domains?q=name%3Aexample.org", responderGenerator(200, domains))
+			routes := []*extension.Route{{Domain: "example.org", Hostname: "www", Path: "/shop"}, {}}
+
+			planned, _, err := sut.planRoutes("main", names, routes)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(planned["main"]).To(HaveLen(2))
+			Expect(planned["main"][0].domain.GUID).To(Equal("custom-domain"))
+			Expect(planned["main"][0].String()).To(Equal("www.example.org/shop"))
+			Expect(planned["main"][1].String()).To(Equal("stack-abcd.apps.example.com"))
+		})
+	})
+
+	Describe("checking availability of routes", func() {
+		planned := map[string][]plannedRoute{
+			"main": {{host: "stack", domain: types.CfDomain{GUID: "d", Name: "example.com"}}},
+		}
+
+		It("should refuse route existing in the space", func() {
+			httpmock.RegisterResponder("GET", "/v2/spaces/space/routes?q=host:stack",
+				responderGenerator(200, routesResponse(route("existing", "stack", "d"))))
+			httpmock.RegisterResponder("GET", "/v2/routes/existing",
+				responderGenerator(200, cfRouteResource{Entity: cfRoute{Host: "stack", DomainGUID: "d"}}))
+
+			err := sut.checkRoutesAvailable(planned, "space")
+
+			Expect(errors.Tail(err)).To(Equal(types.InvalidInputError))
+			Expect(errors.Message(err)).To(ContainSubstring("stack.example.com"))
+		})
+
+		It("should accept route differing in path", func() {
+			httpmock.RegisterResponder("GET", "/v2/spaces/space/routes?q=host:stack",
+				responderGenerator(200, routesResponse(route("existing", "stack", "d"))))
+			httpmock.RegisterResponder("GET", "/v2/routes/existing",
+				responderGenerator(200, cfRouteResource{Entity: cfRoute{Host: "stack", DomainGUID: "d", Path: "/api"}}))
+
+			Expect(sut.checkRoutesAvailable(planned, "space")).To(Succeed())
+		})
+	})
+})
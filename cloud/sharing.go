@@ -0,0 +1,60 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cloud
+
+import (
+	log "github.com/cihub/seelog"
+	"github.com/trustedanalytics/application-broker/service/extension"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+// applySharingPolicies leaves only cloned service instances among components to spawn.
+// Shared service instances are returned, skipped ones are dropped.
+func applySharingPolicies(components map[types.ComponentType][]types.Component,
+	servicesConfiguration []*extension.ServiceConfiguration) []types.Component {
+
+	cloned := []types.Component{}
+	shared := []types.Component{}
+	for _, comp := range components[types.ComponentService] {
+		switch extension.SharingPolicyOf(comp.Name, servicesConfiguration) {
+		case extension.SharePolicy:
+			log.Infof("Service instance %v is shared", comp.Name)
+			shared = append(shared, comp)
+		case extension.SkipPolicy:
+			log.Infof("Service instance %v is skipped", comp.Name)
+		default:
+			cloned = append(cloned, comp)
+		}
+	}
+	components[types.ComponentService] = cloned
+	return shared
+}
+
+// withoutComponents returns components except those of given GUIDs
+func withoutComponents(components []types.Component, guids []string) []types.Component {
+	excluded := make(map[string]bool)
+	for _, guid := range guids {
+		excluded[guid] = true
+	}
+	toReturn := []types.Component{}
+	for _, comp := range components {
+		if !excluded[comp.GUID] {
+			toReturn = append(toReturn, comp)
+		}
+	}
+	return toReturn
+}
@@ -0,0 +1,71 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cloud
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/trustedanalytics/application-broker/service/extension"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+var _ = Describe("Sharing policies", func() {
+
+	var components map[types.ComponentType][]types.Component
+
+	BeforeEach(func() {
+		components = map[types.ComponentType][]types.Component{
+			types.ComponentApp: {{GUID: "main", Name: "main", Type: types.ComponentApp}},
+			types.ComponentService: {
+				{GUID: "db", Name: "db", Type: types.ComponentService, DependencyOf: []string{"main"}},
+				{GUID: "kafka", Name: "kafka", Type: types.ComponentService, DependencyOf: []string{"main"}},
+				{GUID: "auth", Name: "auth", Type: types.ComponentService, DependencyOf: []string{"main"}},
+			},
+		}
+	})
+
+	It("should clone service instances without policy", func() {
+		shared := applySharingPolicies(components, []*extension.ServiceConfiguration{
+			{ServiceName: "db", Params: []string{"size"}},
+		})
+
+		Expect(shared).To(BeEmpty())
+		Expect(components[types.ComponentService]).To(HaveLen(3))
+	})
+
+	It("should return shared service instances and drop skipped ones", func() {
+		shared := applySharingPolicies(components, []*extension.ServiceConfiguration{
+			{ServiceName: "kafka", Policy: extension.SharePolicy},
+			{ServiceName: "auth", Policy: extension.SkipPolicy},
+			{ServiceName: "db", Policy: extension.ClonePolicy},
+		})
+
+		Expect(shared).To(HaveLen(1))
+		Expect(shared[0].GUID).To(Equal("kafka"))
+		Expect(components[types.ComponentService]).To(HaveLen(1))
+		Expect(components[types.ComponentService][0].GUID).To(Equal("db"))
+		Expect(components[types.ComponentApp]).To(HaveLen(1))
+	})
+
+	It("should leave shared service instances out of components to remove", func() {
+		order := []types.Component{{GUID: "main"}, {GUID: "db"}, {GUID: "kafka"}}
+
+		remaining := withoutComponents(order, []string{"kafka"})
+
+		Expect(remaining).To(Equal([]types.Component{{GUID: "main"}, {GUID: "db"}}))
+	})
+})
@@ -0,0 +1,173 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cloud
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/signalfx/golib/errors"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+// validateStack checks that discovered components form a DAG with the source application as its only root.
+// Edges go from a component to components listed in its DependencyOf. All problems found are listed in
+// the message of returned error, which annotates invalid input.
+func validateStack(rootGUID string, components []types.Component) error {
+	if len(components) == 0 {
+		return nil
+	}
+
+	index := make(map[string]types.Component)
+	for _, comp := range components {
+		index[comp.GUID] = comp
+	}
+
+	problems := []string{}
+	roots := []string{}
+	for _, comp := range components {
+		unknown := []string{}
+		for _, dependent := range comp.DependencyOf {
+			if _, ok := index[dependent]; !ok {
+				unknown = append(unknown, dependent)
+			}
+		}
+		if len(unknown) > 0 {
+			problems = append(problems, fmt.Sprintf("%v is dependency of unknown components %v",
+				componentLabel(comp), strings.Join(unknown, ", ")))
+		}
+		if len(comp.DependencyOf) == 0 {
+			roots = append(roots, componentLabel(comp))
+		}
+	}
+
+	if _, ok := index[rootGUID]; !ok {
+		problems = append(problems, fmt.Sprintf("source application %v is not among discovered components", rootGUID))
+	}
+	if root, ok := index[rootGUID]; ok && len(root.DependencyOf) > 0 {
+		problems = append(problems, fmt.Sprintf("source application %v is dependency of other components",
+			componentLabel(root)))
+	}
+	if len(roots) > 1 {
+		problems = append(problems, fmt.Sprintf("multiple roots: %v", strings.Join(roots, ", ")))
+	}
+
+	if cycle := findCycle(components, index); len(cycle) > 0 {
+		problems = append(problems, fmt.Sprintf("cycle: %v", strings.Join(cycle, " -> ")))
+	}
+
+	if _, ok := index[rootGUID]; ok {
+		reachable := reachableDependencies(rootGUID, components)
+		unreachable := []string{}
+		for _, comp := range components {
+			if !reachable[comp.GUID] {
+				unreachable = append(unreachable, componentLabel(comp))
+			}
+		}
+		if len(unreachable) > 0 {
+			problems = append(problems, fmt.Sprintf("not reachable from source application: %v",
+				strings.Join(unreachable, ", ")))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.Annotatef(types.InvalidInputError, "Application stack of %v is invalid: %v",
+			rootGUID, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// findCycle returns labels of components forming the first cycle found, the first component is repeated at the end
+func findCycle(components []types.Component, index map[string]types.Component) []string {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	path := []types.Component{}
+
+	var visit func(comp types.Component) []string
+	visit = func(comp types.Component) []string {
+		state[comp.GUID] = visiting
+		path = append(path, comp)
+		for _, dependent := range comp.DependencyOf {
+			next, ok := index[dependent]
+			if !ok {
+				continue
+			}
+			switch state[dependent] {
+			case visiting:
+				cycle := []string{}
+				for i := len(path) - 1; i >= 0; i-- {
+					if path[i].GUID == dependent {
+						for _, c := range path[i:] {
+							cycle = append(cycle, componentLabel(c))
+						}
+						break
+					}
+				}
+				return append(cycle, componentLabel(next))
+			case 0:
+				if cycle := visit(next); len(cycle) > 0 {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[comp.GUID] = visited
+		return nil
+	}
+
+	for _, comp := range components {
+		if state[comp.GUID] == 0 {
+			if cycle := visit(comp); len(cycle) > 0 {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// reachableDependencies returns GUIDs of root and all components it transitively depends on
+func reachableDependencies(rootGUID string, components []types.Component) map[string]bool {
+	dependencies := make(map[string][]string)
+	for _, comp := range components {
+		for _, dependent := range comp.DependencyOf {
+			dependencies[dependent] = append(dependencies[dependent], comp.GUID)
+		}
+	}
+
+	reachable := map[string]bool{rootGUID: true}
+	queue := []string{rootGUID}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, dependency := range dependencies[current] {
+			if !reachable[dependency] {
+				reachable[dependency] = true
+				queue = append(queue, dependency)
+			}
+		}
+	}
+	return reachable
+}
+
+func componentLabel(comp types.Component) string {
+	if comp.Name == "" {
+		return comp.GUID
+	}
+	return fmt.Sprintf("%v (%v)", comp.Name, comp.GUID)
+}
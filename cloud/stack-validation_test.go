@@ -0,0 +1,96 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cloud
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/signalfx/golib/errors"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+var _ = Describe("Stack validation", func() {
+
+	Context("when stack is a DAG with single root", func() {
+		It("should accept it", func() {
+			components := []types.Component{
+				{GUID: "main", Name: "main"},
+				{GUID: "api", Name: "api", DependencyOf: []string{"main"}},
+				{GUID: "db", Name: "db", DependencyOf: []string{"api", "main"}},
+			}
+
+			Expect(validateStack("main", components)).To(Succeed())
+		})
+	})
+
+	Context("when stack contains a cycle", func() {
+		It("should name components of the cycle", func() {
+			components := []types.Component{
+				{GUID: "main", Name: "main"},
+				{GUID: "a", Name: "a", DependencyOf: []string{"main", "b"}},
+				{GUID: "b", Name: "b", DependencyOf: []string{"a"}},
+			}
+
+			err := validateStack("main", components)
+
+			Expect(errors.Tail(err)).To(Equal(types.InvalidInputError))
+			Expect(errors.Message(err)).To(ContainSubstring("cycle: a (a) -> b (b) -> a (a)"))
+		})
+	})
+
+	Context("when stack has a second root", func() {
+		It("should name roots and unreachable components", func() {
+			components := []types.Component{
+				{GUID: "main", Name: "main"},
+				{GUID: "other", Name: "other"},
+				{GUID: "db", Name: "db", DependencyOf: []string{"other"}},
+			}
+
+			err := validateStack("main", components)
+
+			Expect(errors.Tail(err)).To(Equal(types.InvalidInputError))
+			Expect(errors.Message(err)).To(ContainSubstring("multiple roots: main (main), other (other)"))
+			Expect(errors.Message(err)).To(ContainSubstring("not reachable from source application: other (other), db (db)"))
+		})
+	})
+
+	Context("when component is dependency of unknown component", func() {
+		It("should name the dangling reference", func() {
+			components := []types.Component{
+				{GUID: "main", Name: "main"},
+				{GUID: "db", Name: "db", DependencyOf: []string{"main", "ghost"}},
+			}
+
+			err := validateStack("main", components)
+
+			Expect(errors.Tail(err)).To(Equal(types.InvalidInputError))
+			Expect(errors.Message(err)).To(ContainSubstring("db (db) is dependency of unknown components ghost"))
+		})
+	})
+
+	Context("when source application is not discovered", func() {
+		It("should name the missing root", func() {
+			components := []types.Component{
+				{GUID: "api", Name: "api"},
+			}
+
+			err := validateStack("main", components)
+
+			Expect(errors.Tail(err)).To(Equal(types.InvalidInputError))
+			Expect(errors.Message(err)).To(ContainSubstring("source application main is not among discovered components"))
+		})
+	})
+})
@@ -0,0 +1,121 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cloud
+
+import (
+	log "github.com/cihub/seelog"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+type startResult struct {
+	comp types.Component
+	err  error
+}
+
+// startInDependencyOrder calls start for every component once all components it is a dependency of
+// (listed in their DependencyOf) are started. Start is expected to block until component is running.
+// Independent components are started in parallel. After the first failure no more components are started,
+// components already being started are awaited and the error is returned.
+// Components forming a cycle are started one by one in discovery order, before components depending on them. Provisioning refuses such stacks,
+// but stacks of running instances are discovered again, without validation, when instances are started.
+func startInDependencyOrder(components []types.Component, start func(types.Component) error) error {
+	known := make(map[string]bool)
+	for _, comp := range components {
+		known[comp.GUID] = true
+	}
+	isDependency := func(comp types.Component, dependent string) bool {
+		return known[dependent] && dependent != comp.GUID
+	}
+
+	pending := make(map[string]int)
+	dependencies := make(map[string][]string)
+	for _, comp := range components {
+		for _, dependent := range comp.DependencyOf {
+			if isDependency(comp, dependent) {
+				pending[dependent]++
+				dependencies[dependent] = append(dependencies[dependent], comp.GUID)
+			}
+		}
+	}
+
+	results := make(chan startResult, len(components))
+	launched := make(map[string]bool)
+	running := 0
+	launch := func(comp types.Component) {
+		launched[comp.GUID] = true
+		running++
+		go func() {
+			results <- startResult{comp: comp, err: start(comp)}
+		}()
+	}
+	// inCycle tells if component waits for itself through dependencies which are not launched yet
+	inCycle := func(guid string) bool {
+		visited := make(map[string]bool)
+		waiting := dependencies[guid]
+		for len(waiting) > 0 {
+			next := waiting[0]
+			waiting = waiting[1:]
+			if next == guid {
+				return true
+			}
+			if launched[next] || visited[next] {
+				continue
+			}
+			visited[next] = true
+			waiting = append(waiting, dependencies[next]...)
+		}
+		return false
+	}
+	launchReady := func() {
+		for _, comp := range components {
+			if !launched[comp.GUID] && pending[comp.GUID] == 0 {
+				launch(comp)
+			}
+		}
+		if running > 0 {
+			return
+		}
+		for _, comp := range components {
+			if !launched[comp.GUID] && inCycle(comp.GUID) {
+				log.Warnf("Applications depend on each other in a cycle, starting %v", comp.Name)
+				launch(comp)
+				return
+			}
+		}
+	}
+
+	var firstErr error
+	launchReady()
+	for running > 0 {
+		res := <-results
+		running--
+		if res.err != nil {
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			continue
+		}
+		for _, dependent := range res.comp.DependencyOf {
+			if isDependency(res.comp, dependent) {
+				pending[dependent]--
+			}
+		}
+		if firstErr == nil {
+			launchReady()
+		}
+	}
+	return firstErr
+}
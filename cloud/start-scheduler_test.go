@@ -0,0 +1,137 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cloud
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+var _ = Describe("Start scheduler", func() {
+	var (
+		mutex   sync.Mutex
+		started []string
+	)
+
+	record := func(comp types.Component) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		started = append(started, comp.GUID)
+		return nil
+	}
+
+	BeforeEach(func() {
+		started = []string{}
+	})
+
+	Context("when applications depend on each other", func() {
+		It("should start dependencies first", func() {
+			components := []types.Component{
+				{GUID: "main"},
+				{GUID: "api", DependencyOf: []string{"main"}},
+				{GUID: "db-proxy", DependencyOf: []string{"api", "main"}},
+			}
+
+			err := startInDependencyOrder(components, record)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(started).To(Equal([]string{"db-proxy", "api", "main"}))
+		})
+	})
+
+	Context("when applications are independent", func() {
+		It("should start them in parallel", func() {
+			components := []types.Component{
+				{GUID: "main"},
+				{GUID: "a", DependencyOf: []string{"main"}},
+				{GUID: "b", DependencyOf: []string{"main"}},
+			}
+			inProgress := sync.WaitGroup{}
+			inProgress.Add(2)
+
+			err := startInDependencyOrder(components, func(comp types.Component) error {
+				if comp.GUID != "main" {
+					inProgress.Done()
+					inProgress.Wait()
+				}
+				return record(comp)
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(started).To(ConsistOf("a", "b", "main"))
+			Expect(started[2]).To(Equal("main"))
+		})
+	})
+
+	Context("when application fails to start", func() {
+		It("should not start its dependents and return the error", func() {
+			components := []types.Component{
+				{GUID: "main"},
+				{GUID: "broken", DependencyOf: []string{"main"}},
+				{GUID: "slow"},
+			}
+			failure := errors.New("start failed")
+
+			err := startInDependencyOrder(components, func(comp types.Component) error {
+				switch comp.GUID {
+				case "broken":
+					return failure
+				case "slow":
+					time.Sleep(10 * time.Millisecond)
+				}
+				return record(comp)
+			})
+
+			Expect(err).To(Equal(failure))
+			Expect(started).To(Equal([]string{"slow"}))
+		})
+	})
+
+	Context("when applications form a cycle", func() {
+		It("should still start all of them", func() {
+			components := []types.Component{
+				{GUID: "a", DependencyOf: []string{"b"}},
+				{GUID: "b", DependencyOf: []string{"a"}},
+				{GUID: "self", DependencyOf: []string{"self"}},
+			}
+
+			err := startInDependencyOrder(components, record)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(started).To(ConsistOf("a", "b", "self"))
+		})
+
+		It("should start their dependencies first and their dependents after them", func() {
+			components := []types.Component{
+				{GUID: "main"},
+				{GUID: "a", DependencyOf: []string{"b", "main"}},
+				{GUID: "b", DependencyOf: []string{"a"}},
+				{GUID: "db-proxy", DependencyOf: []string{"a"}},
+			}
+
+			err := startInDependencyOrder(components, record)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(started).To(ConsistOf("db-proxy", "a", "b", "main"))
+			Expect(started[:2]).To(Equal([]string{"db-proxy", "a"}))
+		})
+	})
+})
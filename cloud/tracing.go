@@ -0,0 +1,108 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cloud
+
+import (
+	"fmt"
+	"github.com/trustedanalytics/application-broker/logging"
+	"github.com/trustedanalytics/application-broker/tracing"
+	"net/http"
+	"strconv"
+)
+
+// tracedTransport records Cloud Controller calls as client spans and propagates trace context to Cloud Controller
+type tracedTransport struct {
+	span *tracing.Span
+	next http.RoundTripper
+}
+
+func (t *tracedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	span := tracing.Start(t.span, ccOperation(req), tracing.ClientKind)
+	span.SetAttribute("http.method", req.Method)
+
+	// Transport must not modify the request it was given
+	traced := new(http.Request)
+	*traced = *req
+	traced.Header = make(http.Header, len(req.Header)+1)
+	for key, values := range req.Header {
+		traced.Header[key] = values
+	}
+	traced.Header.Set(tracing.TraceParentHeader, span.TraceParent())
+
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	response, err := next.RoundTrip(traced)
+	if err != nil {
+		span.End(err)
+		return response, err
+	}
+	span.SetAttribute("http.status_code", strconv.Itoa(response.StatusCode))
+	if response.StatusCode >= http.StatusBadRequest {
+		span.End(fmt.Errorf("Cloud Controller responded with status %v", response.StatusCode))
+	} else {
+		span.End(nil)
+	}
+	return response, nil
+}
+
+// traced returns CloudAPI whose Cloud Controller calls are traced as children of span
+func (cloud *CloudAPI) traced(span *tracing.Span) *CloudAPI {
+	if span == nil || cloud.cf == nil || cloud.cf.Client == nil {
+		return cloud
+	}
+	client := *cloud.cf.Client
+	next := client.Transport
+	if alreadyTraced, ok := next.(*tracedTransport); ok {
+		next = alreadyTraced.next
+	}
+	client.Transport = &tracedTransport{span: span, next: next}
+	cf := *cloud.cf
+	cf.Client = &client
+	traced := *cloud
+	traced.cf = &cf
+	return &traced
+}
+
+// stepTracer traces consecutive steps of an operation as children of its span
+type stepTracer struct {
+	parent *tracing.Span
+	span   *tracing.Span
+}
+
+func newStepTracer(logger *logging.Logger) *stepTracer {
+	return &stepTracer{parent: logger.Span()}
+}
+
+// start ends previous step and starts the next one. Returned CloudAPI is meant for the work done within the step.
+func (t *stepTracer) start(cloud *CloudAPI, name string) *CloudAPI {
+	t.span.End(nil)
+	t.span = tracing.Start(t.parent, name, tracing.InternalKind)
+	return cloud.traced(t.span)
+}
+
+// startParallel starts step running along the following ones, caller has to end its span
+func (t *stepTracer) startParallel(cloud *CloudAPI, name string) (*CloudAPI, *tracing.Span) {
+	span := tracing.Start(t.parent, name, tracing.InternalKind)
+	return cloud.traced(span), span
+}
+
+// end finishes current step, marking it failed when err is not nil
+func (t *stepTracer) end(err error) {
+	t.span.End(err)
+}
@@ -0,0 +1,100 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cloud
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/trustedanalytics/application-broker/tracing"
+	"net/http"
+)
+
+var _ = Describe("Tracing", func() {
+
+	var (
+		exporter *tracing.ExporterMock
+		sent     *http.Request
+		sut      *CloudAPI
+	)
+
+	BeforeEach(func() {
+		exporter = &tracing.ExporterMock{}
+		tracing.SetExporter(exporter)
+		sut = NewCloudAPIForClient("http://cc", &http.Client{Transport: roundTripperFunc(
+			func(req *http.Request) (*http.Response, error) {
+				sent = req
+				return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody}, nil
+			})})
+	})
+
+	AfterEach(func() {
+		tracing.SetExporter(nil)
+	})
+
+	It("should trace Cloud Controller calls as children of the step", func() {
+		step := tracing.Start(nil, "create_applications", tracing.InternalKind)
+		req, _ := http.NewRequest("GET", "http://cc/v2/apps/5c2b3e8a-1d4f-4a6b-9e2d-7f8a9b0c1d2e/summary", nil)
+
+		sut.traced(step).cf.Do(req)
+
+		span, found := exporter.Find("GET /v2/apps/:guid/summary")
+		Expect(found).To(BeTrue())
+		Expect(span.TraceID).To(Equal(step.TraceID()))
+		Expect(span.ParentSpanID).To(Equal(step.SpanID()))
+		Expect(span.Kind).To(Equal(tracing.ClientKind))
+		Expect(span.Attributes["http.status_code"]).To(Equal("404"))
+		Expect(span.Error).To(ContainSubstring("404"))
+		Expect(sent.Header.Get(tracing.TraceParentHeader)).To(Equal("00-" + step.TraceID() + "-" + span.SpanID + "-01"))
+		Expect(req.Header.Get(tracing.TraceParentHeader)).To(BeEmpty())
+	})
+
+	It("should trace calls once when traced API is traced again", func() {
+		first := tracing.Start(nil, "plan", tracing.InternalKind)
+		second := tracing.Start(nil, "bind_services", tracing.InternalKind)
+		req, _ := http.NewRequest("PUT", "http://cc/v2/service_bindings", nil)
+
+		sut.traced(first).traced(second).cf.Do(req)
+
+		spans := exporter.Spans()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].ParentSpanID).To(Equal(second.SpanID()))
+	})
+
+	It("should not trace when operation has no span", func() {
+		Expect(sut.traced(nil) == sut).To(BeTrue())
+	})
+
+	It("should trace consecutive steps under the operation span", func() {
+		operation := tracing.Start(nil, "provision", tracing.ServerKind)
+		steps := &stepTracer{parent: operation}
+
+		steps.start(sut, "discovery")
+		_, copySpan := steps.startParallel(sut, "copy_bits")
+		steps.start(sut, "plan")
+		copySpan.End(nil)
+		steps.end(http.ErrHandlerTimeout)
+
+		discovery, _ := exporter.Find("discovery")
+		copyBits, _ := exporter.Find("copy_bits")
+		plan, _ := exporter.Find("plan")
+		Expect(discovery.ParentSpanID).To(Equal(operation.SpanID()))
+		Expect(discovery.Error).To(BeEmpty())
+		Expect(copyBits.ParentSpanID).To(Equal(operation.SpanID()))
+		Expect(plan.ParentSpanID).To(Equal(operation.SpanID()))
+		Expect(plan.Error).To(Equal(http.ErrHandlerTimeout.Error()))
+	})
+})
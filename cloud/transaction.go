@@ -17,17 +17,22 @@
 package cloud
 
 import (
-	log "github.com/cihub/seelog"
+	"github.com/trustedanalytics/application-broker/logging"
+	"github.com/trustedanalytics/application-broker/metrics"
 	"github.com/trustedanalytics/go-cf-lib/types"
 )
 
 type Transaction struct {
 	components []types.Component
+	routes     []string
+	logger     *logging.Logger
 }
 
-func NewTransaction() *Transaction {
+func NewTransaction(logger *logging.Logger) *Transaction {
 	tr := Transaction{
 		components: make([]types.Component, 0),
+		routes:     make([]string, 0),
+		logger:     logger,
 	}
 	return &tr
 }
@@ -52,7 +57,19 @@ func (t *Transaction) AddComponentClone(clone *types.ComponentClone) {
 	}
 }
 
+func (t *Transaction) AddRoute(routeGUID string) {
+	t.routes = append(t.routes, routeGUID)
+}
+
+// Routes returns GUIDs of routes created within the transaction
+func (t *Transaction) Routes() []string {
+	return t.routes
+}
+
 func (t *Transaction) Rollback(cloud *CloudAPI) {
-	log.Errorf("Aborting transaction. Deprovisioning already spawned components")
-	cloud.deprovisionComponents(t.components)
+	t.logger.Errorf("Aborting transaction. Deprovisioning already spawned components")
+	metrics.Rollbacks.Inc()
+	span, logger := t.logger.StartSpan("rollback")
+	cloud.traced(span).deprovisionComponents(t.components, t.routes, logger)
+	span.End(nil)
 }
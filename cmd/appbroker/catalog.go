@@ -0,0 +1,264 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry-community/types-cf"
+	"github.com/trustedanalytics/application-broker/misc"
+	"github.com/trustedanalytics/application-broker/service/extension"
+)
+
+func catalogList(ctx *cliContext, args []string) error {
+	if _, err := parseFlags(newFlagSet("catalog list"), args); err != nil {
+		return err
+	}
+	catalog, err := ctx.broker.getCatalog()
+	if err != nil {
+		return err
+	}
+	rows := [][]string{}
+	for _, svc := range catalog.Services {
+		rows = append(rows, []string{svc.ID, svc.Name, strconv.Itoa(len(svc.Plans)), svc.ReferenceApp.Meta.GUID})
+	}
+	return ctx.printTable(catalog.Services, []string{"ID", "NAME", "PLANS", "APP GUID"}, rows)
+}
+
+// serviceFlags are shared by catalog add and catalog update
+type serviceFlags struct {
+	name, description, displayName, image string
+	app, org, space, appGUID              string
+	config, tags, namingTemplate          string
+}
+
+func newServiceFlags(flags *flag.FlagSet) *serviceFlags {
+	f := new(serviceFlags)
+	flags.StringVar(&f.name, "name", "", "name of the service offering")
+	flags.StringVar(&f.description, "description", "", "explanation of what the service provides")
+	flags.StringVar(&f.displayName, "display-name", "", "name visible in the marketplace (defaults to -name)")
+	flags.StringVar(&f.image, "image", "", "path to icon placed in the marketplace or data URI")
+	flags.StringVar(&f.app, "app", "", "name of reference app")
+	flags.StringVar(&f.org, "org", "", "org of reference app (defaults to org targeted with cf CLI)")
+	flags.StringVar(&f.space, "space", "", "space of reference app (defaults to space targeted with cf CLI)")
+	flags.StringVar(&f.appGUID, "app-guid", "", "GUID of reference app, used instead of -app")
+	flags.StringVar(&f.config, "config", "", "path to JSON array with parameters accepted by stack components")
+	flags.StringVar(&f.tags, "tags", "", "comma separated list of tags")
+	flags.StringVar(&f.namingTemplate, "naming-template", "", "template of names of cloned components, e.g. {{.Component}}-{{.ID}}")
+	return f
+}
+
+// apply copies flags explicitly set by user to the service
+func (f *serviceFlags) apply(svc *extension.ServiceExtension, set map[string]bool) error {
+	if svc.Metadata == nil {
+		svc.Metadata = new(cf.ServiceMeta)
+	}
+	if set["name"] {
+		svc.Name = f.name
+	}
+	if set["description"] {
+		svc.Description = f.description
+	}
+	if set["display-name"] {
+		svc.Metadata.DisplayName = f.displayName
+	}
+	if set["image"] {
+		image, err := readIcon(f.image)
+		if err != nil {
+			return fmt.Errorf("cannot read icon %v: %v", f.image, err)
+		}
+		svc.Metadata.ImageURL = image
+	}
+	if set["tags"] {
+		svc.Tags = splitList(f.tags)
+	}
+	if set["naming-template"] {
+		svc.NamingTemplate = f.namingTemplate
+	}
+	if set["config"] {
+		raw, err := readFileOrStdin(f.config)
+		if err != nil {
+			return err
+		}
+		svc.Configuration = []*extension.ServiceConfiguration{}
+		if err := json.Unmarshal(raw, &svc.Configuration); err != nil {
+			return fmt.Errorf("cannot parse configuration %v: %v", f.config, err)
+		}
+	}
+
+	switch {
+	case set["app-guid"]:
+		svc.ReferenceApp.Meta.GUID = f.appGUID
+	case set["app"]:
+		guid, err := resolveAppGUID(extension.AppReference{Org: f.org, Space: f.space, Name: f.app})
+		if err != nil {
+			return err
+		}
+		svc.ReferenceApp.Meta.GUID = guid
+	case set["org"] || set["space"]:
+		return fmt.Errorf("-org and -space require -app")
+	}
+	return nil
+}
+
+func catalogAdd(ctx *cliContext, args []string) error {
+	flags := newFlagSet("catalog add")
+	f := newServiceFlags(flags)
+	set, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if err := requireFlags(flags, set, "name", "description"); err != nil {
+		return err
+	}
+	if !set["app"] && !set["app-guid"] {
+		return fmt.Errorf("either -app or -app-guid is required in %v", flags.Name())
+	}
+	if !set["display-name"] {
+		f.displayName = f.name
+		set["display-name"] = true
+	}
+
+	svc := extension.NewAutogeneratedService()
+	if err := f.apply(svc, set); err != nil {
+		return err
+	}
+	added, err := ctx.broker.addService(svc)
+	if err != nil {
+		return err
+	}
+	return ctx.printResult(added, "Service %v added to the catalog with id %v", added.Name, added.ID)
+}
+
+func catalogUpdate(ctx *cliContext, args []string) error {
+	flags := newFlagSet("catalog update")
+	service := flags.String("service", "", "id or name of the service offering to update")
+	f := newServiceFlags(flags)
+	set, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if err := requireFlags(flags, set, "service"); err != nil {
+		return err
+	}
+
+	svc, err := ctx.broker.findService(*service)
+	if err != nil {
+		return err
+	}
+	if err := f.apply(svc, set); err != nil {
+		return err
+	}
+	updated, err := ctx.broker.updateService(svc)
+	if err != nil {
+		return err
+	}
+	return ctx.printResult(updated, "Service %v updated", updated.Name)
+}
+
+func catalogRemove(ctx *cliContext, args []string) error {
+	flags := newFlagSet("catalog remove")
+	service := flags.String("service", "", "id or name of the service offering to remove")
+	set, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if err := requireFlags(flags, set, "service"); err != nil {
+		return err
+	}
+
+	svc, err := ctx.broker.findService(*service)
+	if err != nil {
+		return err
+	}
+	if err := ctx.broker.removeService(svc.ID); err != nil {
+		return err
+	}
+	return ctx.printResult(map[string]string{"id": svc.ID, "name": svc.Name},
+		"Service %v removed from the catalog", svc.Name)
+}
+
+func catalogExport(ctx *cliContext, args []string) error {
+	flags := newFlagSet("catalog export")
+	format := flags.String("format", misc.FormatYAML, "format of exported document: yaml or json")
+	output := flags.String("o", "", "file to write the document to (defaults to standard output)")
+	if _, err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if ctx.json {
+		*format = misc.FormatJSON
+	}
+
+	doc, err := ctx.broker.exportCatalog(*format)
+	if err != nil {
+		return err
+	}
+	if len(*output) > 0 {
+		return ioutil.WriteFile(*output, doc, 0644)
+	}
+	_, err = ctx.out.Write(doc)
+	return err
+}
+
+func catalogImport(ctx *cliContext, args []string) error {
+	flags := newFlagSet("catalog import")
+	file := flags.String("f", "", "file holding exported catalog, - for standard input")
+	format := flags.String("format", "", "format of the document: yaml or json (defaults to one implied by file extension)")
+	mode := flags.String("mode", string(extension.ImportMerge), "import mode: merge or replace")
+	dryRun := flags.Bool("dry-run", false, "only report changes that would be made")
+	set, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if err := requireFlags(flags, set, "f"); err != nil {
+		return err
+	}
+	if len(*format) == 0 {
+		*format = misc.FileFormat(*file)
+	}
+
+	doc, err := readFileOrStdin(*file)
+	if err != nil {
+		return err
+	}
+	report, err := ctx.broker.importCatalog(doc, *format, *mode, *dryRun)
+	if err != nil {
+		return err
+	}
+	if ctx.json {
+		return ctx.printJSON(report)
+	}
+	if report.DryRun {
+		fmt.Fprintln(ctx.out, "Dry run, no changes made.")
+	}
+	fmt.Fprintf(ctx.out, "Inserted:  %v\n", strings.Join(report.Inserted, ", "))
+	fmt.Fprintf(ctx.out, "Updated:   %v\n", strings.Join(report.Updated, ", "))
+	fmt.Fprintf(ctx.out, "Deleted:   %v\n", strings.Join(report.Deleted, ", "))
+	fmt.Fprintf(ctx.out, "Unchanged: %v\n", strings.Join(report.Unchanged, ", "))
+	for _, importErr := range report.Errors {
+		fmt.Fprintf(ctx.out, "Error in %v: %v\n", importErr.Service, importErr.Error)
+	}
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("%d service(s) not imported", len(report.Errors))
+	}
+	return nil
+}
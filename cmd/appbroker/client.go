@@ -0,0 +1,194 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/cloudfoundry-community/types-cf"
+	"github.com/trustedanalytics/application-broker/service/extension"
+)
+
+// brokerClient talks to catalog and instances API of the Application Broker
+type brokerClient struct {
+	address  string
+	user     string
+	password string
+	client   *http.Client
+}
+
+// newBrokerClient prefixes address without scheme with http://, as broker serves HTTP unless TLS is configured
+func newBrokerClient(address, user, password string) *brokerClient {
+	if !strings.HasPrefix(address, "http://") && !strings.HasPrefix(address, "https://") {
+		address = "http://" + address
+	}
+	return &brokerClient{
+		address:  strings.TrimRight(address, "/"),
+		user:     user,
+		password: password,
+		client:   http.DefaultClient,
+	}
+}
+
+func (c *brokerClient) getCatalog() (*extension.CatalogExtension, error) {
+	catalog := new(extension.CatalogExtension)
+	_, err := c.doJSON("GET", "/v2/catalog", nil, catalog)
+	return catalog, err
+}
+
+// findService looks for service offering by its id or name
+func (c *brokerClient) findService(idOrName string) (*extension.ServiceExtension, error) {
+	catalog, err := c.getCatalog()
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range catalog.Services {
+		if svc.ID == idOrName || svc.Name == idOrName {
+			return svc, nil
+		}
+	}
+	return nil, fmt.Errorf("service %v not found in the catalog", idOrName)
+}
+
+func (c *brokerClient) addService(svc *extension.ServiceExtension) (*extension.ServiceExtension, error) {
+	added := new(extension.ServiceExtension)
+	_, err := c.doJSON("POST", "/v2/catalog", svc, added)
+	return added, err
+}
+
+func (c *brokerClient) updateService(svc *extension.ServiceExtension) (*extension.ServiceExtension, error) {
+	updated := new(extension.ServiceExtension)
+	_, err := c.doJSON("PUT", "/v2/catalog/"+svc.ID, svc, updated)
+	return updated, err
+}
+
+func (c *brokerClient) removeService(serviceID string) error {
+	_, err := c.doJSON("DELETE", "/v2/catalog/"+serviceID, nil, nil)
+	return err
+}
+
+func (c *brokerClient) addPlan(serviceID string, plan *cf.Plan) (*cf.Plan, error) {
+	added := new(cf.Plan)
+	_, err := c.doJSON("POST", planPath(serviceID, plan.ID), plan, added)
+	return added, err
+}
+
+func (c *brokerClient) updatePlan(serviceID string, plan *cf.Plan) (*cf.Plan, error) {
+	updated := new(cf.Plan)
+	_, err := c.doJSON("PUT", planPath(serviceID, plan.ID), plan, updated)
+	return updated, err
+}
+
+func (c *brokerClient) removePlan(serviceID, planID string) error {
+	_, err := c.doJSON("DELETE", planPath(serviceID, planID), nil, nil)
+	return err
+}
+
+func planPath(serviceID, planID string) string {
+	return fmt.Sprintf("/v2/catalog/%v/plans/%v", serviceID, planID)
+}
+
+func (c *brokerClient) exportCatalog(format string) ([]byte, error) {
+	return c.do("GET", "/v2/catalog/export?format="+format, "", nil)
+}
+
+func (c *brokerClient) importCatalog(doc []byte, format string, mode string, dryRun bool) (*extension.ImportReport, error) {
+	path := fmt.Sprintf("/v2/catalog/import?format=%v&mode=%v&dry_run=%v", format, mode, dryRun)
+	raw, err := c.do("POST", path, "application/"+format, bytes.NewReader(doc))
+	if err != nil {
+		return nil, err
+	}
+	report := new(extension.ImportReport)
+	return report, json.Unmarshal(raw, report)
+}
+
+func (c *brokerClient) getInstances() ([]*extension.ServiceInstanceExtension, error) {
+	instances := []*extension.ServiceInstanceExtension{}
+	_, err := c.doJSON("GET", "/v2/instances", nil, &instances)
+	return instances, err
+}
+
+func (c *brokerClient) getInstance(instanceID string) (*extension.ServiceInstanceExtension, error) {
+	instance := new(extension.ServiceInstanceExtension)
+	_, err := c.doJSON("GET", "/v2/instances/"+instanceID, nil, instance)
+	return instance, err
+}
+
+func (c *brokerClient) extendInstance(instanceID, ttl string) (*extension.ServiceInstanceExtension, error) {
+	instance := new(extension.ServiceInstanceExtension)
+	_, err := c.doJSON("POST", "/v2/instances/"+instanceID+"/extend", extension.ExtendRequest{TTL: ttl}, instance)
+	return instance, err
+}
+
+// changeInstanceState stops or starts service instance, action is either stop or start
+func (c *brokerClient) changeInstanceState(instanceID, action string) (*extension.ServiceInstanceExtension, error) {
+	instance := new(extension.ServiceInstanceExtension)
+	_, err := c.doJSON("POST", "/v2/instances/"+instanceID+"/"+action, nil, instance)
+	return instance, err
+}
+
+func (c *brokerClient) doJSON(method, path string, in interface{}, out interface{}) ([]byte, error) {
+	var body io.Reader
+	if in != nil {
+		raw, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(raw)
+	}
+	raw, err := c.do(method, path, "application/json", body)
+	if err != nil || out == nil || len(raw) == 0 {
+		return raw, err
+	}
+	return raw, json.Unmarshal(raw, out)
+}
+
+func (c *brokerClient) do(method, path, contentType string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, c.address+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.user, c.password)
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		brokerErr := cf.BrokerError{}
+		if json.Unmarshal(raw, &brokerErr) == nil && len(brokerErr.Description) > 0 {
+			return nil, fmt.Errorf("%v %v failed (%d): %v", method, path, resp.StatusCode, brokerErr.Description)
+		}
+		return nil, fmt.Errorf("%v %v failed (%d)", method, path, resp.StatusCode)
+	}
+	return raw, nil
+}
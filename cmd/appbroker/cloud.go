@@ -0,0 +1,111 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/trustedanalytics/application-broker/cloud"
+	"github.com/trustedanalytics/application-broker/env"
+	"github.com/trustedanalytics/application-broker/service/extension"
+)
+
+// cfConfig holds the part of cf CLI configuration needed to reach Cloud Controller
+type cfConfig struct {
+	Target             string
+	AccessToken        string
+	OrganizationFields struct{ Name string }
+	SpaceFields        struct{ Name string }
+}
+
+func loadCfConfig() *cfConfig {
+	config := new(cfConfig)
+	home := env.GetEnvVarAsString("CF_HOME", env.GetEnvVarAsString("HOME", ""))
+	raw, err := ioutil.ReadFile(filepath.Join(home, ".cf", "config.json"))
+	if err == nil {
+		json.Unmarshal(raw, config)
+	}
+	config.Target = env.GetEnvVarAsString("CF_API", config.Target)
+	config.AccessToken = env.GetEnvVarAsString("CF_TOKEN", config.AccessToken)
+	return config
+}
+
+type tokenTransport struct {
+	token string
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	authorized := new(http.Request)
+	*authorized = *req
+	authorized.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		authorized.Header[k] = v
+	}
+	authorized.Header.Set("Authorization", t.token)
+	return http.DefaultTransport.RoundTrip(authorized)
+}
+
+// resolveAppGUID finds reference app in Cloud Controller. Org and space default to ones targeted with cf CLI.
+func resolveAppGUID(ref extension.AppReference) (string, error) {
+	config := loadCfConfig()
+	if len(ref.Org) == 0 {
+		ref.Org = config.OrganizationFields.Name
+	}
+	if len(ref.Space) == 0 {
+		ref.Space = config.SpaceFields.Name
+	}
+	if len(config.Target) == 0 || len(config.AccessToken) == 0 {
+		return "", fmt.Errorf("Cloud Controller address or token unknown, set CF_API and CF_TOKEN or log in with cf CLI")
+	}
+	if len(ref.Org) == 0 || len(ref.Space) == 0 {
+		return "", fmt.Errorf("org and space of application %v not specified", ref.Name)
+	}
+
+	token := config.AccessToken
+	if !strings.HasPrefix(strings.ToLower(token), "bearer ") {
+		token = "bearer " + token
+	}
+	client := &http.Client{Transport: &tokenTransport{token: token}}
+	return cloud.NewCloudAPIForClient(strings.TrimRight(config.Target, "/"), client).FindAppGUID(ref)
+}
+
+// readIcon turns image file into data URI accepted by the marketplace
+func readIcon(path string) (string, error) {
+	if len(path) == 0 || strings.HasPrefix(path, "data:image") {
+		return path, nil
+	}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	imageType := strings.TrimPrefix(filepath.Ext(path), ".")
+	return fmt.Sprintf("data:image/%v;base64,%v", imageType, base64.StdEncoding.EncodeToString(raw)), nil
+}
+
+func readFileOrStdin(path string) ([]byte, error) {
+	if path == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(path)
+}
@@ -0,0 +1,171 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-community/types-cf"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/trustedanalytics/application-broker/service/extension"
+)
+
+var _ = Describe("Commands", func() {
+	var (
+		server   *httptest.Server
+		out      *bytes.Buffer
+		catalog  *extension.CatalogExtension
+		received []*extension.ServiceExtension
+		actions  []string
+	)
+
+	BeforeEach(func() {
+		out = new(bytes.Buffer)
+		received = nil
+		actions = nil
+		svc := &extension.ServiceExtension{}
+		svc.ID = "svc-id"
+		svc.Name = "svc"
+		svc.Description = "desc"
+		svc.Plans = []*cf.Plan{{ID: "plan-id", Name: "Simple", Free: true}}
+		svc.ReferenceApp.Meta.GUID = "app-guid"
+		catalog = &extension.CatalogExtension{Services: []*extension.ServiceExtension{svc}}
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			user, pass, _ := req.BasicAuth()
+			if user != "admin" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			switch {
+			case req.Method == "GET" && req.URL.Path == "/v2/catalog":
+				json.NewEncoder(w).Encode(catalog)
+			case req.Method == "PUT" && req.URL.Path == "/v2/catalog/svc-id":
+				updated := new(extension.ServiceExtension)
+				raw, _ := ioutil.ReadAll(req.Body)
+				json.Unmarshal(raw, updated)
+				received = append(received, updated)
+				w.Write(raw)
+			case req.Method == "POST" && strings.HasPrefix(req.URL.Path, "/v2/catalog/svc-id/plans/"):
+				plan := new(cf.Plan)
+				json.NewDecoder(req.Body).Decode(plan)
+				svc := *catalog.Services[0]
+				svc.Plans = append(svc.Plans, plan)
+				received = append(received, &svc)
+				w.WriteHeader(http.StatusCreated)
+				json.NewEncoder(w).Encode(plan)
+			case req.Method == "DELETE" && req.URL.Path == "/v2/catalog/svc-id/plans/plan-id":
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"description":"Cannot delete the only plan of service svc"}`))
+			case req.Method == "POST" && req.URL.Path == "/v2/instances/instance-id/extend":
+				extendReq := new(extension.ExtendRequest)
+				json.NewDecoder(req.Body).Decode(extendReq)
+				actions = append(actions, "extend "+extendReq.TTL)
+				expiresAt := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+				json.NewEncoder(w).Encode(&extension.ServiceInstanceExtension{ID: "instance-id", ExpiresAt: &expiresAt})
+			case req.Method == "POST" && req.URL.Path == "/v2/instances/instance-id/stop":
+				actions = append(actions, "stop")
+				json.NewEncoder(w).Encode(&extension.ServiceInstanceExtension{ID: "instance-id",
+					State: extension.InstanceStopped})
+			case req.Method == "DELETE" && req.URL.Path == "/v2/catalog/svc-id":
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"description":"Service has instances"}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	execute := func(args ...string) error {
+		return run(append([]string{"-b", server.URL, "-u", "admin", "-p", "secret"}, args...), out)
+	}
+
+	It("should list services as a table", func() {
+		Expect(execute("catalog", "list")).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("svc-id"))
+		Expect(out.String()).To(ContainSubstring("app-guid"))
+	})
+
+	It("should list services as JSON", func() {
+		Expect(execute("-json", "catalog", "list")).To(Succeed())
+		services := []*extension.ServiceExtension{}
+		Expect(json.Unmarshal(out.Bytes(), &services)).To(Succeed())
+		Expect(services).To(HaveLen(1))
+		Expect(services[0].Name).To(Equal("svc"))
+	})
+
+	It("should update only flags that were set", func() {
+		Expect(execute("catalog", "update", "-service", "svc", "-description", "new")).To(Succeed())
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].Description).To(Equal("new"))
+		Expect(received[0].Name).To(Equal("svc"))
+		Expect(received[0].ReferenceApp.Meta.GUID).To(Equal("app-guid"))
+	})
+
+	It("should add plan to the service", func() {
+		Expect(execute("plans", "add", "-service", "svc-id", "-name", "Paid", "-description", "d", "-free=false")).To(Succeed())
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].Plans).To(HaveLen(2))
+		Expect(received[0].Plans[1].Name).To(Equal("Paid"))
+		Expect(received[0].Plans[1].Free).To(BeFalse())
+		Expect(received[0].Plans[1].ID).NotTo(BeEmpty())
+	})
+
+	It("should report refused plan removal", func() {
+		err := execute("plans", "remove", "-service", "svc", "-plan", "Simple")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("only plan"))
+	})
+
+	It("should report broker error description", func() {
+		err := execute("catalog", "remove", "-service", "svc")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Service has instances"))
+	})
+
+	It("should extend instance by requested TTL", func() {
+		Expect(execute("instances", "extend", "-id", "instance-id", "-ttl", "48h")).To(Succeed())
+		Expect(actions).To(Equal([]string{"extend 48h"}))
+		Expect(out.String()).To(ContainSubstring("2030-01-02"))
+	})
+
+	It("should stop instance", func() {
+		Expect(execute("instances", "stop", "-id", "instance-id")).To(Succeed())
+		Expect(actions).To(Equal([]string{"stop"}))
+		Expect(out.String()).To(ContainSubstring("Instance instance-id is stopped"))
+	})
+
+	It("should use http when address has no scheme", func() {
+		address := strings.TrimPrefix(server.URL, "http://")
+		Expect(run([]string{"-b", address, "-u", "admin", "-p", "secret", "catalog", "list"}, out)).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("svc-id"))
+	})
+
+	It("should fail when required flag is missing", func() {
+		Expect(execute("catalog", "add", "-name", "x")).NotTo(Succeed())
+	})
+})
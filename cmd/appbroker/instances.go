@@ -0,0 +1,134 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"fmt"
+)
+
+func instancesList(ctx *cliContext, args []string) error {
+	flags := newFlagSet("instances list")
+	service := flags.String("service", "", "id or name of the service offering to list instances of")
+	set, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	instances, err := ctx.broker.getInstances()
+	if err != nil {
+		return err
+	}
+	if set["service"] {
+		svc, err := ctx.broker.findService(*service)
+		if err != nil {
+			return err
+		}
+		filtered := instances[:0]
+		for _, instance := range instances {
+			if instance.ServiceID == svc.ID {
+				filtered = append(filtered, instance)
+			}
+		}
+		instances = filtered
+	}
+
+	rows := [][]string{}
+	for _, instance := range instances {
+		rows = append(rows, []string{instance.ID, instance.ServiceID, instance.App.Meta.GUID, instance.State})
+	}
+	return ctx.printTable(instances, []string{"ID", "SERVICE ID", "APP GUID", "STATE"}, rows)
+}
+
+func instancesShow(ctx *cliContext, args []string) error {
+	flags := newFlagSet("instances show")
+	id := flags.String("id", "", "id of the service instance")
+	set, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if err := requireFlags(flags, set, "id"); err != nil {
+		return err
+	}
+
+	instance, err := ctx.broker.getInstance(*id)
+	if err != nil {
+		return err
+	}
+	if ctx.json {
+		return ctx.printJSON(instance)
+	}
+	fmt.Fprintf(ctx.out, "ID:         %v\n", instance.ID)
+	fmt.Fprintf(ctx.out, "Service ID: %v\n", instance.ServiceID)
+	fmt.Fprintf(ctx.out, "State:      %v\n", instance.State)
+	fmt.Fprintf(ctx.out, "Plan ID:    %v\n", instance.PlanID)
+	fmt.Fprintf(ctx.out, "Org GUID:   %v\n", instance.OrganizationGUID)
+	fmt.Fprintf(ctx.out, "Space GUID: %v\n", instance.SpaceGUID)
+	fmt.Fprintf(ctx.out, "Created by: %v\n", instance.CreatedBy)
+	fmt.Fprintf(ctx.out, "Created at: %v\n", instance.CreatedAt)
+	fmt.Fprintf(ctx.out, "Updated at: %v\n", instance.UpdatedAt)
+	if instance.ExpiresAt != nil {
+		fmt.Fprintf(ctx.out, "Expires at: %v\n", instance.ExpiresAt)
+	}
+	fmt.Fprintf(ctx.out, "App name:   %v\n", instance.App.Entity.Name)
+	fmt.Fprintf(ctx.out, "App GUID:   %v\n", instance.App.Meta.GUID)
+	fmt.Fprintf(ctx.out, "App state:  %v\n", instance.App.Entity.State)
+	return nil
+}
+
+func instancesExtend(ctx *cliContext, args []string) error {
+	flags := newFlagSet("instances extend")
+	id := flags.String("id", "", "id of the service instance")
+	ttl := flags.String("ttl", "", "new time to live counted from now, e.g. 24h (defaults to TTL of the plan)")
+	set, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if err := requireFlags(flags, set, "id"); err != nil {
+		return err
+	}
+
+	instance, err := ctx.broker.extendInstance(*id, *ttl)
+	if err != nil {
+		return err
+	}
+	return ctx.printResult(instance, "Instance %v expires at %v", instance.ID, instance.ExpiresAt)
+}
+
+func instancesStop(ctx *cliContext, args []string) error {
+	return changeInstanceState(ctx, "stop", args)
+}
+
+func instancesStart(ctx *cliContext, args []string) error {
+	return changeInstanceState(ctx, "start", args)
+}
+
+func changeInstanceState(ctx *cliContext, action string, args []string) error {
+	flags := newFlagSet("instances " + action)
+	id := flags.String("id", "", "id of the service instance")
+	set, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if err := requireFlags(flags, set, "id"); err != nil {
+		return err
+	}
+
+	instance, err := ctx.broker.changeInstanceState(*id, action)
+	if err != nil {
+		return err
+	}
+	return ctx.printResult(instance, "Instance %v is %v", instance.ID, instance.State)
+}
@@ -0,0 +1,138 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command appbroker manages catalog and service instances of the Application Broker.
+//
+// Usage:
+//
+//     appbroker [-b brokerAddress] [-u user] [-p password] [-json] <group> <command> [flags]
+//
+// Broker address and credentials may be also provided with APPBROKER_URL, APPBROKER_USER and APPBROKER_PASS.
+// Address without scheme is reached with plain http://; give https:// explicitly for broker serving TLS.
+// Reference apps are resolved by name through Cloud Controller given with CF_API and CF_TOKEN
+// or taken from cf CLI configuration, when these are not set.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	log "github.com/cihub/seelog"
+	"github.com/trustedanalytics/application-broker/env"
+)
+
+type cliContext struct {
+	broker *brokerClient
+	json   bool
+	out    io.Writer
+}
+
+type command struct {
+	usage string
+	run   func(ctx *cliContext, args []string) error
+}
+
+var commands = map[string]map[string]command{
+	"catalog": {
+		"list":   {"list services in the catalog", catalogList},
+		"add":    {"register reference app as new service offering", catalogAdd},
+		"update": {"update service offering", catalogUpdate},
+		"remove": {"remove service offering from the catalog", catalogRemove},
+		"export": {"export the catalog with reference apps identified by names", catalogExport},
+		"import": {"import exported catalog", catalogImport},
+	},
+	"instances": {
+		"list":   {"list service instances", instancesList},
+		"show":   {"show details of service instance", instancesShow},
+		"extend": {"postpone expiry of service instance", instancesExtend},
+		"stop":   {"stop applications of service instance", instancesStop},
+		"start":  {"start applications of service instance", instancesStart},
+	},
+	"plans": {
+		"list":   {"list plans of service offering", plansList},
+		"add":    {"add plan to service offering", plansAdd},
+		"update": {"update plan of service offering", plansUpdate},
+		"remove": {"remove plan from service offering", plansRemove},
+	},
+}
+
+func main() {
+	// Libraries shared with the broker log to stdout, which would corrupt command output
+	log.ReplaceLogger(log.Disabled)
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	global := flag.NewFlagSet("appbroker", flag.ContinueOnError)
+	address := global.String("b", env.GetEnvVarAsString("APPBROKER_URL", ""), "address of the Application Broker")
+	user := global.String("u", env.GetEnvVarAsString("APPBROKER_USER", ""), "broker credentials - user")
+	password := global.String("p", env.GetEnvVarAsString("APPBROKER_PASS", ""), "broker credentials - password")
+	asJSON := global.Bool("json", false, "print results as JSON")
+	global.Usage = func() { printUsage(global) }
+	if err := global.Parse(args); err != nil {
+		return err
+	}
+
+	rest := global.Args()
+	if len(rest) < 2 {
+		printUsage(global)
+		return fmt.Errorf("command not specified")
+	}
+	cmd, ok := commands[rest[0]][rest[1]]
+	if !ok {
+		printUsage(global)
+		return fmt.Errorf("unknown command: %v %v", rest[0], rest[1])
+	}
+	if len(*address) == 0 {
+		return fmt.Errorf("broker address not specified")
+	}
+
+	ctx := &cliContext{
+		broker: newBrokerClient(*address, *user, *password),
+		json:   *asJSON,
+		out:    out,
+	}
+	return cmd.run(ctx, rest[2:])
+}
+
+func printUsage(global *flag.FlagSet) {
+	fmt.Fprintln(os.Stderr, "Usage: appbroker [global flags] <group> <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nGlobal flags:")
+	global.PrintDefaults()
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	groups := []string{}
+	for group := range commands {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		names := []string{}
+		for name := range commands[group] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(os.Stderr, "  %-20s %v\n", group+" "+name, commands[group][name].usage)
+		}
+	}
+	fmt.Fprintln(os.Stderr, "\nRun 'appbroker <group> <command> -h' for command flags.")
+}
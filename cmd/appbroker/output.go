@@ -0,0 +1,102 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func (ctx *cliContext) printJSON(v interface{}) error {
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(ctx.out, string(raw))
+	return err
+}
+
+// printTable prints rows aligned in columns or, in JSON mode, prints v instead
+func (ctx *cliContext) printTable(v interface{}, header []string, rows [][]string) error {
+	if ctx.json {
+		return ctx.printJSON(v)
+	}
+	w := tabwriter.NewWriter(ctx.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// printResult prints v in JSON mode and message otherwise
+func (ctx *cliContext) printResult(v interface{}, format string, args ...interface{}) error {
+	if ctx.json {
+		return ctx.printJSON(v)
+	}
+	_, err := fmt.Fprintf(ctx.out, format+"\n", args...)
+	return err
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	return flags
+}
+
+// parseFlags parses command flags and returns names of flags explicitly set
+func parseFlags(flags *flag.FlagSet, args []string) (map[string]bool, error) {
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			printFlags(flags)
+		}
+		return nil, err
+	}
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return set, nil
+}
+
+func requireFlags(flags *flag.FlagSet, set map[string]bool, names ...string) error {
+	for _, name := range names {
+		if !set[name] {
+			return fmt.Errorf("flag -%v is required in %v", name, flags.Name())
+		}
+	}
+	return nil
+}
+
+func splitList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func printFlags(flags *flag.FlagSet) {
+	fmt.Fprintf(os.Stderr, "Usage of %v:\n", flags.Name())
+	flags.SetOutput(os.Stderr)
+	flags.PrintDefaults()
+}
@@ -0,0 +1,172 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/cloudfoundry-community/types-cf"
+	"github.com/trustedanalytics/application-broker/misc"
+)
+
+func plansList(ctx *cliContext, args []string) error {
+	flags := newFlagSet("plans list")
+	service := flags.String("service", "", "id or name of the service offering")
+	set, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if err := requireFlags(flags, set, "service"); err != nil {
+		return err
+	}
+
+	svc, err := ctx.broker.findService(*service)
+	if err != nil {
+		return err
+	}
+	rows := [][]string{}
+	for _, plan := range svc.Plans {
+		rows = append(rows, []string{plan.ID, plan.Name, plan.Description, strconv.FormatBool(plan.Free)})
+	}
+	return ctx.printTable(svc.Plans, []string{"ID", "NAME", "DESCRIPTION", "FREE"}, rows)
+}
+
+// planFlags are shared by plans add and plans update
+type planFlags struct {
+	service, name, description, displayName string
+	free                                    bool
+}
+
+func newPlanFlags(flags *flag.FlagSet) *planFlags {
+	f := new(planFlags)
+	flags.StringVar(&f.service, "service", "", "id or name of the service offering")
+	flags.StringVar(&f.name, "name", "", "name of the plan")
+	flags.StringVar(&f.description, "description", "", "description of the plan")
+	flags.StringVar(&f.displayName, "display-name", "", "name of the plan visible in the marketplace")
+	flags.BoolVar(&f.free, "free", true, "whether the plan is free of charge")
+	return f
+}
+
+func (f *planFlags) apply(plan *cf.Plan, set map[string]bool) {
+	if set["name"] {
+		plan.Name = f.name
+	}
+	if set["description"] {
+		plan.Description = f.description
+	}
+	if set["free"] {
+		plan.Free = f.free
+	}
+	if set["display-name"] {
+		if plan.Metadata == nil {
+			plan.Metadata = new(cf.PlanMeta)
+		}
+		plan.Metadata.DisplayName = f.displayName
+	}
+}
+
+func plansAdd(ctx *cliContext, args []string) error {
+	flags := newFlagSet("plans add")
+	f := newPlanFlags(flags)
+	set, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if err := requireFlags(flags, set, "service", "name", "description"); err != nil {
+		return err
+	}
+
+	svc, err := ctx.broker.findService(f.service)
+	if err != nil {
+		return err
+	}
+	if findPlan(svc.Plans, f.name) != nil {
+		return fmt.Errorf("plan %v already exists in service %v", f.name, svc.Name)
+	}
+	plan := &cf.Plan{ID: misc.NewGUID(), Free: true}
+	f.apply(plan, set)
+	added, err := ctx.broker.addPlan(svc.ID, plan)
+	if err != nil {
+		return err
+	}
+	return ctx.printResult(added, "Plan %v added to service %v with id %v", added.Name, svc.Name, added.ID)
+}
+
+func plansUpdate(ctx *cliContext, args []string) error {
+	flags := newFlagSet("plans update")
+	f := newPlanFlags(flags)
+	planName := flags.String("plan", "", "id or name of the plan to update")
+	set, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if err := requireFlags(flags, set, "service", "plan"); err != nil {
+		return err
+	}
+
+	svc, err := ctx.broker.findService(f.service)
+	if err != nil {
+		return err
+	}
+	plan := findPlan(svc.Plans, *planName)
+	if plan == nil {
+		return fmt.Errorf("plan %v not found in service %v", *planName, svc.Name)
+	}
+	f.apply(plan, set)
+	updated, err := ctx.broker.updatePlan(svc.ID, plan)
+	if err != nil {
+		return err
+	}
+	return ctx.printResult(updated, "Plan %v of service %v updated", updated.Name, svc.Name)
+}
+
+func plansRemove(ctx *cliContext, args []string) error {
+	flags := newFlagSet("plans remove")
+	service := flags.String("service", "", "id or name of the service offering")
+	planName := flags.String("plan", "", "id or name of the plan to remove")
+	set, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if err := requireFlags(flags, set, "service", "plan"); err != nil {
+		return err
+	}
+
+	svc, err := ctx.broker.findService(*service)
+	if err != nil {
+		return err
+	}
+	plan := findPlan(svc.Plans, *planName)
+	if plan == nil {
+		return fmt.Errorf("plan %v not found in service %v", *planName, svc.Name)
+	}
+	if err := ctx.broker.removePlan(svc.ID, plan.ID); err != nil {
+		return err
+	}
+	return ctx.printResult(plan, "Plan %v removed from service %v", plan.Name, svc.Name)
+}
+
+// findPlan looks for plan by its id or name
+func findPlan(plans []*cf.Plan, idOrName string) *cf.Plan {
+	for _, plan := range plans {
+		if plan.ID == idOrName || plan.Name == idOrName {
+			return plan
+		}
+	}
+	return nil
+}
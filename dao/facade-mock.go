@@ -19,6 +19,8 @@ package dao
 import (
 	"github.com/stretchr/testify/mock"
 	"github.com/trustedanalytics/application-broker/service/extension"
+	"github.com/trustedanalytics/go-cf-lib/types"
+	"time"
 )
 
 type FacadeMock struct {
@@ -60,7 +62,26 @@ func (c *FacadeMock) AppendInstance(instance extension.ServiceInstanceExtension)
 
 func (c *FacadeMock) FindInstance(id string) (*extension.ServiceInstanceExtension, error) {
 	args := c.Called(id)
-	return args.Get(0).(*extension.ServiceInstanceExtension), nil
+	instance, _ := args.Get(0).(*extension.ServiceInstanceExtension)
+	if instance == nil {
+		return nil, types.InstanceNotFoundError
+	}
+	return instance, nil
+}
+
+func (c *FacadeMock) GetInstances() ([]*extension.ServiceInstanceExtension, error) {
+	args := c.Called()
+	return args.Get(0).([]*extension.ServiceInstanceExtension), nil
+}
+
+func (c *FacadeMock) GetInstancesExpiringBefore(deadline time.Time) ([]*extension.ServiceInstanceExtension, error) {
+	args := c.Called(deadline)
+	return args.Get(0).([]*extension.ServiceInstanceExtension), args.Error(1)
+}
+
+func (c *FacadeMock) UpdateInstance(instance *extension.ServiceInstanceExtension) error {
+	args := c.Called(instance)
+	return args.Error(0)
 }
 
 func (c *FacadeMock) HasInstancesOf(serviceID string) (bool, error) {
@@ -71,6 +92,19 @@ func (c *FacadeMock) HasInstancesOf(serviceID string) (bool, error) {
 	return args.Bool(0), nil
 }
 
+func (c *FacadeMock) HasInstancesOfPlan(serviceID, planID string) (bool, error) {
+	args := c.Called(serviceID, planID)
+	if args.Get(1) != nil {
+		return false, args.Get(1).(error)
+	}
+	return args.Bool(0), nil
+}
+
+func (c *FacadeMock) CountInstances(filter InstanceFilter) (int, error) {
+	args := c.Called(filter)
+	return args.Int(0), args.Error(1)
+}
+
 func (c *FacadeMock) RemoveInstance(id string) error {
 	c.Called(id)
 	return nil
@@ -17,12 +17,27 @@
 package dao
 
 import (
+	"time"
+
 	"github.com/trustedanalytics/application-broker/service/extension"
 )
 
+// InstanceFilter selects instances matching all non-empty fields
+type InstanceFilter struct {
+	ServiceID        string
+	PlanID           string
+	OrganizationGUID string
+	SpaceGUID        string
+}
+
 type Instances interface {
 	AppendInstance(extension.ServiceInstanceExtension) error
 	FindInstance(id string) (*extension.ServiceInstanceExtension, error)
+	GetInstances() ([]*extension.ServiceInstanceExtension, error)
+	GetInstancesExpiringBefore(deadline time.Time) ([]*extension.ServiceInstanceExtension, error)
+	UpdateInstance(*extension.ServiceInstanceExtension) error
 	HasInstancesOf(serviceID string) (bool, error)
+	HasInstancesOfPlan(serviceID, planID string) (bool, error)
+	CountInstances(filter InstanceFilter) (int, error)
 	RemoveInstance(id string) (err error)
 }
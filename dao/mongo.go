@@ -24,6 +24,7 @@ import (
 	"github.com/trustedanalytics/go-cf-lib/types"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
+	"time"
 )
 
 type Mongo struct {
@@ -49,6 +50,16 @@ func MongoFactory(envs *cfenv.App) *Mongo {
 	return &Mongo{session: session}
 }
 
+// Ping checks connection with mongodb
+func (c *Mongo) Ping() error {
+	if c.session == nil {
+		return errors.New("Not connected to mongodb")
+	}
+	session := c.session.Copy()
+	defer session.Close()
+	return session.Ping()
+}
+
 func (c *Mongo) Get() ([]*extension.ServiceExtension, error) {
 	result := []*extension.ServiceExtension{}
 	session := c.session.Copy()
@@ -150,6 +161,44 @@ func (c *Mongo) FindInstance(id string) (*extension.ServiceInstanceExtension, er
 	return result, nil
 }
 
+func (c *Mongo) GetInstances() ([]*extension.ServiceInstanceExtension, error) {
+	session := c.session.Copy()
+	defer session.Close()
+	instances := session.DB("").C("instances")
+
+	result := []*extension.ServiceInstanceExtension{}
+	if err := instances.Find(nil).All(&result); err != nil {
+		log.Errorf("Problems while getting service instances: [%v]", err)
+		return nil, errors.Annotate(types.InternalServerError, "Could not get service instances from DB")
+	}
+	return result, nil
+}
+
+func (c *Mongo) GetInstancesExpiringBefore(deadline time.Time) ([]*extension.ServiceInstanceExtension, error) {
+	session := c.session.Copy()
+	defer session.Close()
+	instances := session.DB("").C("instances")
+
+	result := []*extension.ServiceInstanceExtension{}
+	if err := instances.Find(bson.M{"expiresat": bson.M{"$lte": deadline}}).All(&result); err != nil {
+		log.Errorf("Problems while getting expiring service instances: [%v]", err)
+		return nil, errors.Annotate(types.InternalServerError, "Could not get service instances from DB")
+	}
+	return result, nil
+}
+
+func (c *Mongo) UpdateInstance(instance *extension.ServiceInstanceExtension) error {
+	session := c.session.Copy()
+	defer session.Close()
+	instances := session.DB("").C("instances")
+
+	if err := instances.Update(bson.M{"id": instance.ID}, instance); err != nil {
+		log.Errorf("Could not update instance %v in database: [%v]", instance.ID, err)
+		return errors.Annotate(types.InternalServerError, "Problem with updating svc instance in DB")
+	}
+	return nil
+}
+
 func (c *Mongo) HasInstancesOf(serviceID string) (bool, error) {
 	session := c.session.Copy()
 	defer session.Close()
@@ -162,6 +211,45 @@ func (c *Mongo) HasInstancesOf(serviceID string) (bool, error) {
 	return count > 0, nil
 }
 
+// HasInstancesOfPlan counts also instances created before plan was recorded, as they may belong to any plan
+func (c *Mongo) HasInstancesOfPlan(serviceID, planID string) (bool, error) {
+	session := c.session.Copy()
+	defer session.Close()
+	instances := session.DB("").C("instances")
+
+	query := bson.M{"serviceid": serviceID, "planid": bson.M{"$in": []interface{}{planID, "", nil}}}
+	count, err := instances.Find(query).Count()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (c *Mongo) CountInstances(filter InstanceFilter) (int, error) {
+	session := c.session.Copy()
+	defer session.Close()
+	instances := session.DB("").C("instances")
+
+	// Failed provisionings do not hold quota
+	query := bson.M{"state": bson.M{"$ne": extension.InstanceFailed}}
+	for field, value := range map[string]string{
+		"serviceid":        filter.ServiceID,
+		"planid":           filter.PlanID,
+		"organizationguid": filter.OrganizationGUID,
+		"spaceguid":        filter.SpaceGUID,
+	} {
+		if len(value) > 0 {
+			query[field] = value
+		}
+	}
+	count, err := instances.Find(query).Count()
+	if err != nil {
+		log.Errorf("Could not count instances matching %+v: [%v]", filter, err)
+		return 0, errors.Annotate(types.InternalServerError, "Could not count service instances in DB")
+	}
+	return count, nil
+}
+
 func (c *Mongo) RemoveInstance(id string) error {
 	session := c.session.Copy()
 	defer session.Close()
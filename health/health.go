@@ -0,0 +1,98 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package health
+
+import (
+	"fmt"
+	"github.com/trustedanalytics/application-broker/logging"
+	"sync"
+	"time"
+)
+
+const (
+	UpStatus   = "up"
+	DownStatus = "down"
+)
+
+// Check probes single dependency of the broker
+type Check struct {
+	Name string
+	// Broker is not ready when required dependency is down
+	Required bool
+	Probe    func() error
+}
+
+// DependencyStatus is result of a check
+type DependencyStatus struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Required  bool    `json:"required"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report tells whether broker is ready and how its dependencies are doing
+type Report struct {
+	Status       string             `json:"status"`
+	Dependencies []DependencyStatus `json:"dependencies"`
+}
+
+// Run probes all dependencies in parallel. Dependency whose probe does not finish within timeout is reported down.
+func Run(checks []Check, timeout time.Duration) Report {
+	report := Report{Status: UpStatus, Dependencies: make([]DependencyStatus, len(checks))}
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(checks))
+	for i, check := range checks {
+		go func(i int, check Check) {
+			defer wg.Done()
+			report.Dependencies[i] = run(check, timeout)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, dependency := range report.Dependencies {
+		if dependency.Required && dependency.Status != UpStatus {
+			report.Status = DownStatus
+		}
+	}
+	return report
+}
+
+func run(check Check, timeout time.Duration) DependencyStatus {
+	status := DependencyStatus{Name: check.Name, Status: UpStatus, Required: check.Required}
+
+	start := time.Now()
+	// Buffered, so that probe outliving the timeout does not block forever
+	result := make(chan error, 1)
+	go func() { result <- check.Probe() }()
+
+	var err error
+	select {
+	case err = <-result:
+	case <-time.After(timeout):
+		err = fmt.Errorf("No response within %v", timeout)
+	}
+	status.LatencyMs = float64(time.Since(start)) / float64(time.Millisecond)
+
+	if err != nil {
+		status.Status = DownStatus
+		// Errors of drivers may contain addresses with credentials
+		status.Error = logging.Redact(err.Error())
+	}
+	return status
+}
@@ -18,10 +18,31 @@ package logging
 
 import (
 	log "github.com/cihub/seelog"
+	"github.com/trustedanalytics/application-broker/env"
 	"os"
+	"strings"
 )
 
+// Formats of logger.config use %RedactedMsg for text messages with fields in front or %JSON for JSON entries
+func init() {
+	log.RegisterCustomFormatter("RedactedMsg", func(param string) log.FormatterFunc {
+		return func(message string, level log.LogLevel, context log.LogContextInterface) interface{} {
+			return textMessage(message)
+		}
+	})
+	log.RegisterCustomFormatter("JSON", func(param string) log.FormatterFunc {
+		return func(message string, level log.LogLevel, context log.LogContextInterface) interface{} {
+			return jsonMessage(message, level, context)
+		}
+	})
+}
+
+// Initialize configures logger from logger.config. Values of keys listed in LOG_SENSITIVE_KEYS
+// (comma separated) are masked in addition to DefaultSensitiveKeys when format uses %RedactedMsg.
 func Initialize() {
+	if keys := env.GetEnvVarAsString("LOG_SENSITIVE_KEYS", ""); len(keys) > 0 {
+		SetSensitiveKeys(append(DefaultSensitiveKeys, strings.Split(keys, ",")...))
+	}
 	log.RegisterReceiver("stderr", &StdErrReceiver{})
 
 	logger, err := log.LoggerFromConfigAsFile("logger.config")
@@ -0,0 +1,189 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logging
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// Mask replaces sensitive values in log messages
+	Mask = "*****"
+	// MinSecretLength is the shortest registered secret, shorter ones would mask unrelated parts of messages
+	MinSecretLength = 6
+
+	maxSecrets = 10000
+)
+
+// DefaultSensitiveKeys are matched case insensitively as parts of keys, e.g. db_password or clientSecret
+var DefaultSensitiveKeys = []string{
+	"password", "passwd", "pwd", "secret", "token", "apikey", "api_key", "access_key", "private_key", "authorization",
+}
+
+var (
+	headerPattern   = regexp.MustCompile(`(?im)^((?:proxy-)?authorization|cookie|set-cookie)[ \t]*:[^\r\n]*`)
+	userinfoPattern = regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`)
+)
+
+// Redactor masks values of sensitive keys (in JSON, key: value and key=value forms), authorization headers,
+// passwords in URLs and registered secrets. It is safe for concurrent use.
+type Redactor struct {
+	mutex       sync.RWMutex
+	jsonPattern *regexp.Regexp
+	pairPattern *regexp.Regexp
+	keys        []string
+	secrets     []string
+	known       map[string]bool
+	replacer    *strings.Replacer
+}
+
+func NewRedactor(sensitiveKeys []string) *Redactor {
+	r := &Redactor{known: make(map[string]bool)}
+	r.SetSensitiveKeys(sensitiveKeys)
+	return r
+}
+
+var defaultRedactor = NewRedactor(DefaultSensitiveKeys)
+
+// SetSensitiveKeys replaces keys whose values are masked
+func (r *Redactor) SetSensitiveKeys(keys []string) {
+	quoted := []string{}
+	lowered := []string{}
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); len(key) > 0 {
+			quoted = append(quoted, regexp.QuoteMeta(key))
+			lowered = append(lowered, strings.ToLower(key))
+		}
+	}
+	var jsonPattern, pairPattern *regexp.Regexp
+	if len(quoted) > 0 {
+		key := `[A-Za-z0-9_.-]*(?:` + strings.Join(quoted, "|") + `)[A-Za-z0-9_.-]*`
+		jsonPattern = regexp.MustCompile(`(?i)("` + key + `"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+		pairPattern = regexp.MustCompile(`(?i)(^|[^A-Za-z0-9_.-])(` + key + `)([ \t]*[:=][ \t]*)([^\s&,;\]\}"]+)`)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.jsonPattern, r.pairPattern = jsonPattern, pairPattern
+	r.keys = lowered
+}
+
+// IsSensitiveKey tells whether values of the key are masked, i.e. it contains one of sensitive keys
+func (r *Redactor) IsSensitiveKey(key string) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	key = strings.ToLower(key)
+	for _, sensitive := range r.keys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskValues returns copy of values with values of sensitive keys masked
+func (r *Redactor) MaskValues(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	masked := make(map[string]string, len(values))
+	for key, value := range values {
+		if r.IsSensitiveKey(key) {
+			value = Mask
+		}
+		masked[key] = value
+	}
+	return masked
+}
+
+// RegisterSecret makes the value masked wherever it appears, e.g. generated password
+func (r *Redactor) RegisterSecret(secret string) {
+	if len(secret) < MinSecretLength {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.known[secret] {
+		return
+	}
+	if len(r.secrets) == maxSecrets {
+		delete(r.known, r.secrets[0])
+		r.secrets = r.secrets[1:]
+	}
+	r.secrets = append(r.secrets, secret)
+	r.known[secret] = true
+	r.replacer = nil
+}
+
+// Redact returns message with sensitive values masked
+func (r *Redactor) Redact(message string) string {
+	r.mutex.RLock()
+	jsonPattern, pairPattern, replacer := r.jsonPattern, r.pairPattern, r.replacer
+	r.mutex.RUnlock()
+	if replacer == nil {
+		replacer = r.buildReplacer()
+	}
+
+	message = replacer.Replace(message)
+	message = headerPattern.ReplaceAllString(message, "${1}: "+Mask)
+	message = userinfoPattern.ReplaceAllString(message, "${1}"+Mask+"@")
+	if jsonPattern != nil {
+		message = jsonPattern.ReplaceAllString(message, `${1}"`+Mask+`"`)
+		message = pairPattern.ReplaceAllString(message, "${1}${2}${3}"+Mask)
+	}
+	return message
+}
+
+func (r *Redactor) buildReplacer() *strings.Replacer {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.replacer == nil {
+		pairs := make([]string, 0, 2*len(r.secrets))
+		for _, secret := range r.secrets {
+			pairs = append(pairs, secret, Mask)
+		}
+		r.replacer = strings.NewReplacer(pairs...)
+	}
+	return r.replacer
+}
+
+// SetSensitiveKeys replaces keys masked in all log messages
+func SetSensitiveKeys(keys []string) {
+	defaultRedactor.SetSensitiveKeys(keys)
+}
+
+// RegisterSecret makes the value masked in all log messages
+func RegisterSecret(secret string) {
+	defaultRedactor.RegisterSecret(secret)
+}
+
+// IsSensitiveKey tells whether values of the key are masked in all log messages
+func IsSensitiveKey(key string) bool {
+	return defaultRedactor.IsSensitiveKey(key)
+}
+
+// MaskValues masks values of sensitive keys the way all log messages mask them
+func MaskValues(values map[string]string) map[string]string {
+	return defaultRedactor.MaskValues(values)
+}
+
+// Redact masks sensitive values the way all log messages are masked
+func Redact(message string) string {
+	return defaultRedactor.Redact(message)
+}
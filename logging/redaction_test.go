@@ -0,0 +1,118 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logging
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+
+	log "github.com/cihub/seelog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Redaction", func() {
+
+	var (
+		output *bytes.Buffer
+		logger log.LoggerInterface
+	)
+
+	BeforeEach(func() {
+		output = new(bytes.Buffer)
+		var err error
+		logger, err = log.LoggerFromWriterWithMinLevelAndFormat(output, log.TraceLvl, "[%LEV] %RedactedMsg%n")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		SetSensitiveKeys(DefaultSensitiveKeys)
+	})
+
+	It("should keep authorization header out of request dumps", func() {
+		req, _ := http.NewRequest("PUT", "http://broker/v2/service_instances/1", strings.NewReader(`{"plan_id": "p"}`))
+		req.SetBasicAuth("admin", "S3cretPass")
+		dump, _ := httputil.DumpRequest(req, true)
+
+		logger.Tracef(string(dump))
+		logger.Flush()
+
+		Expect(output.String()).To(ContainSubstring("Authorization: " + Mask))
+		Expect(output.String()).NotTo(ContainSubstring(strings.TrimPrefix(req.Header.Get("Authorization"), "Basic ")))
+		Expect(output.String()).To(ContainSubstring(`"plan_id": "p"`))
+	})
+
+	It("should mask sensitive keys in JSON, Go values and key=value pairs", func() {
+		logger.Infof(`body: {"name": "db", "db_password" : "json-secret", "nested": {"clientSecret":"esc\"aped"}}`)
+		logger.Infof("Final UPS %v content %v", "ups", map[string]interface{}{"password": "map-secret", "url": "http://x"})
+		logger.Infof("retrieved: %+v", struct{ Token, Name string }{"struct-secret", "ups"})
+		logger.Infof("callback http://api/cb?access_token=query-secret&x=1 and postgres://user:url-secret@db:5432/x")
+		logger.Flush()
+
+		for _, secret := range []string{"json-secret", "esc", "aped", "map-secret", "struct-secret", "query-secret", "url-secret"} {
+			Expect(output.String()).NotTo(ContainSubstring(secret))
+		}
+		Expect(output.String()).To(ContainSubstring(`"name": "db"`))
+		Expect(output.String()).To(ContainSubstring("url:http://x"))
+		Expect(output.String()).To(ContainSubstring("x=1"))
+		Expect(output.String()).To(ContainSubstring("postgres://user:" + Mask + "@db:5432/x"))
+	})
+
+	It("should mask configured keys", func() {
+		SetSensitiveKeys(append(DefaultSensitiveKeys, "license"))
+
+		logger.Infof(`{"license": "ABC-DEF"} license=GHI`)
+		logger.Flush()
+
+		Expect(output.String()).NotTo(ContainSubstring("ABC-DEF"))
+		Expect(output.String()).NotTo(ContainSubstring("GHI"))
+	})
+
+	It("should mask values of sensitive and configured keys in parameters", func() {
+		SetSensitiveKeys(append(DefaultSensitiveKeys, "license"))
+		params := map[string]string{"name": "db", "DB_Password": "p4ss", "license_key": "ABC-DEF"}
+
+		masked := MaskValues(params)
+
+		Expect(masked).To(Equal(map[string]string{"name": "db", "DB_Password": Mask, "license_key": Mask}))
+		Expect(params["DB_Password"]).To(Equal("p4ss"))
+	})
+
+	It("should mask registered secrets wherever they appear", func() {
+		RegisterSecret("hTn8X07zm8KRDKr1")
+		RegisterSecret("short")
+
+		logger.Infof("Creating UPS with url amqp://broker/hTn8X07zm8KRDKr1 for short time")
+		logger.Flush()
+
+		Expect(output.String()).NotTo(ContainSubstring("hTn8X07zm8KRDKr1"))
+		Expect(output.String()).To(ContainSubstring("for short time"))
+	})
+
+	It("should forget the oldest secrets when too many are registered", func() {
+		sut := NewRedactor(DefaultSensitiveKeys)
+		for i := 0; i <= maxSecrets; i++ {
+			sut.RegisterSecret(strings.Repeat("x", MinSecretLength) + string(rune('a'+i%26)) + strings.Repeat("y", i/26))
+		}
+
+		Expect(sut.secrets).To(HaveLen(maxSecrets))
+		Expect(sut.known).To(HaveLen(maxSecrets))
+		Expect(sut.Redact("xxxxxxa")).To(Equal("xxxxxxa"))
+	})
+})
@@ -0,0 +1,203 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/nu7hatch/gouuid"
+	"github.com/trustedanalytics/application-broker/tracing"
+)
+
+// RequestIDHeader carries id correlating log messages of single request
+const RequestIDHeader = "X-Request-ID"
+
+// fieldsMark encloses fields of the message, formatters strip them or turn them into JSON properties
+const fieldsMark = "\x1e"
+
+// Request ids are put into logs and headers, so ids coming from clients are accepted only when they are plain
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// Fields describe operation that log message belongs to
+type Fields struct {
+	RequestID  string `json:"request_id,omitempty"`
+	Operation  string `json:"operation,omitempty"`
+	ServiceID  string `json:"service_id,omitempty"`
+	InstanceID string `json:"instance_id,omitempty"`
+	TraceID    string `json:"trace_id,omitempty"`
+	SpanID     string `json:"span_id,omitempty"`
+	// User who made the change, recorded for catalog changes
+	Actor string `json:"actor,omitempty"`
+}
+
+// Logger writes messages with fields of the operation to seelog logger.
+// It also carries span of the operation, so that its steps are traced as children of it.
+// Nil Logger writes plain messages.
+type Logger struct {
+	fields Fields
+	span   *tracing.Span
+}
+
+// NewLogger creates logger of the operation, request id is generated when fields have none
+func NewLogger(fields Fields) *Logger {
+	if len(fields.RequestID) == 0 {
+		fields.RequestID = NewRequestID()
+	}
+	return &Logger{fields: fields}
+}
+
+// NewRequestID generates id for request that came without valid one
+func NewRequestID() string {
+	id, _ := uuid.NewV4()
+	return id.String()
+}
+
+// ValidRequestID returns id if it is safe to be used, otherwise new id
+func ValidRequestID(id string) string {
+	if requestIDPattern.MatchString(id) {
+		return id
+	}
+	return NewRequestID()
+}
+
+// Fields returns fields attached to entries of the logger
+func (l *Logger) Fields() Fields {
+	if l == nil {
+		return Fields{}
+	}
+	return l.fields
+}
+
+// WithSpan returns logger of work traced by span, its entries carry trace and span ids
+func (l *Logger) WithSpan(span *tracing.Span) *Logger {
+	fields := l.Fields()
+	fields.TraceID = span.TraceID()
+	fields.SpanID = span.SpanID()
+	return &Logger{fields: fields, span: span}
+}
+
+// Span returns span of the operation, nil when it is not traced
+func (l *Logger) Span() *tracing.Span {
+	if l == nil {
+		return nil
+	}
+	return l.span
+}
+
+// StartSpan starts span as a child of span of the logger and returns logger for work traced by it.
+// When tracing is disabled the span is nil and the logger is returned as it is.
+func (l *Logger) StartSpan(name string) (*tracing.Span, *Logger) {
+	span := tracing.Start(l.Span(), name, tracing.InternalKind)
+	if span == nil {
+		return nil, l
+	}
+	return span, l.WithSpan(span)
+}
+
+func (l *Logger) Tracef(format string, params ...interface{}) {
+	log.Trace(l.message(format, params))
+}
+
+func (l *Logger) Debugf(format string, params ...interface{}) {
+	log.Debug(l.message(format, params))
+}
+
+func (l *Logger) Infof(format string, params ...interface{}) {
+	log.Info(l.message(format, params))
+}
+
+func (l *Logger) Warnf(format string, params ...interface{}) error {
+	return log.Warn(l.message(format, params))
+}
+
+func (l *Logger) Errorf(format string, params ...interface{}) error {
+	return log.Error(l.message(format, params))
+}
+
+func (l *Logger) message(format string, params []interface{}) string {
+	message := fmt.Sprintf(format, params...)
+	if l == nil {
+		return message
+	}
+	fields, _ := json.Marshal(l.fields)
+	return fieldsMark + string(fields) + fieldsMark + message
+}
+
+// splitMessage separates fields from message written by Logger
+func splitMessage(message string) (Fields, string) {
+	fields := Fields{}
+	if !strings.HasPrefix(message, fieldsMark) {
+		return fields, message
+	}
+	end := strings.Index(message[len(fieldsMark):], fieldsMark)
+	if end < 0 {
+		return fields, message
+	}
+	end += len(fieldsMark)
+	if err := json.Unmarshal([]byte(message[len(fieldsMark):end]), &fields); err != nil {
+		return Fields{}, message
+	}
+	return fields, message[end+len(fieldsMark):]
+}
+
+// textMessage puts fields in front of redacted message
+func textMessage(message string) string {
+	fields, message := splitMessage(message)
+	prefix := []string{}
+	for _, field := range []struct{ name, value string }{
+		{"request_id", fields.RequestID},
+		{"operation", fields.Operation},
+		{"service_id", fields.ServiceID},
+		{"instance_id", fields.InstanceID},
+		{"trace_id", fields.TraceID},
+		{"actor", fields.Actor},
+	} {
+		if len(field.value) > 0 {
+			prefix = append(prefix, field.name+"="+field.value)
+		}
+	}
+	if len(prefix) == 0 {
+		return Redact(message)
+	}
+	return "[" + strings.Join(prefix, " ") + "] " + Redact(message)
+}
+
+type jsonEntry struct {
+	Time  string `json:"time"`
+	Level string `json:"level"`
+	Fields
+	Message string `json:"message"`
+}
+
+// jsonMessage renders whole log entry with redacted message as single line JSON object
+func jsonMessage(message string, level log.LogLevel, context log.LogContextInterface) string {
+	entry := jsonEntry{Level: level.String()}
+	entry.Fields, entry.Message = splitMessage(message)
+	entry.Message = Redact(entry.Message)
+	callTime := time.Now()
+	if context != nil && context.IsValid() {
+		callTime = context.CallTime()
+	}
+	entry.Time = callTime.UTC().Format(time.RFC3339Nano)
+	raw, _ := json.Marshal(entry)
+	return string(raw)
+}
@@ -0,0 +1,144 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	log "github.com/cihub/seelog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/trustedanalytics/application-broker/tracing"
+)
+
+var _ = Describe("Structured logging", func() {
+
+	var (
+		output *bytes.Buffer
+		fields Fields
+	)
+
+	useFormat := func(format string) {
+		output = new(bytes.Buffer)
+		logger, err := log.LoggerFromWriterWithMinLevelAndFormat(output, log.TraceLvl, format)
+		Expect(err).NotTo(HaveOccurred())
+		log.ReplaceLogger(logger)
+	}
+
+	BeforeEach(func() {
+		fields = Fields{RequestID: "req-1", Operation: "provision", ServiceID: "svc", InstanceID: "inst"}
+	})
+
+	AfterEach(func() {
+		log.ReplaceLogger(log.Disabled)
+	})
+
+	It("should write entries as JSON objects with fields of the logger", func() {
+		useFormat("%JSON%n")
+
+		NewLogger(fields).Infof("provisioned %v with password=%v", "inst", "Sup3rSecret")
+		log.Flush()
+
+		entry := map[string]string{}
+		Expect(json.Unmarshal(output.Bytes(), &entry)).To(Succeed())
+		Expect(entry["level"]).To(Equal("info"))
+		Expect(entry["request_id"]).To(Equal("req-1"))
+		Expect(entry["operation"]).To(Equal("provision"))
+		Expect(entry["service_id"]).To(Equal("svc"))
+		Expect(entry["instance_id"]).To(Equal("inst"))
+		Expect(entry["message"]).To(Equal("provisioned inst with password=" + Mask))
+		Expect(entry["time"]).NotTo(BeEmpty())
+	})
+
+	It("should write messages of plain seelog calls as JSON without fields", func() {
+		useFormat("%JSON%n")
+
+		log.Warn("plain message")
+		log.Flush()
+
+		entry := map[string]string{}
+		Expect(json.Unmarshal(output.Bytes(), &entry)).To(Succeed())
+		Expect(entry["message"]).To(Equal("plain message"))
+		Expect(entry).NotTo(HaveKey("request_id"))
+	})
+
+	It("should put fields in front of text messages", func() {
+		useFormat("%RedactedMsg%n")
+
+		NewLogger(Fields{RequestID: "req-1", Operation: "stop"}).Debugf("stopping")
+		log.Flush()
+
+		Expect(output.String()).To(Equal("[request_id=req-1 operation=stop] stopping\n"))
+	})
+
+	It("should put trace and span ids of the span into fields", func() {
+		exporter := &tracing.ExporterMock{}
+		tracing.SetExporter(exporter)
+		defer tracing.SetExporter(nil)
+
+		operation := NewLogger(fields).WithSpan(tracing.Start(nil, "provision", tracing.ServerKind))
+		span, step := operation.StartSpan("discovery")
+		span.End(nil)
+
+		Expect(step.Fields().RequestID).To(Equal("req-1"))
+		Expect(step.Fields().TraceID).To(Equal(operation.Span().TraceID()))
+		Expect(step.Fields().SpanID).To(Equal(span.SpanID()))
+		Expect(exporter.Spans()[0].ParentSpanID).To(Equal(operation.Span().SpanID()))
+	})
+
+	It("should keep logger when tracing is disabled", func() {
+		logger := NewLogger(fields)
+
+		span, step := logger.StartSpan("discovery")
+
+		Expect(span).To(BeNil())
+		Expect(step == logger).To(BeTrue())
+	})
+
+	It("should generate request id when none is given", func() {
+		logger := NewLogger(Fields{Operation: "expire"})
+
+		Expect(logger.Fields().RequestID).NotTo(BeEmpty())
+		Expect(logger.Fields().Operation).To(Equal("expire"))
+	})
+
+	It("should accept plain request ids only", func() {
+		Expect(ValidRequestID("abc-123_x.y:z")).To(Equal("abc-123_x.y:z"))
+		Expect(ValidRequestID("")).NotTo(BeEmpty())
+		Expect(ValidRequestID("forged\nentry")).NotTo(ContainSubstring("forged"))
+		Expect(ValidRequestID(strings.Repeat("a", 129))).NotTo(HaveLen(129))
+	})
+
+	It("should log without fields when logger is nil", func() {
+		useFormat("%RedactedMsg%n")
+
+		var logger *Logger
+		logger.Infof("no fields")
+		log.Flush()
+
+		Expect(output.String()).To(Equal("no fields\n"))
+	})
+
+	It("should leave messages without fields untouched", func() {
+		fields, message := splitMessage(fieldsMark + "not json" + fieldsMark + "text")
+
+		Expect(fields).To(Equal(Fields{}))
+		Expect(message).To(Equal(fieldsMark + "not json" + fieldsMark + "text"))
+	})
+})
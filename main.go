@@ -30,9 +30,13 @@ import (
 	"github.com/trustedanalytics/application-broker/broker"
 	"github.com/trustedanalytics/application-broker/cloud"
 	"github.com/trustedanalytics/application-broker/dao"
+	"github.com/trustedanalytics/application-broker/env"
+	"github.com/trustedanalytics/application-broker/health"
 	"github.com/trustedanalytics/application-broker/logging"
 	"github.com/trustedanalytics/application-broker/messagebus"
 	"github.com/trustedanalytics/application-broker/service"
+	"github.com/trustedanalytics/application-broker/tracing"
+	"time"
 )
 
 func main() {
@@ -41,6 +45,7 @@ func main() {
 	var err error
 
 	logging.Initialize()
+	tracing.Initialize()
 
 	cfEnv, err := cfenv.Current()
 	if err != nil {
@@ -63,11 +68,34 @@ func main() {
 	cloud := cloud.NewCloudAPI(cfEnv)
 	s := service.New(db, cloud, mbus, service.CreationStatusFactory{})
 
-	b, err := broker.New(s)
+	checks := []health.Check{
+		{Name: "mongodb", Required: true, Probe: db.Ping},
+		{Name: "cloud_controller", Required: true, Probe: cloud.PingCloudController},
+		{Name: "app_dependency_discoverer", Required: true, Probe: cloud.PingDiscoverer},
+	}
+	if natsAvailable {
+		// Events are not published when NATS is down, but broker still works
+		checks = append(checks, health.Check{Name: "nats", Probe: func() error { return messagebus.Ping(mbus) }})
+	}
+
+	b, err := broker.New(s, checks)
 	if err != nil {
 		log.Criticalf("failed to initialize broker: [%v]", err)
 	}
 
+	// Background jobs are stopped and awaited on shutdown, like requests in progress
+	if len(service.CatalogSyncFile()) > 0 {
+		interval := time.Duration(env.GetEnvVarAsInt("CATALOG_SYNC_INTERVAL", 60)) * time.Second
+		b.Background(func(stop <-chan struct{}) { s.WatchCatalogFile(interval, stop) })
+	}
+
+	expiryInterval := time.Duration(env.GetEnvVarAsInt("INSTANCE_EXPIRY_INTERVAL", 300)) * time.Second
+	expiryWarning := time.Duration(env.GetEnvVarAsInt("INSTANCE_EXPIRY_WARNING", 86400)) * time.Second
+	b.Background(func(stop <-chan struct{}) { s.WatchExpiringInstances(expiryInterval, expiryWarning, stop) })
+
+	scheduleInterval := time.Duration(env.GetEnvVarAsInt("INSTANCE_SCHEDULE_INTERVAL", 60)) * time.Second
+	b.Background(func(stop <-chan struct{}) { s.WatchSchedules(scheduleInterval, stop) })
+
 	brokerCfg := broker.Config{}
 	brokerCfg.Initialize(cfEnv)
 	b.Start(brokerCfg)
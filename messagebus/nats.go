@@ -17,6 +17,7 @@
 package messagebus
 
 import (
+	"errors"
 	log "github.com/cihub/seelog"
 	"github.com/nats-io/nats"
 )
@@ -57,3 +58,15 @@ func (n *NatsMessageBus) Publish(m Message) {
 		log.Errorf("Unable to publish message with nats: [%v]", err)
 	}
 }
+
+// Ping checks that bus is connected to NATS server
+func Ping(bus MessageBus) error {
+	natsBus, ok := bus.(*NatsMessageBus)
+	if !ok {
+		return errors.New("Not connected to NATS")
+	}
+	if natsBus.NatsConnection.Conn.Status() != nats.CONNECTED {
+		return errors.New("NATS connection is not established")
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"net/http"
+)
+
+// Default registry is served by the broker on /metrics
+var Default = NewRegistry()
+
+var (
+	// Operations counts Service Broker API operations by service, plan and outcome
+	Operations = Default.NewCounter("appbroker_operations_total",
+		"Service broker operations handled.", "operation", "service", "plan", "outcome")
+	// OperationDuration observes how long Service Broker API operations take
+	OperationDuration = Default.NewHistogram("appbroker_operation_duration_seconds",
+		"Duration of service broker operations.", DefaultBuckets, "operation", "service", "plan", "outcome")
+	// OperationsInFlight is number of Service Broker API operations being handled
+	OperationsInFlight = Default.NewGauge("appbroker_operations_in_flight",
+		"Service broker operations in progress.", "operation")
+
+	// CloudControllerDuration observes latency of Cloud Controller calls
+	CloudControllerDuration = Default.NewHistogram("appbroker_cloud_controller_request_duration_seconds",
+		"Duration of Cloud Controller requests.", DefaultBuckets, "operation")
+	// CloudControllerErrors counts Cloud Controller calls which failed or were answered with error status
+	CloudControllerErrors = Default.NewCounter("appbroker_cloud_controller_errors_total",
+		"Cloud Controller requests that failed.", "operation", "code")
+
+	// DiscoveryDuration observes latency of app dependency discoverer calls
+	DiscoveryDuration = Default.NewHistogram("appbroker_discovery_duration_seconds",
+		"Duration of application stack discovery.", DefaultBuckets, "outcome")
+
+	// Rollbacks counts provisionings aborted by removing already created components
+	Rollbacks = Default.NewCounter("appbroker_rollbacks_total",
+		"Provisioning transactions rolled back.")
+)
+
+const (
+	SuccessOutcome     = "success"
+	ClientErrorOutcome = "client_error"
+	ServerErrorOutcome = "server_error"
+)
+
+// Outcome classifies HTTP status code of an operation
+func Outcome(status int) string {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return ServerErrorOutcome
+	case status >= http.StatusBadRequest:
+		return ClientErrorOutcome
+	default:
+		return SuccessOutcome
+	}
+}
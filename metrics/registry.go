@@ -0,0 +1,253 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are upper bounds (in seconds) of histogram buckets.
+// They cover both single Cloud Controller calls and provisioning of whole stacks.
+var DefaultBuckets = []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+const (
+	counterType   = "counter"
+	gaugeType     = "gauge"
+	histogramType = "histogram"
+)
+
+// Registry keeps metrics and writes them in Prometheus text exposition format
+type Registry struct {
+	mutex    sync.Mutex
+	families []*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+type family struct {
+	name       string
+	help       string
+	metricType string
+	labels     []string
+	buckets    []float64
+	mutex      sync.Mutex
+	series     map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+func (r *Registry) register(name, help, metricType string, buckets []float64, labels []string) *family {
+	f := &family{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labels:     labels,
+		buckets:    buckets,
+		series:     map[string]*series{},
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, registered := range r.families {
+		if registered.name == name {
+			panic(fmt.Sprintf("metric %v registered twice", name))
+		}
+	}
+	r.families = append(r.families, f)
+	return f
+}
+
+// update runs fn on series of given label values under lock of the family
+func (f *family) update(labelValues []string, fn func(*series)) {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metric %v expects labels %v, got values %v", f.name, f.labels, labelValues))
+	}
+	key := strings.Join(labelValues, "\xff")
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string{}, labelValues...), counts: make([]uint64, len(f.buckets))}
+		f.series[key] = s
+	}
+	fn(s)
+}
+
+// Counter is a metric that only grows, e.g. number of handled requests
+type Counter struct {
+	f *family
+}
+
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{r.register(name, help, counterType, nil, labels)}
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases counter by delta, negative deltas are ignored
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.f.update(labelValues, func(s *series) { s.value += delta })
+}
+
+// Gauge is a metric that goes up and down, e.g. number of operations in progress
+type Gauge struct {
+	f *family
+}
+
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(name, help, gaugeType, nil, labels)}
+}
+
+func (g *Gauge) Inc(labelValues ...string) {
+	g.f.update(labelValues, func(s *series) { s.value++ })
+}
+
+func (g *Gauge) Dec(labelValues ...string) {
+	g.f.update(labelValues, func(s *series) { s.value-- })
+}
+
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.f.update(labelValues, func(s *series) { s.value = value })
+}
+
+// Histogram counts observed values, e.g. latencies, in buckets
+type Histogram struct {
+	f *family
+}
+
+// NewHistogram creates histogram with given bucket upper bounds, sorted in increasing order
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return &Histogram{r.register(name, help, histogramType, buckets, labels)}
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.f.update(labelValues, func(s *series) {
+		for i, bound := range h.f.buckets {
+			if value <= bound {
+				s.counts[i]++
+			}
+		}
+		s.sum += value
+		s.count++
+	})
+}
+
+// ObserveSince observes seconds elapsed since start
+func (h *Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// WriteTo writes all metrics in Prometheus text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mutex.Lock()
+	families := append([]*family{}, r.families...)
+	r.mutex.Unlock()
+
+	buffer := new(bytes.Buffer)
+	for _, f := range families {
+		f.write(buffer)
+	}
+	return buffer.WriteTo(w)
+}
+
+// ServeHTTP serves metrics to Prometheus
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+func (f *family) write(w *bytes.Buffer) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	fmt.Fprintf(w, "# HELP %v %v\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %v %v\n", f.name, f.metricType)
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := f.series[key]
+		labels := formatLabels(f.labels, s.labelValues)
+		if f.metricType != histogramType {
+			fmt.Fprintf(w, "%v%v %v\n", f.name, labels, formatValue(s.value))
+			continue
+		}
+		bucketNames := append(append([]string{}, f.labels...), "le")
+		bucketValues := append(append([]string{}, s.labelValues...), "")
+		for i, bound := range f.buckets {
+			bucketValues[len(bucketValues)-1] = formatValue(bound)
+			fmt.Fprintf(w, "%v_bucket%v %v\n", f.name, formatLabels(bucketNames, bucketValues), s.counts[i])
+		}
+		bucketValues[len(bucketValues)-1] = "+Inf"
+		fmt.Fprintf(w, "%v_bucket%v %v\n", f.name, formatLabels(bucketNames, bucketValues), s.count)
+		fmt.Fprintf(w, "%v_sum%v %v\n", f.name, labels, formatValue(s.sum))
+		fmt.Fprintf(w, "%v_count%v %v\n", f.name, labels, s.count)
+	}
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabelValue(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
@@ -0,0 +1,123 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+
+	var (
+		sut *Registry
+	)
+
+	BeforeEach(func() {
+		sut = NewRegistry()
+	})
+
+	exposition := func() string {
+		output := new(bytes.Buffer)
+		_, err := sut.WriteTo(output)
+		Expect(err).NotTo(HaveOccurred())
+		return output.String()
+	}
+
+	It("should write counters per label values", func() {
+		counter := sut.NewCounter("requests_total", "Requests handled.", "operation", "outcome")
+		counter.Inc("provision", "success")
+		counter.Inc("provision", "success")
+		counter.Add(2.5, "bind", "server_error")
+		counter.Add(-1, "bind", "server_error")
+
+		Expect(exposition()).To(Equal(`# HELP requests_total Requests handled.
+# TYPE requests_total counter
+requests_total{operation="bind",outcome="server_error"} 2.5
+requests_total{operation="provision",outcome="success"} 2
+`))
+	})
+
+	It("should write gauges", func() {
+		gauge := sut.NewGauge("in_flight", "Operations in progress.", "operation")
+		gauge.Inc("provision")
+		gauge.Inc("provision")
+		gauge.Dec("provision")
+		gauge.Set(7, "bind")
+
+		Expect(exposition()).To(ContainSubstring(`in_flight{operation="bind"} 7`))
+		Expect(exposition()).To(ContainSubstring(`in_flight{operation="provision"} 1`))
+	})
+
+	It("should write cumulative histogram buckets with sum and count", func() {
+		histogram := sut.NewHistogram("duration_seconds", "Duration.", []float64{0.1, 1})
+		histogram.Observe(0.05)
+		histogram.Observe(0.5)
+		histogram.Observe(3)
+
+		Expect(exposition()).To(Equal(`# HELP duration_seconds Duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{le="0.1"} 1
+duration_seconds_bucket{le="1"} 2
+duration_seconds_bucket{le="+Inf"} 3
+duration_seconds_sum 3.55
+duration_seconds_count 3
+`))
+	})
+
+	It("should escape help and label values", func() {
+		counter := sut.NewCounter("escaped_total", "Line\nbreak", "service")
+		counter.Inc("a\"b\\c\nd")
+
+		Expect(exposition()).To(ContainSubstring(`# HELP escaped_total Line\nbreak`))
+		Expect(exposition()).To(ContainSubstring(`escaped_total{service="a\"b\\c\nd"} 1`))
+	})
+
+	It("should refuse label values not matching label names", func() {
+		counter := sut.NewCounter("labeled_total", "Labeled.", "operation")
+
+		Expect(func() { counter.Inc() }).To(Panic())
+	})
+
+	It("should refuse metrics registered twice", func() {
+		sut.NewCounter("twice_total", "Twice.")
+
+		Expect(func() { sut.NewGauge("twice_total", "Twice.") }).To(Panic())
+	})
+
+	It("should serve metrics in text format", func() {
+		sut.NewCounter("served_total", "Served.").Inc()
+		recorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/metrics", nil)
+
+		sut.ServeHTTP(recorder, req)
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(HavePrefix("text/plain; version=0.0.4"))
+		Expect(recorder.Body.String()).To(ContainSubstring("served_total 1"))
+	})
+
+	It("should classify outcomes by status code", func() {
+		Expect(Outcome(http.StatusCreated)).To(Equal(SuccessOutcome))
+		Expect(Outcome(http.StatusGone)).To(Equal(ClientErrorOutcome))
+		Expect(Outcome(http.StatusBadGateway)).To(Equal(ServerErrorOutcome))
+	})
+})
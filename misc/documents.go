@@ -0,0 +1,91 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package misc
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// FileFormat guesses document format from file extension
+func FileFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		return FormatYAML
+	}
+	return FormatJSON
+}
+
+// MarshalDocument serializes value in given format. YAML documents use the same field names as JSON ones.
+func MarshalDocument(v interface{}, format string) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil || format == FormatJSON {
+		return raw, err
+	}
+	if format != FormatYAML {
+		return nil, fmt.Errorf("Unsupported document format: %v", format)
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(generic)
+}
+
+func UnmarshalDocument(data []byte, format string, v interface{}) error {
+	switch format {
+	case FormatJSON:
+		return json.Unmarshal(data, v)
+	case FormatYAML:
+		var generic interface{}
+		if err := yaml.Unmarshal(data, &generic); err != nil {
+			return err
+		}
+		raw, err := json.Marshal(stringifyKeys(generic))
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(raw, v)
+	}
+	return fmt.Errorf("Unsupported document format: %v", format)
+}
+
+// stringifyKeys converts maps produced by YAML decoder to the ones JSON encoder accepts
+func stringifyKeys(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			converted[fmt.Sprintf("%v", k)] = stringifyKeys(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range value {
+			value[i] = stringifyKeys(item)
+		}
+	}
+	return v
+}
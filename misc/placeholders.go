@@ -0,0 +1,251 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package misc
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/trustedanalytics/application-broker/logging"
+)
+
+const (
+	alphanumericCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// Quotes and backslash are left out not to need escaping, $ not to look like placeholder
+	passwordCharset = alphanumericCharset + "!#%&()*+,-.:;<=>?@[]^_{|}~"
+
+	// MaxPlaceholderSize limits length of generated values
+	MaxPlaceholderSize = 4096
+)
+
+// placeholderGenerator produces value of placeholder, sized generators get the number following placeholder name.
+// Values of secret generators are masked in logs.
+type placeholderGenerator struct {
+	sized    bool
+	secret   bool
+	generate func(p *Placeholders, size int) (string, error)
+}
+
+var placeholderGenerators = map[string]placeholderGenerator{
+	"RANDOM":   {sized: true, secret: true, generate: charsetGenerator(alphanumericCharset)},
+	"PASSWORD": {sized: true, secret: true, generate: charsetGenerator(passwordCharset)},
+	"HEX":      {sized: true, secret: true, generate: bytesGenerator(hex.EncodeToString)},
+	"BASE64":   {sized: true, secret: true, generate: bytesGenerator(base64.StdEncoding.EncodeToString)},
+	"UUID": {secret: true, generate: func(p *Placeholders, size int) (string, error) {
+		return NewGUID(), nil
+	}},
+	"INSTANCE_ID": {generate: func(p *Placeholders, size int) (string, error) {
+		return p.InstanceID, nil
+	}},
+	"INSTANCE_NAME": {generate: func(p *Placeholders, size int) (string, error) {
+		return p.InstanceName, nil
+	}},
+	"SPACE_GUID": {generate: func(p *Placeholders, size int) (string, error) {
+		return p.SpaceGUID, nil
+	}},
+}
+
+// $NAME<size>, ${NAME<size>} or ${NAME<size>:label}, size is given only to sized generators.
+// Without braces placeholder ends with name of known one and its size, e.g. $RANDOM16_SUFFIX is $RANDOM16 and _SUFFIX.
+var placeholderPattern = regexp.MustCompile(`\$\{([A-Z][A-Z0-9_]*)(?::([A-Za-z0-9_.-]+))?\}|\$(` +
+	knownPlaceholders() + `)`)
+
+// Placeholders replaces placeholders in credentials of user provided services cloned for single instance.
+// Values of labeled placeholders, like ${PASSWORD16:db}, are generated once, so the same label
+// gives the same value in every user provided service of the instance. It is safe for concurrent use.
+type Placeholders struct {
+	InstanceID   string
+	InstanceName string
+	SpaceGUID    string
+
+	mutex   sync.Mutex
+	labeled map[string]string
+}
+
+func NewPlaceholders(instanceID, instanceName, spaceGUID string) *Placeholders {
+	return &Placeholders{
+		InstanceID:   instanceID,
+		InstanceName: instanceName,
+		SpaceGUID:    spaceGUID,
+		labeled:      make(map[string]string),
+	}
+}
+
+// Replace substitutes all known placeholders in value, unknown ones are left intact
+func (p *Placeholders) Replace(value string) (string, error) {
+	var err error
+	replaced := placeholderPattern.ReplaceAllStringFunc(value, func(match string) string {
+		if err != nil {
+			return match
+		}
+		var generated string
+		generated, err = p.resolve(match)
+		return generated
+	})
+	if err != nil {
+		return value, err
+	}
+	return replaced, nil
+}
+
+// ReplaceInValue substitutes placeholders in all strings found in decoded JSON value, keys are left intact
+func (p *Placeholders) ReplaceInValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return p.Replace(v)
+	case map[string]interface{}:
+		for key, item := range v {
+			replaced, err := p.ReplaceInValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = replaced
+		}
+	case []interface{}:
+		for i, item := range v {
+			replaced, err := p.ReplaceInValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = replaced
+		}
+	}
+	return value, nil
+}
+
+func (p *Placeholders) resolve(match string) (string, error) {
+	groups := placeholderPattern.FindStringSubmatch(match)
+	token, label := groups[1], groups[2]
+	if len(groups[3]) > 0 {
+		token = groups[3]
+	}
+	generator, sizeStr, ok := findPlaceholderGenerator(token)
+	if !ok {
+		return match, nil
+	}
+	size := 0
+	if generator.sized {
+		size, _ = strconv.Atoi(sizeStr)
+		if size == 0 || size > MaxPlaceholderSize {
+			return "", fmt.Errorf("Size of placeholder %v must be between 1 and %v", match, MaxPlaceholderSize)
+		}
+	}
+
+	if len(label) == 0 {
+		return generate(generator, p, size)
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if generated, ok := p.labeled[label]; ok {
+		return generated, nil
+	}
+	generated, err := generate(generator, p, size)
+	if err != nil {
+		return "", err
+	}
+	if p.labeled == nil {
+		p.labeled = make(map[string]string)
+	}
+	p.labeled[label] = generated
+	return generated, nil
+}
+
+// knownPlaceholders is alternative of names of all generators, longer first so that the longest known name matches
+func knownPlaceholders() string {
+	names := []string{}
+	for name := range placeholderGenerators {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) > len(names[j])
+		}
+		return names[i] < names[j]
+	})
+	for i, name := range names {
+		if placeholderGenerators[name].sized {
+			names[i] = name + "[0-9]+"
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+func generate(generator placeholderGenerator, p *Placeholders, size int) (string, error) {
+	generated, err := generator.generate(p, size)
+	if err == nil && generator.secret {
+		logging.RegisterSecret(generated)
+	}
+	return generated, err
+}
+
+// findPlaceholderGenerator matches placeholder with generator, e.g. RANDOM16 with sized RANDOM and BASE6432 with sized BASE64
+func findPlaceholderGenerator(token string) (placeholderGenerator, string, bool) {
+	if generator, ok := placeholderGenerators[token]; ok && !generator.sized {
+		return generator, "", true
+	}
+	found, foundName, size := placeholderGenerator{}, "", ""
+	for name, generator := range placeholderGenerators {
+		if !generator.sized || !strings.HasPrefix(token, name) || len(name) <= len(foundName) {
+			continue
+		}
+		if rest := token[len(name):]; len(rest) > 0 && strings.Trim(rest, "0123456789") == "" {
+			found, foundName, size = generator, name, rest
+		}
+	}
+	return found, size, len(foundName) > 0
+}
+
+func charsetGenerator(charset string) func(p *Placeholders, size int) (string, error) {
+	return func(p *Placeholders, size int) (string, error) {
+		return randomString(charset, size)
+	}
+}
+
+func bytesGenerator(encode func([]byte) string) func(p *Placeholders, size int) (string, error) {
+	return func(p *Placeholders, size int) (string, error) {
+		bytes := make([]byte, size)
+		if _, err := rand.Read(bytes); err != nil {
+			return "", err
+		}
+		return encode(bytes), nil
+	}
+}
+
+// randomString picks characters uniformly, bytes that would favour beginning of charset are drawn again
+func randomString(charset string, length int) (string, error) {
+	limit := 256 - 256%len(charset)
+	toReturn := make([]byte, 0, length)
+	buffer := make([]byte, length)
+	for len(toReturn) < length {
+		if _, err := rand.Read(buffer); err != nil {
+			return "", err
+		}
+		for _, b := range buffer {
+			if int(b) < limit && len(toReturn) < length {
+				toReturn = append(toReturn, charset[int(b)%len(charset)])
+			}
+		}
+	}
+	return string(toReturn), nil
+}
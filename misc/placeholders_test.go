@@ -0,0 +1,134 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package misc
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/trustedanalytics/application-broker/logging"
+)
+
+var _ = Describe("Placeholders", func() {
+
+	var sut *Placeholders
+
+	BeforeEach(func() {
+		sut = NewPlaceholders("1234-abcd", "my-stack", "space-guid")
+	})
+
+	It("should replace legacy random placeholders of any length", func() {
+		replaced, err := sut.Replace("$RANDOM8/$RANDOM16/$RANDOM40")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replaced).To(MatchRegexp(`^[A-Za-z0-9]{8}/[A-Za-z0-9]{16}/[A-Za-z0-9]{40}$`))
+	})
+
+	It("should replace instance placeholders", func() {
+		replaced, err := sut.Replace("${INSTANCE_NAME}_db:$INSTANCE_ID@$SPACE_GUID")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replaced).To(Equal("my-stack_db:1234-abcd@space-guid"))
+	})
+
+	It("should generate secrets of given number of random bytes", func() {
+		replaced, err := sut.Replace("$HEX16 ${BASE6432} $UUID")
+
+		Expect(err).NotTo(HaveOccurred())
+		parts := strings.Split(replaced, " ")
+		hexBytes, err := hex.DecodeString(parts[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hexBytes).To(HaveLen(16))
+		base64Bytes, err := base64.StdEncoding.DecodeString(parts[1])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(base64Bytes).To(HaveLen(32))
+		Expect(parts[2]).To(MatchRegexp(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`))
+	})
+
+	It("should generate passwords without characters needing escaping", func() {
+		replaced, err := sut.Replace("$PASSWORD64")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replaced).To(HaveLen(64))
+		Expect(replaced).NotTo(ContainSubstring(`"`))
+		Expect(replaced).NotTo(ContainSubstring(`\`))
+		Expect(replaced).NotTo(ContainSubstring(`$`))
+	})
+
+	It("should keep generated secrets out of logs", func() {
+		replaced, err := sut.Replace("$PASSWORD16 $INSTANCE_NAME")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(logging.Redact(replaced)).To(Equal(logging.Mask + " my-stack"))
+	})
+
+	It("should give the same value to placeholders with the same label", func() {
+		first, err := sut.Replace("${PASSWORD16:db}")
+		Expect(err).NotTo(HaveOccurred())
+		second, err := sut.Replace("${PASSWORD16:db} ${PASSWORD16:other}")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(second).To(HavePrefix(first + " "))
+		Expect(second).NotTo(Equal(first + " " + first))
+	})
+
+	It("should leave unknown placeholders and sized ones without size intact", func() {
+		replaced, err := sut.Replace("$HOME ${PATH} $RANDOM ${UUID8}")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replaced).To(Equal("$HOME ${PATH} $RANDOM ${UUID8}"))
+	})
+
+	It("should end placeholders without braces with known name and size", func() {
+		replaced, err := sut.Replace("$RANDOM16_SUFFIX $INSTANCE_NAME_db $INSTANCE_IDS $UUID8")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replaced).To(MatchRegexp(`^[A-Za-z0-9]{16}_SUFFIX my-stack_db 1234-abcdS [0-9a-f-]{36}8$`))
+	})
+
+	It("should refuse too long values", func() {
+		_, err := sut.Replace("$RANDOM100000")
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should replace placeholders in nested credentials only", func() {
+		credentials := map[string]interface{}{
+			"$RANDOM8": "kept",
+			"nested":   map[string]interface{}{"password": "${PASSWORD8:admin}"},
+			"list":     []interface{}{"${PASSWORD8:admin}", 5.0},
+		}
+
+		_, err := sut.ReplaceInValue(credentials)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(credentials).To(HaveKeyWithValue("$RANDOM8", "kept"))
+		password := credentials["nested"].(map[string]interface{})["password"]
+		Expect(password).To(HaveLen(8))
+		Expect(credentials["list"]).To(Equal([]interface{}{password, 5.0}))
+	})
+
+	It("should pick characters uniformly", func() {
+		generated, err := randomString("ab", 10000)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(strings.Count(generated, "a")).To(BeNumerically("~", 5000, 300))
+	})
+})
@@ -17,8 +17,6 @@
 package misc
 
 import (
-	"crypto/rand"
-	"fmt"
 	"github.com/nu7hatch/gouuid"
 	"strings"
 )
@@ -42,23 +40,8 @@ func FirstNonEmpty(elems chan error, size int) error {
 	return nil
 }
 
+// GenerateRandomString returns alphanumeric string, every character is equally likely
 func GenerateRandomString(length int) string {
-	dictionary := "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
-	var bytes = make([]byte, length)
-	rand.Read(bytes)
-	for k, v := range bytes {
-		bytes[k] = dictionary[v%byte(len(dictionary))]
-	}
-	return string(bytes)
-}
-
-// Available substrings for replacement are $RANDOM8, $RANDOM16, $RANDOM24, $RANDOM32.
-// They will be replaced with random alphanumeric string of length 8, 16, 24, 32 accordingly.
-func ReplaceWithRandom(value string) string {
-	for i := 8; i <= 32; i += 8 {
-		for strings.Contains(value, fmt.Sprintf("$RANDOM%d", i)) {
-			value = strings.Replace(value, fmt.Sprintf("$RANDOM%d", i), GenerateRandomString(i), 1)
-		}
-	}
-	return value
+	toReturn, _ := randomString(alphanumericCharset, length)
+	return toReturn
 }
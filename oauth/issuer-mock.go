@@ -0,0 +1,69 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package oauth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+)
+
+// IssuerMock signs tokens with generated key, its key set can be served or written to local file
+type IssuerMock struct {
+	KeyID string
+	key   *rsa.PrivateKey
+}
+
+func NewIssuerMock(keyID string) (*IssuerMock, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return &IssuerMock{KeyID: keyID, key: key}, nil
+}
+
+// Sign returns RS256 token with given claims
+func (i *IssuerMock) Sign(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": i.KeyID})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(signed))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, i.key, crypto.SHA256, digest.Sum(nil))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// KeySet returns JWKS document with public key of the issuer
+func (i *IssuerMock) KeySet() []byte {
+	raw, _ := json.Marshal(map[string][]jsonWebKey{"keys": {{
+		KeyID:   i.KeyID,
+		KeyType: "RSA",
+		Use:     "sig",
+		N:       base64.RawURLEncoding.EncodeToString(i.key.N.Bytes()),
+		E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(i.key.E)).Bytes()),
+	}}})
+	return raw
+}
+
+// WriteKeySet stores key set in file, to be used with file:// URL
+func (i *IssuerMock) WriteKeySet(file string) error {
+	return ioutil.WriteFile(file, i.KeySet(), 0600)
+}
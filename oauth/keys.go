@@ -0,0 +1,126 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package oauth
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	log "github.com/cihub/seelog"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+)
+
+// jsonWebKey is key of JWKS, UAA token_key additionally has PEM encoded key in value
+type jsonWebKey struct {
+	KeyID   string `json:"kid"`
+	KeyType string `json:"kty"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Value   string `json:"value"`
+}
+
+// keySet is either JWKS or single key returned by UAA token_key endpoint
+type keySet struct {
+	Keys []jsonWebKey `json:"keys"`
+	jsonWebKey
+}
+
+// fetchKeys reads RSA signing keys by their ids
+func fetchKeys(client *http.Client, url string) (map[string]*rsa.PublicKey, error) {
+	raw, err := readKeySet(client, url)
+	if err != nil {
+		return nil, err
+	}
+	set := keySet{}
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, err
+	}
+	if len(set.Keys) == 0 {
+		set.Keys = []jsonWebKey{set.jsonWebKey}
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, jwk := range set.Keys {
+		if len(jwk.Use) > 0 && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			log.Warnf("Skipping signing key %q: %v", jwk.KeyID, err)
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA signing keys in key set")
+	}
+	log.Infof("Fetched %d token signing keys from %v", len(keys), url)
+	return keys, nil
+}
+
+func readKeySet(client *http.Client, url string) ([]byte, error) {
+	if strings.HasPrefix(url, "file://") {
+		return ioutil.ReadFile(strings.TrimPrefix(url, "file://"))
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("key set responded with %v", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (jwk jsonWebKey) publicKey() (*rsa.PublicKey, error) {
+	if len(jwk.N) > 0 && len(jwk.E) > 0 {
+		if len(jwk.KeyType) > 0 && jwk.KeyType != "RSA" {
+			return nil, fmt.Errorf("unsupported key type %q", jwk.KeyType)
+		}
+		n, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(jwk.N, "="))
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(jwk.E, "="))
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	}
+	block, _ := pem.Decode([]byte(jwk.Value))
+	if block == nil {
+		return nil, errors.New("neither modulus and exponent nor PEM value given")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not an RSA key")
+	}
+	return key, nil
+}
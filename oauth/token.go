@@ -0,0 +1,222 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package oauth
+
+import (
+	"crypto"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// keysRefreshInterval limits fetching of key set when token is signed with unknown key
+	keysRefreshInterval = time.Minute
+	// leeway tolerates clock skew between the broker and token issuer
+	leeway = 30 * time.Second
+)
+
+var algorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+}
+
+// Claims of validated token used by the broker
+type Claims struct {
+	Subject   string `json:"sub"`
+	UserID    string `json:"user_id"`
+	UserName  string `json:"user_name"`
+	ClientID  string `json:"client_id"`
+	Issuer    string `json:"iss"`
+	Scope     Scopes `json:"scope"`
+	ExpiresAt int64  `json:"exp"`
+	NotBefore int64  `json:"nbf"`
+}
+
+// Actor identifies user of the token, or client when the token was issued to client itself
+func (c *Claims) Actor() string {
+	for _, actor := range []string{c.UserName, c.ClientID, c.Subject} {
+		if len(actor) > 0 {
+			return actor
+		}
+	}
+	return ""
+}
+
+// HasScopes tells whether the token was granted all required scopes
+func (c *Claims) HasScopes(required []string) bool {
+	for _, scope := range required {
+		if !c.Scope.has(scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// Scopes are read from JSON array, as issued by UAA, or space separated string
+type Scopes []string
+
+func (s *Scopes) UnmarshalJSON(raw []byte) error {
+	list := []string{}
+	if err := json.Unmarshal(raw, &list); err == nil {
+		*s = list
+		return nil
+	}
+	joined := ""
+	if err := json.Unmarshal(raw, &joined); err != nil {
+		return err
+	}
+	*s = strings.Fields(joined)
+	return nil
+}
+
+func (s Scopes) has(scope string) bool {
+	for _, granted := range s {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Validator checks signed JWT bearer tokens with keys of their issuer.
+// Keys are read from JWKS or UAA token_key URL, file:// URL points to local key set.
+type Validator struct {
+	keysURL string
+	issuer  string
+	client  *http.Client
+
+	mutex   sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+// NewValidator creates validator of tokens signed with keys from keysURL, issuer is not checked when empty
+func NewValidator(keysURL, issuer string) *Validator {
+	return &Validator{
+		keysURL: keysURL,
+		issuer:  issuer,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// Validate verifies signature, expiry and issuer of the token and returns its claims
+func (v *Validator) Validate(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	h := header{}
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("malformed token header: %v", err)
+	}
+	hash, ok := algorithms[h.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", h.Algorithm)
+	}
+	key, err := v.key(h.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %v", err)
+	}
+	hasher := hash.New()
+	hasher.Write([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, hash, hasher.Sum(nil), signature); err != nil {
+		return nil, errors.New("invalid token signature")
+	}
+
+	claims := &Claims{}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %v", err)
+	}
+	now := time.Now()
+	if claims.ExpiresAt == 0 || now.Add(-leeway).After(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, errors.New("token expired")
+	}
+	if claims.NotBefore > 0 && now.Add(leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, errors.New("token not valid yet")
+	}
+	if len(v.issuer) > 0 && claims.Issuer != v.issuer {
+		return nil, fmt.Errorf("token issued by %q", claims.Issuer)
+	}
+	return claims, nil
+}
+
+// key returns key of given id, key set is fetched again when the key is not known, e.g. after rotation
+func (v *Validator) key(keyID string) (*rsa.PublicKey, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if key := v.lookup(keyID); key != nil {
+		return key, nil
+	}
+	if time.Since(v.fetched) < keysRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", keyID)
+	}
+	v.fetched = time.Now()
+	keys, err := fetchKeys(v.client, v.keysURL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch signing keys: %v", err)
+	}
+	v.keys = keys
+	if key := v.lookup(keyID); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", keyID)
+}
+
+// lookup finds key by id. Token without key id can use the only key of the set,
+// the only key without id, as served by UAA token_key, is used for any token.
+func (v *Validator) lookup(keyID string) *rsa.PublicKey {
+	if key, ok := v.keys[keyID]; ok {
+		return key
+	}
+	if len(v.keys) != 1 {
+		return nil
+	}
+	for id, key := range v.keys {
+		if len(keyID) == 0 || len(id) == 0 {
+			return key
+		}
+	}
+	return nil
+}
+
+func decodeSegment(segment string, target interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, target)
+}
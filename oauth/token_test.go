@@ -0,0 +1,168 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package oauth_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/trustedanalytics/application-broker/oauth"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var _ = Describe("Token validation", func() {
+
+	var (
+		issuer  *IssuerMock
+		other   *IssuerMock
+		server  *httptest.Server
+		fetches int
+		sut     *Validator
+	)
+
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		result := map[string]interface{}{
+			"sub":       "user-guid",
+			"user_name": "operator",
+			"client_id": "cf",
+			"iss":       "https://uaa.example.com/oauth/token",
+			"scope":     []string{"openid", "application_broker.admin"},
+			"exp":       time.Now().Add(time.Hour).Unix(),
+		}
+		for k, v := range overrides {
+			result[k] = v
+		}
+		return result
+	}
+
+	BeforeSuite(func() {
+		var err error
+		issuer, err = NewIssuerMock("key-1")
+		Expect(err).NotTo(HaveOccurred())
+		other, err = NewIssuerMock("key-1")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	BeforeEach(func() {
+		fetches = 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			fetches++
+			w.Write(issuer.KeySet())
+		}))
+		sut = NewValidator(server.URL, "https://uaa.example.com/oauth/token")
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should return claims of valid token", func() {
+		token, err := sut.Validate(issuer.Sign(claims(nil)))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token.Actor()).To(Equal("operator"))
+		Expect(token.HasScopes([]string{"application_broker.admin"})).To(BeTrue())
+		Expect(token.HasScopes([]string{"application_broker.admin", "cloud_controller.admin"})).To(BeFalse())
+	})
+
+	It("should fetch keys once", func() {
+		sut.Validate(issuer.Sign(claims(nil)))
+		sut.Validate(issuer.Sign(claims(nil)))
+
+		Expect(fetches).To(Equal(1))
+	})
+
+	It("should identify client when token has no user", func() {
+		token, err := sut.Validate(issuer.Sign(claims(map[string]interface{}{"user_name": nil, "scope": "application_broker.admin uaa.none"})))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token.Actor()).To(Equal("cf"))
+		Expect(token.HasScopes([]string{"application_broker.admin"})).To(BeTrue())
+	})
+
+	It("should reject token signed with other key", func() {
+		_, err := sut.Validate(other.Sign(claims(nil)))
+
+		Expect(err).To(MatchError("invalid token signature"))
+	})
+
+	It("should reject expired token", func() {
+		_, err := sut.Validate(issuer.Sign(claims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})))
+
+		Expect(err).To(MatchError("token expired"))
+	})
+
+	It("should reject token without expiry", func() {
+		_, err := sut.Validate(issuer.Sign(claims(map[string]interface{}{"exp": nil})))
+
+		Expect(err).To(MatchError("token expired"))
+	})
+
+	It("should reject token of other issuer", func() {
+		_, err := sut.Validate(issuer.Sign(claims(map[string]interface{}{"iss": "https://evil.example.com"})))
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject unsigned token", func() {
+		parts := strings.Split(issuer.Sign(claims(nil)), ".")
+		parts[0] = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+		_, err := sut.Validate(strings.Join(parts, "."))
+
+		Expect(err).To(MatchError(`unsupported signing algorithm "none"`))
+	})
+
+	It("should reject malformed token", func() {
+		_, err := sut.Validate("not-a-token")
+
+		Expect(err).To(MatchError("malformed token"))
+	})
+
+	It("should read local key set", func() {
+		dir, err := ioutil.TempDir("", "oauth")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		file := filepath.Join(dir, "keys.json")
+		Expect(issuer.WriteKeySet(file)).To(Succeed())
+		sut = NewValidator("file://"+file, "")
+
+		_, err = sut.Validate(issuer.Sign(claims(nil)))
+
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should read UAA token key", func() {
+		jwks := map[string][]map[string]string{}
+		Expect(json.Unmarshal(issuer.KeySet(), &jwks)).To(Succeed())
+		tokenKey := jwks["keys"][0]
+		delete(tokenKey, "kid")
+		server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			json.NewEncoder(w).Encode(tokenKey)
+		})
+
+		_, err := sut.Validate(issuer.Sign(claims(nil)))
+
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
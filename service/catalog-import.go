@@ -0,0 +1,194 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package service
+
+import (
+	"encoding/json"
+
+	log "github.com/cihub/seelog"
+	"github.com/signalfx/golib/errors"
+	"github.com/trustedanalytics/application-broker/misc"
+	"github.com/trustedanalytics/application-broker/service/extension"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+// ExportCatalog returns all services from the catalog with reference apps identified by org, space and name
+func (p *LaunchingService) ExportCatalog() (*extension.CatalogExport, error) {
+	services, err := p.db.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	toReturn := &extension.CatalogExport{
+		Version:  extension.CatalogExportVersion,
+		Services: []*extension.ServiceExport{},
+	}
+	for _, svc := range services {
+		ref, err := p.cloud.GetAppReference(svc.ReferenceApp.Meta.GUID)
+		if err != nil {
+			log.Errorf("Cannot resolve reference app of service %v: [%v]", svc.Name, err)
+			return nil, errors.Annotatef(err, "Cannot resolve reference app of service %v", svc.Name)
+		}
+		toReturn.Services = append(toReturn.Services, &extension.ServiceExport{
+			Service:        svc.Service,
+			ReferenceApp:   *ref,
+			Configuration:  svc.Configuration,
+			Quota:          svc.Quota,
+			PlanQuotas:     svc.PlanQuotas,
+			PlanTTLs:       svc.PlanTTLs,
+			PlanSchedules:  svc.PlanSchedules,
+			PlanRoutes:     svc.PlanRoutes,
+			NamingTemplate: svc.NamingTemplate,
+		})
+	}
+	return toReturn, nil
+}
+
+// ImportCatalog resolves reference apps of exported services in this environment and applies them to the catalog.
+// Services are matched by id first and by name then. In replace mode services absent in the document are deleted.
+// With dryRun set nothing is changed, but returned report lists what would be done.
+func (p *LaunchingService) ImportCatalog(doc *extension.CatalogExport, mode extension.ImportMode,
+	dryRun bool) (*extension.ImportReport, error) {
+
+	if doc == nil || doc.Version > extension.CatalogExportVersion || !extension.IsValidImportMode(mode) {
+		return nil, types.InvalidInputError
+	}
+
+	existing, err := p.db.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	report := extension.NewImportReport(mode, dryRun)
+	imported := make(map[string]bool)
+	for _, exported := range doc.Services {
+		current := findMatchingService(existing, exported)
+		if current != nil {
+			imported[current.ID] = true
+		}
+
+		svc, err := p.resolveExportedService(exported, current)
+		if err != nil {
+			report.AddError(exported.Name, err)
+			continue
+		}
+
+		switch {
+		case current == nil:
+			if dryRun {
+				err = p.checkIfInsertable(svc)
+			} else {
+				err = p.InsertToCatalog(svc)
+			}
+			recordChange(report, &report.Inserted, svc.Name, err)
+		case sameServices(current, svc):
+			report.Unchanged = append(report.Unchanged, svc.Name)
+		default:
+			if !dryRun {
+				err = p.UpdateCatalog(svc)
+			}
+			recordChange(report, &report.Updated, svc.Name, err)
+		}
+	}
+
+	if mode == extension.ImportReplace {
+		// Dry run counts services the catalog would hold, real import deletes them one by one
+		services := len(existing) + len(report.Inserted)
+		for _, svc := range existing {
+			if imported[svc.ID] {
+				continue
+			}
+			if dryRun {
+				if err = p.checkIfDeletable(svc.ID, services); err == nil {
+					services--
+				}
+			} else {
+				err = p.DeleteFromCatalog(svc.ID)
+			}
+			recordChange(report, &report.Deleted, svc.Name, err)
+		}
+	}
+	return report, nil
+}
+
+func (p *LaunchingService) resolveExportedService(exported *extension.ServiceExport,
+	current *extension.ServiceExtension) (*extension.ServiceExtension, error) {
+
+	appGUID, err := p.cloud.FindAppGUID(exported.ReferenceApp)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := &extension.ServiceExtension{
+		Service:        exported.Service,
+		Configuration:  exported.Configuration,
+		Quota:          exported.Quota,
+		PlanQuotas:     exported.PlanQuotas,
+		PlanTTLs:       exported.PlanTTLs,
+		PlanSchedules:  exported.PlanSchedules,
+		PlanRoutes:     exported.PlanRoutes,
+		NamingTemplate: exported.NamingTemplate,
+	}
+	svc.ReferenceApp.Meta.GUID = appGUID
+	if current != nil {
+		// Identity of service already published in marketplace has to be preserved
+		svc.ID = current.ID
+	} else if len(svc.ID) == 0 {
+		svc.ID = misc.NewGUID()
+	}
+	for _, plan := range svc.Plans {
+		if len(plan.ID) == 0 {
+			plan.ID = misc.NewGUID()
+		}
+	}
+	if !extension.Validate(svc) {
+		return nil, types.InvalidInputError
+	}
+	return svc, nil
+}
+
+func recordChange(report *extension.ImportReport, changes *[]string, name string, err error) {
+	if err != nil {
+		log.Errorf("Catalog import of service %v failed: [%v]", name, err)
+		report.AddError(name, err)
+		return
+	}
+	*changes = append(*changes, name)
+}
+
+func findMatchingService(services []*extension.ServiceExtension,
+	exported *extension.ServiceExport) *extension.ServiceExtension {
+
+	if len(exported.ID) > 0 {
+		for _, svc := range services {
+			if svc.ID == exported.ID {
+				return svc
+			}
+		}
+	}
+	for _, svc := range services {
+		if svc.Name == exported.Name {
+			return svc
+		}
+	}
+	return nil
+}
+
+func sameServices(a, b *extension.ServiceExtension) bool {
+	first, errA := json.Marshal(a)
+	second, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(first) == string(second)
+}
@@ -0,0 +1,107 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package service
+
+import (
+	"github.com/cloudfoundry-community/types-cf"
+	"github.com/signalfx/golib/errors"
+	"github.com/trustedanalytics/application-broker/service/extension"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+// AddPlan appends new plan to service offering and publishes it in the marketplace
+func (p *LaunchingService) AddPlan(serviceID string, plan *cf.Plan) error {
+	svc, err := p.db.Find(serviceID)
+	if err != nil {
+		return err
+	}
+	if err := validatePlan(svc, plan); err != nil {
+		return err
+	}
+	if findPlan(svc.Plans, plan.ID) >= 0 {
+		return errors.Annotatef(types.ServiceAlreadyExistsError, "Plan %v already exists in service %v", plan.ID, svc.Name)
+	}
+
+	svc.Plans = append(svc.Plans, plan)
+	return p.UpdateCatalog(svc)
+}
+
+// UpdatePlan replaces plan of service offering keeping its position on the plans list
+func (p *LaunchingService) UpdatePlan(serviceID string, plan *cf.Plan) error {
+	svc, err := p.db.Find(serviceID)
+	if err != nil {
+		return err
+	}
+	idx := findPlan(svc.Plans, plan.ID)
+	if idx < 0 {
+		return errors.Annotatef(types.EntityNotFoundError, "Plan %v not found in service %v", plan.ID, svc.Name)
+	}
+	if err := validatePlan(svc, plan); err != nil {
+		return err
+	}
+
+	svc.Plans[idx] = plan
+	return p.UpdateCatalog(svc)
+}
+
+// DeletePlan removes plan from service offering. Plans with instances and the last plan cannot be removed.
+func (p *LaunchingService) DeletePlan(serviceID, planID string) error {
+	svc, err := p.db.Find(serviceID)
+	if err != nil {
+		return err
+	}
+	idx := findPlan(svc.Plans, planID)
+	if idx < 0 {
+		return errors.Annotatef(types.EntityNotFoundError, "Plan %v not found in service %v", planID, svc.Name)
+	}
+	if len(svc.Plans) <= 1 {
+		return errors.Annotatef(types.InvalidInputError, "Cannot delete the only plan of service %v", svc.Name)
+	}
+
+	hasInstances, err := p.db.HasInstancesOfPlan(serviceID, planID)
+	if err != nil {
+		return err
+	}
+	if hasInstances {
+		return errors.Annotatef(types.ExistingInstancesError, "Cannot delete plan %v with existing instances", planID)
+	}
+
+	svc.Plans = append(svc.Plans[:idx], svc.Plans[idx+1:]...)
+	return p.UpdateCatalog(svc)
+}
+
+// validatePlan checks plan itself and uniqueness of its name within the service
+func validatePlan(svc *extension.ServiceExtension, plan *cf.Plan) error {
+	if !extension.ValidatePlan(plan) {
+		return types.InvalidInputError
+	}
+	for _, other := range svc.Plans {
+		if other.ID != plan.ID && other.Name == plan.Name {
+			return errors.Annotatef(types.ServiceAlreadyExistsError, "Plan named %v already exists in service %v",
+				plan.Name, svc.Name)
+		}
+	}
+	return nil
+}
+
+func findPlan(plans []*cf.Plan, planID string) int {
+	for i, plan := range plans {
+		if plan.ID == planID {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,108 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/signalfx/golib/errors"
+	"github.com/trustedanalytics/application-broker/env"
+	"github.com/trustedanalytics/application-broker/misc"
+	"github.com/trustedanalytics/application-broker/service/extension"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+// CatalogSyncFile returns path of the file holding desired catalog, empty when catalog sync is disabled
+func CatalogSyncFile() string {
+	return env.GetEnvVarAsString("CATALOG_SYNC_FILE", "")
+}
+
+// SyncCatalog makes the catalog match document stored in file configured with CATALOG_SYNC_FILE.
+// Services missing in the document are deleted, unless they still have instances.
+func (p *LaunchingService) SyncCatalog(dryRun bool) (*extension.ImportReport, error) {
+	path := CatalogSyncFile()
+	if len(path) == 0 {
+		return nil, errors.Annotate(types.InvalidInputError, "Catalog sync file is not configured")
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Errorf("Cannot read catalog sync file %v: [%v]", path, err)
+		return nil, errors.Annotatef(types.InternalServerError, "Cannot read catalog sync file %v", path)
+	}
+	doc := new(extension.CatalogExport)
+	if err := misc.UnmarshalDocument(raw, misc.FileFormat(path), doc); err != nil {
+		log.Errorf("Cannot parse catalog sync file %v: [%v]", path, err)
+		return nil, errors.Annotatef(types.InvalidInputError, "Cannot parse catalog sync file %v", path)
+	}
+
+	report, err := p.ImportCatalog(doc, extension.ImportReplace, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Catalog synchronized with %v. Inserted: %v, updated: %v, deleted: %v, errors: %+v",
+		path, report.Inserted, report.Updated, report.Deleted, report.Errors)
+	return report, nil
+}
+
+// WatchCatalogFile synchronizes the catalog every time modification of catalog sync file is noticed.
+// It blocks until stop channel is closed.
+func (p *LaunchingService) WatchCatalogFile(interval time.Duration, stop <-chan struct{}) {
+	path := CatalogSyncFile()
+	log.Infof("Watching catalog sync file %v every %v", path, interval)
+
+	var lastModified time.Time
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		lastModified = p.syncModifiedCatalog(path, lastModified)
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncModifiedCatalog synchronizes the catalog when sync file was modified after lastModified and returns
+// modification time of synchronized file. Sync which failed or reported retryable errors is retried on next check,
+// other errors, like deletions blocked by instances, are reported once per modification.
+func (p *LaunchingService) syncModifiedCatalog(path string, lastModified time.Time) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Errorf("Cannot access catalog sync file %v: [%v]", path, err)
+		return lastModified
+	}
+	if info.ModTime() == lastModified {
+		return lastModified
+	}
+	report, err := p.SyncCatalog(false)
+	if err != nil {
+		return lastModified
+	}
+	if report.Retryable {
+		log.Warnf("Catalog sync with %v reported errors, it will be retried", path)
+		return lastModified
+	}
+	if len(report.Errors) > 0 {
+		log.Warnf("Catalog sync with %v reported errors, which remain until the file changes", path)
+	}
+	return info.ModTime()
+}
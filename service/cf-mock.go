@@ -19,6 +19,7 @@ package service
 import (
 	"github.com/cloudfoundry-community/types-cf"
 	"github.com/stretchr/testify/mock"
+	"github.com/trustedanalytics/application-broker/logging"
 	"github.com/trustedanalytics/application-broker/service/extension"
 )
 
@@ -28,9 +29,12 @@ type CfMock struct {
 
 func (c *CfMock) Provision(sourceAppGUID string,
 	servicesConfiguration []*extension.ServiceConfiguration,
-	request *cf.ServiceCreationRequest) (*extension.ServiceCreationResponse, error) {
+	naming *extension.Naming,
+	mainRoutes []*extension.Route,
+	request *cf.ServiceCreationRequest,
+	logger *logging.Logger) (*extension.ServiceCreationResponse, error) {
 
-	args := c.Called(sourceAppGUID, servicesConfiguration, request)
+	args := c.Called(sourceAppGUID, servicesConfiguration, naming, mainRoutes, request, logger)
 	if args.Get(0) == nil {
 		//first return value is nil, we test error case then
 		return nil, args.Get(1).(error)
@@ -38,14 +42,39 @@ func (c *CfMock) Provision(sourceAppGUID string,
 	return args.Get(0).(*extension.ServiceCreationResponse), nil
 }
 
-func (c *CfMock) Deprovision(appGUID string) error {
-	args := c.Called(appGUID)
+func (c *CfMock) Preview(sourceAppGUID string,
+	servicesConfiguration []*extension.ServiceConfiguration,
+	naming *extension.Naming,
+	mainRoutes []*extension.Route,
+	request *cf.ServiceCreationRequest,
+	logger *logging.Logger) (*extension.ProvisioningPreview, error) {
+
+	args := c.Called(sourceAppGUID, servicesConfiguration, naming, mainRoutes, request, logger)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*extension.ProvisioningPreview), nil
+}
+
+func (c *CfMock) Deprovision(appGUID string, routeGUIDs []string, sharedServiceGUIDs []string,
+	logger *logging.Logger) error {
+	args := c.Called(appGUID, routeGUIDs, sharedServiceGUIDs, logger)
 	if args.Get(0) == nil {
 		return nil
 	}
 	return args.Get(0).(error)
 }
 
+func (c *CfMock) StopInstance(appGUID string, logger *logging.Logger) error {
+	args := c.Called(appGUID, logger)
+	return args.Error(0)
+}
+
+func (c *CfMock) StartInstance(appGUID string, logger *logging.Logger) error {
+	args := c.Called(appGUID, logger)
+	return args.Error(0)
+}
+
 func (c *CfMock) UpdateBroker(brokerName string, brokerUri string, username string, password string) error {
 	args := c.Called(brokerName, brokerUri, username, password)
 	if args.Get(0) == nil {
@@ -58,3 +87,31 @@ func (c *CfMock) CheckIfServiceExists(serviceName string) error {
 	c.Called(serviceName)
 	return nil
 }
+
+func (c *CfMock) CheckServiceName(serviceName string) error {
+	args := c.Called(serviceName)
+	if args.Get(0) == nil {
+		return nil
+	}
+	return args.Get(0).(error)
+}
+
+func (c *CfMock) GetAppReference(appGUID string) (*extension.AppReference, error) {
+	args := c.Called(appGUID)
+	if args.Get(0) == nil {
+		return nil, args.Get(1).(error)
+	}
+	return args.Get(0).(*extension.AppReference), nil
+}
+
+func (c *CfMock) FindAppGUID(ref extension.AppReference) (string, error) {
+	args := c.Called(ref)
+	if args.Get(1) != nil {
+		return "", args.Get(1).(error)
+	}
+	return args.String(0), nil
+}
+
+func (c *CfMock) Abort() {
+	c.Called()
+}
@@ -0,0 +1,151 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package service
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/signalfx/golib/errors"
+	"github.com/trustedanalytics/application-broker/logging"
+	"github.com/trustedanalytics/application-broker/service/extension"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+// ExtendInstance moves expiry of instance TTL ahead from now. Empty TTL means TTL of the instance plan.
+func (p *LaunchingService) ExtendInstance(instanceID string, ttl string) (*extension.ServiceInstanceExtension, error) {
+	instance, err := p.db.FindInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	if instance.ExpiresAt == nil {
+		return nil, errors.Annotatef(types.InvalidInputError, "Instance %v does not expire", instanceID)
+	}
+	svc, err := p.db.Find(instance.ServiceID)
+	if err != nil {
+		return nil, err
+	}
+	d, err := instanceTTL(svc, instance.PlanID, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if d == 0 {
+		return nil, errors.Annotatef(types.InvalidInputError, "TTL not given and plan of instance %v has none", instanceID)
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(d)
+	instance.ExpiresAt = &expiresAt
+	instance.ExpiryWarned = false
+	instance.UpdatedAt = now
+	if err := p.db.UpdateInstance(instance); err != nil {
+		return nil, err
+	}
+	log.Infof("Expiry of instance %v extended to %v", instanceID, expiresAt)
+	return instance, nil
+}
+
+// ExpireInstances deprovisions expired instances and warns about ones expiring within warnBefore
+func (p *LaunchingService) ExpireInstances(warnBefore time.Duration) error {
+	now := time.Now().UTC()
+	instances, err := p.db.GetInstancesExpiringBefore(now.Add(warnBefore))
+	if err != nil {
+		return err
+	}
+
+	for _, instance := range instances {
+		if !expires(instance) {
+			continue
+		}
+		if !instance.ExpiresAt.After(now) {
+			logger := logging.NewLogger(logging.Fields{
+				Operation: "expire", ServiceID: instance.ServiceID, InstanceID: instance.ID})
+			logger.Infof("Instance %v expired at %v, deleting it", instance.ID, instance.ExpiresAt)
+			if err := p.DeleteService(instance.ID, logger); err != nil {
+				logger.Errorf("Failed to delete expired instance %v: [%v]", instance.ID, err)
+				continue
+			}
+			p.publishInstanceStatus(instance, "Service instance expired and was deleted")
+		} else if !instance.ExpiryWarned {
+			p.publishInstanceStatus(instance, fmt.Sprintf("Service instance expires at %v",
+				instance.ExpiresAt.Format(time.RFC3339)))
+			instance.ExpiryWarned = true
+			if err := p.db.UpdateInstance(instance); err != nil {
+				log.Errorf("Failed to mark instance %v as warned about expiry: [%v]", instance.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// WatchExpiringInstances runs ExpireInstances periodically. It blocks until stop channel is closed.
+func (p *LaunchingService) WatchExpiringInstances(interval, warnBefore time.Duration, stop <-chan struct{}) {
+	log.Infof("Checking instance expiry every %v", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := p.ExpireInstances(warnBefore); err != nil {
+			log.Errorf("Instance expiry check failed: [%v]", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expires tells whether expiry applies to the instance. Records of failed provisionings, and ones still
+// being provisioned, have no application to delete, so they are left alone.
+func expires(instance *extension.ServiceInstanceExtension) bool {
+	if instance.State == extension.InstanceFailed || instance.State == extension.InstanceProvisioning {
+		return false
+	}
+	return len(instance.App.Meta.GUID) > 0
+}
+
+func (p *LaunchingService) publishInstanceStatus(instance *extension.ServiceInstanceExtension, message string) {
+	stype := instance.ServiceID
+	if svc, err := p.db.Find(instance.ServiceID); err == nil {
+		stype = svc.Name
+	}
+	msg := p.msgFactory.NewServiceStatus(instance.App.Entity.Name, stype, instance.OrganizationGUID, message)
+	p.msgBus.Publish(msg)
+}
+
+// instanceTTL returns time to live of instance, zero when it does not expire.
+// TTL requested explicitly cannot exceed TTL of the plan.
+func instanceTTL(svc *extension.ServiceExtension, planID string, requested string) (time.Duration, error) {
+	var planTTL time.Duration
+	if ttl, ok := svc.PlanTTLs[planID]; ok {
+		planTTL, _ = extension.ParseTTL(ttl)
+	}
+	if len(requested) == 0 {
+		return planTTL, nil
+	}
+
+	ttl, err := extension.ParseTTL(requested)
+	if err != nil {
+		return 0, errors.Annotatef(types.InvalidInputError, "Invalid TTL %v: %v", requested, err)
+	}
+	if planTTL > 0 && ttl > planTTL {
+		return 0, errors.Annotatef(types.InvalidInputError, "Requested TTL %v exceeds TTL %v of the plan", ttl, planTTL)
+	}
+	return ttl, nil
+}
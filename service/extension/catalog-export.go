@@ -0,0 +1,104 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package extension
+
+import (
+	cf "github.com/cloudfoundry-community/types-cf"
+	"github.com/signalfx/golib/errors"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+const CatalogExportVersion = 1
+
+type ImportMode string
+
+const (
+	// ImportMerge inserts new services and updates existing ones, leaving the others untouched
+	ImportMerge ImportMode = "merge"
+	// ImportReplace works like ImportMerge but also deletes services missing in imported document
+	ImportReplace ImportMode = "replace"
+)
+
+// AppReference identifies application by its location instead of GUID, so it remains valid across environments.
+type AppReference struct {
+	Org   string `json:"org"`
+	Space string `json:"space"`
+	Name  string `json:"name"`
+}
+
+// ServiceExport is portable counterpart of ServiceExtension.
+type ServiceExport struct {
+	cf.Service
+	ReferenceApp   AppReference            `json:"app"`
+	Configuration  []*ServiceConfiguration `json:"configuration,omitempty"`
+	Quota          *Quota                  `json:"quota,omitempty"`
+	PlanQuotas     map[string]*Quota       `json:"plan_quotas,omitempty"`
+	PlanTTLs       map[string]string       `json:"plan_ttls,omitempty"`
+	PlanSchedules  map[string]*Schedule    `json:"plan_schedules,omitempty"`
+	PlanRoutes     map[string][]*Route     `json:"plan_routes,omitempty"`
+	NamingTemplate string                  `json:"naming_template,omitempty"`
+}
+
+// CatalogExport is a document holding all services from the catalog in portable form.
+type CatalogExport struct {
+	Version  int              `json:"version"`
+	Services []*ServiceExport `json:"services"`
+}
+
+type ImportError struct {
+	Service string `json:"service"`
+	Error   string `json:"error"`
+}
+
+// ImportReport summarizes changes made (or planned, in case of dry run) by catalog import.
+type ImportReport struct {
+	Mode      ImportMode    `json:"mode"`
+	DryRun    bool          `json:"dry_run"`
+	Inserted  []string      `json:"inserted"`
+	Updated   []string      `json:"updated"`
+	Deleted   []string      `json:"deleted"`
+	Unchanged []string      `json:"unchanged"`
+	Errors    []ImportError `json:"errors"`
+	// Retryable tells that some errors may not happen when import is repeated, e.g. failed lookup of reference app.
+	// Deletions blocked by instances and invalid services fail the same way until the document changes.
+	Retryable bool `json:"-"`
+}
+
+func NewImportReport(mode ImportMode, dryRun bool) *ImportReport {
+	return &ImportReport{
+		Mode:      mode,
+		DryRun:    dryRun,
+		Inserted:  []string{},
+		Updated:   []string{},
+		Deleted:   []string{},
+		Unchanged: []string{},
+		Errors:    []ImportError{},
+	}
+}
+
+func (r *ImportReport) AddError(service string, err error) {
+	r.Errors = append(r.Errors, ImportError{Service: service, Error: errors.Message(err)})
+	switch errors.Tail(err) {
+	case types.ExistingInstancesError, types.InvalidInputError, types.ServiceAlreadyExistsError:
+	default:
+		r.Retryable = true
+	}
+}
+
+func IsValidImportMode(mode ImportMode) bool {
+	return mode == ImportMerge || mode == ImportReplace
+}
@@ -0,0 +1,41 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package extension
+
+import (
+	"fmt"
+	"time"
+)
+
+// TTLParameter is provisioning parameter requesting instance expiry, e.g. ttl=24h
+const TTLParameter = "ttl"
+
+// ExtendRequest asks for instance expiry to be moved TTL ahead from now
+type ExtendRequest struct {
+	TTL string `json:"ttl"`
+}
+
+// ParseTTL accepts positive durations in Go notation, like 90m or 72h
+func ParseTTL(ttl string) (time.Duration, error) {
+	d, err := time.ParseDuration(ttl)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("TTL %v is not positive", ttl)
+	}
+	return d, nil
+}
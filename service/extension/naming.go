@@ -0,0 +1,95 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package extension
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/signalfx/golib/errors"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+const (
+	// DefaultNamingTemplate names clones after their source components with short instance id appended
+	DefaultNamingTemplate = "{{.Component}}-{{.ID}}"
+
+	// MaxHostnameLength limits names of cloned applications, as they are used as route hostnames
+	MaxHostnameLength = 63
+
+	// MaxServiceInstanceNameLength limits names of cloned service instances and user provided services
+	MaxServiceInstanceNameLength = 50
+)
+
+// NamingVariables can be used in naming templates, e.g. {{.Space}}-{{.Component}}-{{.ID}}
+type NamingVariables struct {
+	// Instance is the name requested in provisioning parameters or the name of the service
+	Instance string
+	// Org and Space are names of the organization and space the instance is created in
+	Org   string
+	Space string
+	// Component is the name of the source component, for the main application it is the instance name
+	Component string
+	// ID is the first segment of instance GUID
+	ID string
+}
+
+// Naming describes how components cloned for service instance are named
+type Naming struct {
+	NamingVariables
+	Template string
+	// KeepMainName names the main application after the instance, without applying the template
+	KeepMainName bool
+}
+
+// ValidateNamingTemplate checks that template parses and uses known variables only
+func ValidateNamingTemplate(text string) error {
+	vars := NamingVariables{Instance: "instance", Org: "org", Space: "space", Component: "component", ID: "id"}
+	_, err := renderName(text, vars)
+	return err
+}
+
+// Name renders name of the clone of given component
+func (n *Naming) Name(component string) (string, error) {
+	vars := n.NamingVariables
+	vars.Component = component
+	return renderName(n.Template, vars)
+}
+
+// MainName renders name of the main application
+func (n *Naming) MainName() (string, error) {
+	if n.KeepMainName {
+		return n.Instance, nil
+	}
+	return n.Name(n.Instance)
+}
+
+func renderName(text string, vars NamingVariables) (string, error) {
+	t, err := template.New("name").Parse(text)
+	if err != nil {
+		return "", errors.Annotatef(types.InvalidInputError, "Invalid naming template: %v", err)
+	}
+	buffer := new(bytes.Buffer)
+	if err := t.Execute(buffer, vars); err != nil {
+		return "", errors.Annotatef(types.InvalidInputError, "Invalid naming template: %v", err)
+	}
+	name := strings.Replace(strings.TrimSpace(buffer.String()), " ", "-", -1)
+	if len(name) == 0 {
+		return "", errors.Annotatef(types.InvalidInputError, "Naming template %q renders empty name", text)
+	}
+	return name, nil
+}
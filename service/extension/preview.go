@@ -0,0 +1,62 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package extension
+
+// ProvisioningPreview describes components that provisioning of service instance would create
+type ProvisioningPreview struct {
+	InstanceID           string                       `json:"instance_id"`
+	ServiceID            string                       `json:"service_id"`
+	PlanID               string                       `json:"plan_id"`
+	Apps                 []AppPreview                 `json:"apps"`
+	Services             []ServicePreview             `json:"services"`
+	UserProvidedServices []UserProvidedServicePreview `json:"user_provided_services"`
+	Bindings             []BindingPreview             `json:"bindings"`
+}
+
+// AppPreview is a clone of application, parameters are passed to it as environment variables.
+// Routes are given as host.domain/path.
+type AppPreview struct {
+	SourceGUID string            `json:"source_guid"`
+	Name       string            `json:"name"`
+	Main       bool              `json:"main,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+	Routes     []string          `json:"routes,omitempty"`
+}
+
+// ServicePreview is a clone of service instance, created in the same plan as the source instance.
+// Shared instance is not created, apps are bound to the source instance.
+type ServicePreview struct {
+	SourceGUID string                 `json:"source_guid"`
+	Name       string                 `json:"name"`
+	Shared     bool                   `json:"shared,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// UserProvidedServicePreview is a clone of user provided service.
+// When it links an app, its url is rewritten to http://<first route of linked app>. Credentials are shown
+// with other references to source applications rewritten, placeholders unresolved and sensitive values masked.
+type UserProvidedServicePreview struct {
+	SourceGUID  string                 `json:"source_guid"`
+	Name        string                 `json:"name"`
+	LinkedApp   string                 `json:"linked_app,omitempty"`
+	Credentials map[string]interface{} `json:"credentials,omitempty"`
+}
+
+// BindingPreview binds service or user provided service to app, both identified by clone names
+type BindingPreview struct {
+	Service string `json:"service"`
+	App     string `json:"app"`
+}
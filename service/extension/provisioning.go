@@ -0,0 +1,86 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package extension
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	cf "github.com/cloudfoundry-community/types-cf"
+	"github.com/signalfx/golib/errors"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+// OriginatingIdentityHeader carries identity of the platform user that triggered the request
+const OriginatingIdentityHeader = "X-Broker-API-Originating-Identity"
+
+// ServiceCreationRequest extends cf.ServiceCreationRequest with data introduced in later Service Broker API versions.
+type ServiceCreationRequest struct {
+	cf.ServiceCreationRequest
+	Context  map[string]interface{} `json:"context,omitempty"`
+	Identity *OriginatingIdentity   `json:"-"`
+}
+
+// OriginatingIdentity is decoded value of X-Broker-API-Originating-Identity header
+type OriginatingIdentity struct {
+	Platform string                 `json:"platform"`
+	Value    map[string]interface{} `json:"value"`
+}
+
+// ParseOriginatingIdentity decodes header value made of platform name and base64 encoded JSON object
+func ParseOriginatingIdentity(header string) (*OriginatingIdentity, error) {
+	fields := strings.Fields(header)
+	if len(fields) != 2 {
+		return nil, errors.Annotatef(types.InvalidInputError, "Malformed %v header", OriginatingIdentityHeader)
+	}
+	raw, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, errors.Annotatef(types.InvalidInputError, "%v header value is not base64 encoded", OriginatingIdentityHeader)
+	}
+	identity := &OriginatingIdentity{Platform: fields[0]}
+	if err := json.Unmarshal(raw, &identity.Value); err != nil {
+		return nil, errors.Annotatef(types.InvalidInputError, "%v header value is not JSON object", OriginatingIdentityHeader)
+	}
+	return identity, nil
+}
+
+// User returns id of the user, as Cloud Foundry and Kubernetes identify it
+func (i *OriginatingIdentity) User() string {
+	if i == nil {
+		return ""
+	}
+	for _, key := range []string{"user_id", "username"} {
+		if user, ok := i.Value[key].(string); ok && len(user) > 0 {
+			return user
+		}
+	}
+	return ""
+}
+
+// ApplyContext fills org and space GUIDs, deprecated in Service Broker API 2.12, from context object if missing
+func (r *ServiceCreationRequest) ApplyContext() {
+	if len(r.OrganizationGUID) == 0 {
+		if org, ok := r.Context["organization_guid"].(string); ok {
+			r.OrganizationGUID = org
+		}
+	}
+	if len(r.SpaceGUID) == 0 {
+		if space, ok := r.Context["space_guid"].(string); ok {
+			r.SpaceGUID = space
+		}
+	}
+}
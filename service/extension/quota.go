@@ -0,0 +1,33 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package extension
+
+import (
+	"errors"
+)
+
+var QuotaExceededError = errors.New("Instance quota exceeded")
+
+// Quota limits number of service instances. Zero means no limit.
+type Quota struct {
+	PerOrg   int `json:"per_org,omitempty"`
+	PerSpace int `json:"per_space,omitempty"`
+	Total    int `json:"total,omitempty"`
+}
+
+func (q *Quota) isValid() bool {
+	return q == nil || (q.PerOrg >= 0 && q.PerSpace >= 0 && q.Total >= 0)
+}
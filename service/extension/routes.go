@@ -0,0 +1,80 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package extension
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Provisioning parameters overriding the first route of the main application
+const (
+	RouteDomainParameter   = "route_domain"
+	RouteHostnameParameter = "route_hostname"
+	RoutePathParameter     = "route_path"
+)
+
+// Route of the main application of service instance.
+// Empty Domain means domain of the reference app, empty Hostname means name of the cloned application.
+type Route struct {
+	Domain   string `json:"domain,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	Path     string `json:"path,omitempty"`
+}
+
+var hostnamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// Validate checks that hostname is a DNS label and path is absolute
+func (r *Route) Validate() error {
+	if len(r.Hostname) > 0 && (len(r.Hostname) > MaxHostnameLength || !hostnamePattern.MatchString(r.Hostname)) {
+		return fmt.Errorf("hostname %q is not a valid DNS label", r.Hostname)
+	}
+	if len(r.Path) > 0 && (!strings.HasPrefix(r.Path, "/") || r.Path == "/") {
+		return fmt.Errorf("path %q has to start with / followed by at least one character", r.Path)
+	}
+	return nil
+}
+
+// MainAppRoutes returns routes of the main application: routes of the plan with the first one
+// overridden by provisioning parameters. Single default route is returned when nothing is configured.
+func MainAppRoutes(planRoutes []*Route, parameters map[string]string) ([]*Route, error) {
+	routes := []*Route{}
+	for _, route := range planRoutes {
+		if route != nil {
+			copied := *route
+			routes = append(routes, &copied)
+		}
+	}
+	if len(routes) == 0 {
+		routes = append(routes, &Route{})
+	}
+
+	primary := routes[0]
+	if domain, ok := parameters[RouteDomainParameter]; ok {
+		primary.Domain = domain
+	}
+	if hostname, ok := parameters[RouteHostnameParameter]; ok {
+		primary.Hostname = hostname
+	}
+	if path, ok := parameters[RoutePathParameter]; ok {
+		primary.Path = path
+	}
+	if err := primary.Validate(); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
@@ -0,0 +1,98 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package extension
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	InstanceProvisioning = "provisioning"
+	InstanceStarted      = "started"
+	InstanceStopped      = "stopped"
+	InstanceFailed       = "failed"
+)
+
+// InstanceNotProvisionedError is returned when applications of instance which failed
+// or is still being provisioned are to be stopped or started
+var InstanceNotProvisionedError = errors.New("Instance is not provisioned")
+
+const clockLayout = "15:04"
+
+// Schedule defines working hours. Outside of them instance applications are stopped.
+// Start later than stop means working hours span midnight.
+type Schedule struct {
+	Start    string   `json:"start"`
+	Stop     string   `json:"stop"`
+	Days     []string `json:"days,omitempty"`
+	Timezone string   `json:"timezone,omitempty"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Validate checks that hours are given as HH:MM, days as three letter abbreviations and timezone is known
+func (s *Schedule) Validate() error {
+	start, errStart := time.Parse(clockLayout, s.Start)
+	stop, errStop := time.Parse(clockLayout, s.Stop)
+	if errStart != nil || errStop != nil {
+		return fmt.Errorf("Schedule hours %v-%v are not in HH:MM format", s.Start, s.Stop)
+	}
+	if start.Equal(stop) {
+		return fmt.Errorf("Schedule start and stop hours are equal")
+	}
+	for _, day := range s.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("Unknown schedule day %v, use mon, tue, wed, thu, fri, sat or sun", day)
+		}
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("Unknown schedule timezone %v", s.Timezone)
+	}
+	return nil
+}
+
+// IsWorkingTime tells whether instance should be running at given moment
+func (s *Schedule) IsWorkingTime(t time.Time) bool {
+	location, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	t = t.In(location)
+
+	if len(s.Days) > 0 {
+		working := false
+		for _, day := range s.Days {
+			working = working || weekdays[strings.ToLower(day)] == t.Weekday()
+		}
+		if !working {
+			return false
+		}
+	}
+
+	start, _ := time.Parse(clockLayout, s.Start)
+	stop, _ := time.Parse(clockLayout, s.Stop)
+	now, _ := time.Parse(clockLayout, t.Format(clockLayout))
+	if start.Before(stop) {
+		return !now.Before(start) && now.Before(stop)
+	}
+	return !now.Before(start) || now.Before(stop)
+}
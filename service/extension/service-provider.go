@@ -18,10 +18,12 @@ package extension
 
 import (
 	"github.com/cloudfoundry-community/types-cf"
+	"github.com/trustedanalytics/application-broker/logging"
 	"github.com/trustedanalytics/go-cf-lib/types"
 )
 
 // ServiceProviderExtension beside standard cf.ServiceProvider introduces additional API endpoints.
+// Operations on service instances write their log messages with logger correlating them with the request.
 type ServiceProviderExtension interface {
 
 	// Appends service to the catalog managed by this broker
@@ -33,16 +35,58 @@ type ServiceProviderExtension interface {
 	// Deletes service description from the catalog
 	DeleteFromCatalog(serviceID string) error
 
+	// AddPlan appends plan to service in the catalog
+	AddPlan(serviceID string, plan *cf.Plan) error
+
+	// UpdatePlan replaces plan of service in the catalog
+	UpdatePlan(serviceID string, plan *cf.Plan) error
+
+	// DeletePlan removes plan without instances from service in the catalog
+	DeletePlan(serviceID, planID string) error
+
 	// GetCatalog returns the catalog of services managed by this broker
 	GetCatalog() (*CatalogExtension, error)
 
+	// ExportCatalog returns the catalog with reference apps identified by org, space and name
+	ExportCatalog() (*CatalogExport, error)
+
+	// ImportCatalog applies exported catalog document to the catalog managed by this broker
+	ImportCatalog(doc *CatalogExport, mode ImportMode, dryRun bool) (*ImportReport, error)
+
+	// SyncCatalog makes the catalog match the document stored in configured file
+	SyncCatalog(dryRun bool) (*ImportReport, error)
+
 	// CreateService creates a service instance for specific plan
-	CreateService(r *cf.ServiceCreationRequest) (*cf.ServiceCreationResponse, error)
+	CreateService(r *ServiceCreationRequest, logger *logging.Logger) (*cf.ServiceCreationResponse, error)
+
+	// PreviewService describes what creation of service instance would create, without creating anything
+	PreviewService(r *ServiceCreationRequest, logger *logging.Logger) (*ProvisioningPreview, error)
 
 	// DeleteService deletes previously created service instance
-	DeleteService(instanceID string) error
+	DeleteService(instanceID string, logger *logging.Logger) error
+
+	// GetInstances returns all service instances created by this broker
+	GetInstances() ([]*ServiceInstanceExtension, error)
+
+	// GetInstance returns details of service instance
+	GetInstance(instanceID string) (*ServiceInstanceExtension, error)
+
+	// ExtendInstance moves expiry of service instance TTL ahead from now
+	ExtendInstance(instanceID string, ttl string) (*ServiceInstanceExtension, error)
+
+	// StopInstance stops applications of service instance
+	StopInstance(instanceID string, logger *logging.Logger) (*ServiceInstanceExtension, error)
+
+	// StartInstance starts applications of service instance in dependency order
+	StartInstance(instanceID string, logger *logging.Logger) (*ServiceInstanceExtension, error)
+
+	// SetInstanceSchedule sets working hours of service instance, nil restores schedule of the plan
+	SetInstanceSchedule(instanceID string, schedule *Schedule) (*ServiceInstanceExtension, error)
 
 	// BindService binds to specified service instance and
 	// Returns credentials necessary to establish connection to that service
 	BindService(r *cf.ServiceBindingRequest) (*types.ServiceBindingResponse, error)
+
+	// Abort makes service instances being created roll back what they spawned, used on broker shutdown
+	Abort()
 }
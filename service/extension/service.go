@@ -17,33 +17,70 @@
 package extension
 
 import (
+	"regexp"
+	"time"
+
 	log "github.com/cihub/seelog"
 	cf "github.com/cloudfoundry-community/types-cf"
 	"github.com/nu7hatch/gouuid"
+	"github.com/signalfx/golib/errors"
 	"github.com/trustedanalytics/go-cf-lib/types"
 )
 
+// ServiceConfiguration describes dependent service instance (identified by name of the source instance):
+// parameters passed to its clone and whether it is cloned, shared or skipped at all.
 type ServiceConfiguration struct {
-	ServiceName string   `json:"service_name"`
-	Params      []string `json:"parameters"`
+	ServiceName string        `json:"service_name"`
+	Params      []string      `json:"parameters"`
+	Policy      SharingPolicy `json:"policy,omitempty"`
 }
 
 // ServiceExtension extends cf.Service with data describing application to clone.
+// Quota applies to all instances of the service, PlanQuotas (keyed by plan id) to instances of particular plan.
+// PlanTTLs (keyed by plan id) hold durations, like 72h, after which instances of the plan expire.
+// PlanSchedules (keyed by plan id) define working hours of instances of the plan.
+// PlanRoutes (keyed by plan id) define routes of the main application of instances of the plan.
+// NamingTemplate defines names of cloned components, DefaultNamingTemplate is used when empty.
 type ServiceExtension struct {
 	cf.Service
-	ReferenceApp  types.CfAppResource     `json:"app"`
-	Configuration []*ServiceConfiguration `json:"configuration,omitempty"`
+	ReferenceApp   types.CfAppResource     `json:"app"`
+	Configuration  []*ServiceConfiguration `json:"configuration,omitempty"`
+	Quota          *Quota                  `json:"quota,omitempty"`
+	PlanQuotas     map[string]*Quota       `json:"plan_quotas,omitempty"`
+	PlanTTLs       map[string]string       `json:"plan_ttls,omitempty"`
+	PlanSchedules  map[string]*Schedule    `json:"plan_schedules,omitempty"`
+	PlanRoutes     map[string][]*Route     `json:"plan_routes,omitempty"`
+	NamingTemplate string                  `json:"naming_template,omitempty"`
 }
 
+// ServiceInstanceExtension holds service instance together with context of its provisioning
 type ServiceInstanceExtension struct {
-	ID        string              `json:"id"`
-	ServiceID string              `json:"service_id"`
-	App       types.CfAppResource `json:"app"`
+	ID               string                 `json:"id"`
+	ServiceID        string                 `json:"service_id"`
+	PlanID           string                 `json:"plan_id"`
+	OrganizationGUID string                 `json:"organization_guid"`
+	SpaceGUID        string                 `json:"space_guid"`
+	Parameters       map[string]string      `json:"parameters,omitempty"`
+	Context          map[string]interface{} `json:"context,omitempty"`
+	CreatedBy        string                 `json:"created_by,omitempty"`
+	CreatedAt        time.Time              `json:"created_at"`
+	UpdatedAt        time.Time              `json:"updated_at"`
+	ExpiresAt        *time.Time             `json:"expires_at,omitempty"`
+	ExpiryWarned     bool                   `json:"expiry_warned,omitempty"`
+	Routes           []string               `json:"routes,omitempty"`
+	SharedServices   []string               `json:"shared_services,omitempty"`
+	State            string                 `json:"state,omitempty"`
+	Schedule         *Schedule              `json:"schedule,omitempty"`
+	App              types.CfAppResource    `json:"app"`
 }
 
 type ServiceCreationResponse struct {
 	cf.ServiceCreationResponse
 	App types.CfAppResource `json:"-"`
+	// Routes hold GUIDs of routes created for cloned applications
+	Routes []string `json:"-"`
+	// SharedServices hold GUIDs of service instances bound to cloned applications but not created for them
+	SharedServices []string `json:"-"`
 }
 
 func NewAutogeneratedService() *ServiceExtension {
@@ -54,7 +91,7 @@ func NewAutogeneratedService() *ServiceExtension {
 	to_return.ID = service_guid.String()
 	to_return.Plans = []*cf.Plan{}
 	to_return.Bindable = true
-	default_plan := &cf.Plan{ID: plan_guid.String(), Name: "Simple", Description: "SimplePlan"}
+	default_plan := &cf.Plan{ID: plan_guid.String(), Name: "Simple", Description: "SimplePlan", Free: true}
 	to_return.Plans = append(to_return.Plans, default_plan)
 
 	return to_return
@@ -73,5 +110,72 @@ func Validate(svc *ServiceExtension) bool {
 		log.Warn("Reference app GUID is empty")
 		return false
 	}
+	if !svc.Quota.isValid() {
+		log.Warn("Service quota is negative")
+		return false
+	}
+	for _, conf := range svc.Configuration {
+		if conf != nil && !conf.Policy.IsValid() {
+			log.Warnf("Policy %q of service %v is invalid", conf.Policy, conf.ServiceName)
+			return false
+		}
+	}
+	for planID, quota := range svc.PlanQuotas {
+		if !quota.isValid() {
+			log.Warnf("Quota of plan %v is negative", planID)
+			return false
+		}
+	}
+	for planID, ttl := range svc.PlanTTLs {
+		if _, err := ParseTTL(ttl); err != nil {
+			log.Warnf("TTL of plan %v is invalid: %v", planID, err)
+			return false
+		}
+	}
+	for planID, schedule := range svc.PlanSchedules {
+		if schedule == nil {
+			continue
+		}
+		if err := schedule.Validate(); err != nil {
+			log.Warnf("Schedule of plan %v is invalid: %v", planID, err)
+			return false
+		}
+	}
+	for planID, routes := range svc.PlanRoutes {
+		for _, route := range routes {
+			if route == nil {
+				continue
+			}
+			if err := route.Validate(); err != nil {
+				log.Warnf("Route of plan %v is invalid: %v", planID, err)
+				return false
+			}
+		}
+	}
+	if len(svc.NamingTemplate) > 0 {
+		if err := ValidateNamingTemplate(svc.NamingTemplate); err != nil {
+			log.Warn(errors.Message(err))
+			return false
+		}
+	}
+	return true
+}
+
+// Plan names are used in cf CLI commands, so they are limited to characters that need no quoting
+var planNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+func ValidatePlan(plan *cf.Plan) bool {
+	if len(plan.ID) == 0 {
+		log.Warn("Plan id is empty")
+		return false
+	}
+	if !planNamePattern.MatchString(plan.Name) {
+		log.Warnf("Plan name %q is invalid", plan.Name)
+		return false
+	}
+	if len(plan.Description) == 0 {
+		log.Warn("Plan description is empty")
+		return false
+	}
 	return true
 }
@@ -0,0 +1,48 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package extension
+
+// SharingPolicy tells what provisioning does with dependent service instance of the reference app
+type SharingPolicy string
+
+const (
+	// ClonePolicy creates new service instance in the plan of the source one, it is the default
+	ClonePolicy SharingPolicy = "clone"
+	// SharePolicy binds cloned applications to the source service instance itself
+	SharePolicy SharingPolicy = "share"
+	// SkipPolicy neither creates nor binds service instance
+	SkipPolicy SharingPolicy = "skip"
+)
+
+// IsValid tells whether policy is known, empty policy means ClonePolicy
+func (p SharingPolicy) IsValid() bool {
+	switch p {
+	case "", ClonePolicy, SharePolicy, SkipPolicy:
+		return true
+	}
+	return false
+}
+
+// SharingPolicyOf returns policy configured for dependent service instance of given name
+func SharingPolicyOf(serviceName string, servicesConfiguration []*ServiceConfiguration) SharingPolicy {
+	for _, conf := range servicesConfiguration {
+		if conf != nil && conf.ServiceName == serviceName && len(conf.Policy) > 0 {
+			return conf.Policy
+		}
+	}
+	return ClonePolicy
+}
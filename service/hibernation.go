@@ -0,0 +1,156 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package service
+
+import (
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/signalfx/golib/errors"
+	"github.com/trustedanalytics/application-broker/logging"
+	"github.com/trustedanalytics/application-broker/service/extension"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+// StopInstance stops all applications of service instance
+func (p *LaunchingService) StopInstance(instanceID string, logger *logging.Logger) (*extension.ServiceInstanceExtension, error) {
+	instance, err := p.db.FindInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireProvisioned(instance); err != nil {
+		return nil, err
+	}
+	if err := p.cloud.StopInstance(instance.App.Meta.GUID, logger); err != nil {
+		return nil, err
+	}
+	return instance, p.saveInstanceState(instance, extension.InstanceStopped)
+}
+
+// StartInstance starts applications of service instance in dependency order
+func (p *LaunchingService) StartInstance(instanceID string, logger *logging.Logger) (*extension.ServiceInstanceExtension, error) {
+	instance, err := p.db.FindInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireProvisioned(instance); err != nil {
+		return nil, err
+	}
+	if err := p.cloud.StartInstance(instance.App.Meta.GUID, logger); err != nil {
+		return nil, err
+	}
+	return instance, p.saveInstanceState(instance, extension.InstanceStarted)
+}
+
+// SetInstanceSchedule sets working hours of service instance. Nil schedule restores schedule of the plan.
+func (p *LaunchingService) SetInstanceSchedule(instanceID string,
+	schedule *extension.Schedule) (*extension.ServiceInstanceExtension, error) {
+
+	if schedule != nil {
+		if err := schedule.Validate(); err != nil {
+			return nil, errors.Annotate(types.InvalidInputError, err.Error())
+		}
+	}
+	instance, err := p.db.FindInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	instance.Schedule = schedule
+	instance.UpdatedAt = time.Now().UTC()
+	return instance, p.db.UpdateInstance(instance)
+}
+
+// ApplySchedules stops and starts instances whose working hours began or ended between since and now.
+// Acting on transitions only keeps instances stopped or started manually in that state until next transition.
+func (p *LaunchingService) ApplySchedules(since, now time.Time) error {
+	services, err := p.db.Get()
+	if err != nil {
+		return err
+	}
+	planSchedules := make(map[string]map[string]*extension.Schedule)
+	for _, svc := range services {
+		planSchedules[svc.ID] = svc.PlanSchedules
+	}
+
+	instances, err := p.db.GetInstances()
+	if err != nil {
+		return err
+	}
+	for _, instance := range instances {
+		schedule := instance.Schedule
+		if schedule == nil {
+			schedule = planSchedules[instance.ServiceID][instance.PlanID]
+		}
+		if schedule == nil || instance.State == extension.InstanceFailed || instance.State == extension.InstanceProvisioning {
+			continue
+		}
+
+		working := schedule.IsWorkingTime(now)
+		if working == schedule.IsWorkingTime(since) {
+			continue
+		}
+		logger := logging.NewLogger(logging.Fields{
+			Operation: "schedule", ServiceID: instance.ServiceID, InstanceID: instance.ID})
+		var err error
+		if working && instance.State == extension.InstanceStopped {
+			logger.Infof("Working hours of instance %v began, starting it", instance.ID)
+			_, err = p.StartInstance(instance.ID, logger)
+		} else if !working && instance.State != extension.InstanceStopped {
+			logger.Infof("Working hours of instance %v ended, stopping it", instance.ID)
+			_, err = p.StopInstance(instance.ID, logger)
+		}
+		if err != nil {
+			logger.Errorf("Scheduled state change of instance %v failed: [%v]", instance.ID, err)
+		}
+	}
+	return nil
+}
+
+// WatchSchedules applies schedules periodically. It blocks until stop channel is closed.
+func (p *LaunchingService) WatchSchedules(interval time.Duration, stop <-chan struct{}) {
+	log.Infof("Applying instance schedules every %v", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	since := time.Now().Add(-interval)
+	for {
+		now := time.Now()
+		if err := p.ApplySchedules(since, now); err != nil {
+			log.Errorf("Applying instance schedules failed: [%v]", err)
+		}
+		since = now
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// requireProvisioned refuses to act on instances without applications, i.e. failed or still being provisioned
+func requireProvisioned(instance *extension.ServiceInstanceExtension) error {
+	if instance.State == extension.InstanceFailed || instance.State == extension.InstanceProvisioning {
+		return errors.Annotatef(extension.InstanceNotProvisionedError, "Instance %v is %v", instance.ID, instance.State)
+	}
+	return nil
+}
+
+func (p *LaunchingService) saveInstanceState(instance *extension.ServiceInstanceExtension, state string) error {
+	instance.State = state
+	instance.UpdatedAt = time.Now().UTC()
+	return p.db.UpdateInstance(instance)
+}
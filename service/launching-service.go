@@ -18,6 +18,7 @@ package service
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	log "github.com/cihub/seelog"
 	"github.com/cloudfoundry-community/types-cf"
@@ -25,6 +26,7 @@ import (
 	"github.com/trustedanalytics/application-broker/cloud"
 	"github.com/trustedanalytics/application-broker/dao"
 	"github.com/trustedanalytics/application-broker/env"
+	"github.com/trustedanalytics/application-broker/logging"
 	"github.com/trustedanalytics/application-broker/messagebus"
 	"github.com/trustedanalytics/application-broker/service/extension"
 	"github.com/trustedanalytics/go-cf-lib/types"
@@ -99,19 +101,10 @@ func (p *LaunchingService) DeleteFromCatalog(serviceID string) error {
 	if err != nil {
 		return err
 	}
-	if len(services) <= 1 {
-		return errors.Annotate(types.InternalServerError, "Cannot delete the only service offering. Catalog cannot be empty.")
-	}
-
-	hasInstances, err := p.db.HasInstancesOf(serviceID)
-	if err != nil {
+	if err := p.checkIfDeletable(serviceID, len(services)); err != nil {
 		return err
 	}
 
-	if hasInstances {
-		return types.ExistingInstancesError
-	}
-
 	if err := p.db.Remove(serviceID); err != nil {
 		return err
 	}
@@ -138,19 +131,39 @@ func (p *LaunchingService) GetCatalog() (*extension.CatalogExtension, error) {
 }
 
 // CreateService creates a service instance
-func (p *LaunchingService) CreateService(r *cf.ServiceCreationRequest) (*cf.ServiceCreationResponse, error) {
+func (p *LaunchingService) CreateService(r *extension.ServiceCreationRequest,
+	logger *logging.Logger) (*cf.ServiceCreationResponse, error) {
 	service, err := p.db.Find(r.ServiceID)
 	if err != nil {
 		return nil, err
 	}
-
-	name := p.normalizeInstanceName(r.Parameters["name"], service.Name)
-	// Use param: no-application-name-change=true not to generate main application name automatically
-	if _, ok := r.Parameters["no-application-name-change"]; !ok {
-		name = addInstanceIdSuffix(r.InstanceID, name)
+	ttl, err := instanceTTL(service, r.PlanID, r.Parameters[extension.TTLParameter])
+	if err != nil {
+		return nil, err
+	}
+	routes, err := mainAppRoutes(service, r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.db.FindInstance(r.InstanceID); err == nil {
+		return nil, types.InstanceAlreadyExistsError
+	}
+	if err := p.checkQuotas(service, r, false); err != nil {
+		return nil, err
+	}
+	// Quotas are checked again once the record is written, so that concurrent provisionings count each other
+	instance, err := p.appendInstance(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkQuotas(service, r, true); err != nil {
+		p.db.RemoveInstance(instance.ID)
+		return nil, err
 	}
-	r.Parameters["name"] = name
-	log.Infof("create service: [%v]", name)
+
+	naming := p.instanceNaming(r, service)
+	name := naming.Instance
+	logger.Infof("create service: [%v]", name)
 
 	stype := service.Name
 	org := r.OrganizationGUID
@@ -159,41 +172,70 @@ func (p *LaunchingService) CreateService(r *cf.ServiceCreationRequest) (*cf.Serv
 	p.msgBus.Publish(msg)
 
 	//TODO: instead of referenceApp.GUID we should pass entire app object
-	resp, err := p.cloud.Provision(service.ReferenceApp.Meta.GUID, service.Configuration, r)
+	resp, err := p.cloud.Provision(service.ReferenceApp.Meta.GUID, service.Configuration, naming, routes,
+		&r.ServiceCreationRequest, logger)
 	if err != nil {
 		msg = p.msgFactory.NewServiceStatus(name, stype, org, "Service spawning failed with error: "+err.Error())
 		p.msgBus.Publish(msg)
 
-		if appendErr := p.appendInstance(r, resp); appendErr != nil {
-			log.Errorf("Failed to append instance %v to database: [%v]", r.InstanceID, appendErr.Error())
+		if updateErr := p.completeInstance(instance, nil, 0); updateErr != nil {
+			logger.Errorf("Failed to update instance %v in database: [%v]", r.InstanceID, updateErr.Error())
 		}
 		return nil, err
 	}
 	msg = p.msgFactory.NewServiceStatus(name, stype, org, "Service spawning succeded")
 	p.msgBus.Publish(msg)
 
-	if appendErr := p.appendInstance(r, resp); appendErr != nil {
-		log.Errorf("Failed to append instance %v to database: [%v]", r.InstanceID, appendErr.Error())
+	if updateErr := p.completeInstance(instance, resp, ttl); updateErr != nil {
+		logger.Errorf("Failed to update instance %v in database: [%v]", r.InstanceID, updateErr.Error())
 	}
 	return &resp.ServiceCreationResponse, nil
 }
 
+// PreviewService describes components that CreateService would create for the request, nothing is created
+func (p *LaunchingService) PreviewService(r *extension.ServiceCreationRequest,
+	logger *logging.Logger) (*extension.ProvisioningPreview, error) {
+	service, err := p.db.Find(r.ServiceID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := instanceTTL(service, r.PlanID, r.Parameters[extension.TTLParameter]); err != nil {
+		return nil, err
+	}
+	routes, err := mainAppRoutes(service, r)
+	if err != nil {
+		return nil, err
+	}
+	return p.cloud.Preview(service.ReferenceApp.Meta.GUID, service.Configuration, p.instanceNaming(r, service),
+		routes, &r.ServiceCreationRequest, logger)
+}
+
 // DeleteService deletes service instance and its dependencies
-func (p *LaunchingService) DeleteService(instanceID string) error {
-	log.Debugf("Deleting service %s...", instanceID)
+func (p *LaunchingService) DeleteService(instanceID string, logger *logging.Logger) error {
+	logger.Debugf("Deleting service %s...", instanceID)
 
 	service, err := p.db.FindInstance(instanceID)
 	if err != nil {
 		return err
 	}
 
-	if err := p.cloud.Deprovision(service.App.Meta.GUID); err != nil {
+	if err := p.cloud.Deprovision(service.App.Meta.GUID, service.Routes, service.SharedServices, logger); err != nil {
 		return err
 	}
 	p.db.RemoveInstance(service.ID)
 	return nil
 }
 
+// GetInstances returns all service instances created by this broker
+func (p *LaunchingService) GetInstances() ([]*extension.ServiceInstanceExtension, error) {
+	return p.db.GetInstances()
+}
+
+// GetInstance returns details of service instance
+func (p *LaunchingService) GetInstance(instanceID string) (*extension.ServiceInstanceExtension, error) {
+	return p.db.FindInstance(instanceID)
+}
+
 // BindService creates a (service instance <-> application) binding
 func (p *LaunchingService) BindService(r *cf.ServiceBindingRequest) (*types.ServiceBindingResponse, error) {
 	instance, err := p.db.FindInstance(r.InstanceID)
@@ -207,6 +249,11 @@ func (p *LaunchingService) BindService(r *cf.ServiceBindingRequest) (*types.Serv
 	return resp, nil
 }
 
+// Abort stops provisionings in progress, they roll back and are stored as failed instances
+func (p *LaunchingService) Abort() {
+	p.cloud.Abort()
+}
+
 func (p *LaunchingService) UpdateBroker() error {
 	vcap := env.GetVcapApplication()
 	username := env.GetEnvVarAsString("AUTH_USER", "")
@@ -219,20 +266,108 @@ func (p *LaunchingService) UpdateBroker() error {
 	if len(vcap.Uris) == 0 || len(vcap.Uris[0]) == 0 {
 		return errors.Annotate(types.InternalServerError, "Application has no url set")
 	}
-	url := fmt.Sprintf("http://%v", vcap.Uris[0])
+	url := fmt.Sprintf("%v://%v", brokerScheme(), vcap.Uris[0])
 
 	return p.cloud.UpdateBroker(vcap.Name, url, username, password)
 }
 
-func (p *LaunchingService) appendInstance(req *cf.ServiceCreationRequest, res *extension.ServiceCreationResponse) error {
+// brokerScheme returns scheme of broker URL registered in Cloud Controller, broker serves HTTPS when certificate is configured
+func brokerScheme() string {
+	if len(env.GetEnvVarAsString("TLS_CERT_FILE", "")) > 0 {
+		return "https"
+	}
+	return "http"
+}
+
+// checkIfInsertable validates service the way InsertToCatalog does, without changing anything
+func (p *LaunchingService) checkIfInsertable(svc *extension.ServiceExtension) error {
+	if !extension.Validate(svc) {
+		return types.InvalidInputError
+	}
+	return p.cloud.CheckServiceName(svc.Name)
+}
+
+// checkIfDeletable fails when service cannot be deleted from catalog holding given number of services
+func (p *LaunchingService) checkIfDeletable(serviceID string, services int) error {
+	if services <= 1 {
+		return errors.Annotate(types.InternalServerError, "Cannot delete the only service offering. Catalog cannot be empty.")
+	}
+	hasInstances, err := p.db.HasInstancesOf(serviceID)
+	if err != nil {
+		return err
+	}
+	if hasInstances {
+		return types.ExistingInstancesError
+	}
+	return nil
+}
+
+// appendInstance stores record of instance being provisioned
+func (p *LaunchingService) appendInstance(req *extension.ServiceCreationRequest) (*extension.ServiceInstanceExtension, error) {
+	now := time.Now().UTC()
 	toAppend := extension.ServiceInstanceExtension{
-		ID:        req.InstanceID,
-		ServiceID: req.ServiceID,
+		ID:               req.InstanceID,
+		ServiceID:        req.ServiceID,
+		PlanID:           req.PlanID,
+		OrganizationGUID: req.OrganizationGUID,
+		SpaceGUID:        req.SpaceGUID,
+		Parameters:       req.Parameters,
+		Context:          req.Context,
+		CreatedBy:        req.Identity.User(),
+		CreatedAt:        now,
+		UpdatedAt:        now,
 	}
+	toAppend.State = extension.InstanceProvisioning
+	if err := p.db.AppendInstance(toAppend); err != nil {
+		return nil, err
+	}
+	return &toAppend, nil
+}
+
+// completeInstance records result of provisioning, nil response means it failed.
+// Only provisioned instances expire, failed ones have no application to delete.
+func (p *LaunchingService) completeInstance(instance *extension.ServiceInstanceExtension,
+	res *extension.ServiceCreationResponse, ttl time.Duration) error {
+
+	instance.UpdatedAt = time.Now().UTC()
+	instance.State = extension.InstanceFailed
 	if res != nil {
-		toAppend.App = res.App
+		instance.App = res.App
+		instance.Routes = res.Routes
+		instance.SharedServices = res.SharedServices
+		instance.State = extension.InstanceStarted
+		if ttl > 0 {
+			expiresAt := instance.CreatedAt.Add(ttl)
+			instance.ExpiresAt = &expiresAt
+		}
 	}
-	return p.db.AppendInstance(toAppend)
+	return p.db.UpdateInstance(instance)
+}
+
+// instanceNaming describes how components cloned for the instance are named.
+// Names of org and space are taken from context object, cloud looks them up when missing.
+func (p *LaunchingService) instanceNaming(r *extension.ServiceCreationRequest, service *extension.ServiceExtension) *extension.Naming {
+	naming := &extension.Naming{Template: service.NamingTemplate}
+	if len(naming.Template) == 0 {
+		naming.Template = extension.DefaultNamingTemplate
+	}
+	naming.Instance = p.normalizeInstanceName(r.Parameters["name"], service.Name)
+	// Use param: no-application-name-change=true not to apply the template to main application name
+	_, naming.KeepMainName = r.Parameters["no-application-name-change"]
+	// Short id is required for ATK
+	naming.ID = strings.Split(r.InstanceID, "-")[0]
+	naming.Org, _ = r.Context["organization_name"].(string)
+	naming.Space, _ = r.Context["space_name"].(string)
+	return naming
+}
+
+// mainAppRoutes returns routes of the main application configured by plan and provisioning parameters
+func mainAppRoutes(service *extension.ServiceExtension, r *extension.ServiceCreationRequest) ([]*extension.Route, error) {
+	routes, err := extension.MainAppRoutes(service.PlanRoutes[r.PlanID], r.Parameters)
+	if err != nil {
+		return nil, errors.Annotatef(types.InvalidInputError, "Invalid route: %v", err)
+	}
+	return routes, nil
 }
 
 func (p *LaunchingService) normalizeInstanceName(instanceName string, serviceName string) string {
@@ -251,13 +386,3 @@ func getNameToNormalize(instanceName string, serviceName string) string {
 func replaceSpacesByDashes(name string) string {
 	return strings.Replace(name, " ", "-", -1)
 }
-
-func addInstanceIdSuffix(instanceID string, instanceName string) string {
-	// Suffix required for ATK
-	idx := strings.Index(instanceID, "-")
-	if idx > 0 {
-		// Take only first part of the GUID
-		instanceName = instanceName + "-" + instanceID[0:idx]
-	}
-	return instanceName
-}
@@ -21,12 +21,15 @@ import (
 	"github.com/cloudfoundry-community/types-cf"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	sfxerrors "github.com/signalfx/golib/errors"
 	"github.com/stretchr/testify/mock"
 	"github.com/trustedanalytics/application-broker/dao"
 	"github.com/trustedanalytics/application-broker/messagebus"
 	"github.com/trustedanalytics/application-broker/service/extension"
 	"github.com/trustedanalytics/go-cf-lib/types"
+	"io/ioutil"
 	"os"
+	"time"
 )
 
 var _ = Describe("Launching service", func() {
@@ -183,21 +186,28 @@ var _ = Describe("Launching service", func() {
 					Service:      svc,
 				}
 				dataCatalog.On("Find", mock.Anything).Return(svcExt)
+				dataCatalog.On("FindInstance", mock.Anything).Return(nil)
 				dataCatalog.On("AppendInstance", mock.Anything).Return()
-				request := new(cf.ServiceCreationRequest)
+				dataCatalog.On("UpdateInstance", mock.Anything).Return(nil)
+				request := new(extension.ServiceCreationRequest)
 				request.Parameters = make(map[string]string)
 
 				cfApi := new(CfMock)
 				expectedErr := errors.New("ERROR!")
-				cfApi.On("Provision", mock.Anything, mock.Anything, mock.Anything).Return(nil, expectedErr)
+				cfApi.On("Provision", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, expectedErr)
 
 				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
-				resp, err := sut.CreateService(request)
+				resp, err := sut.CreateService(request, nil)
 
 				cfApi.AssertExpectations(GinkgoT())
 				Expect(resp).To(BeNil())
 				Expect(err).To(Equal(expectedErr))
-				dataCatalog.AssertCalled(GinkgoT(), "AppendInstance", mock.Anything)
+				dataCatalog.AssertCalled(GinkgoT(), "AppendInstance", mock.MatchedBy(
+					func(instance extension.ServiceInstanceExtension) bool {
+						return instance.State == extension.InstanceProvisioning
+					}))
+				dataCatalog.AssertCalled(GinkgoT(), "UpdateInstance", mock.MatchedBy(
+					func(instance *extension.ServiceInstanceExtension) bool { return instance.State == extension.InstanceFailed }))
 			})
 		})
 
@@ -210,22 +220,206 @@ var _ = Describe("Launching service", func() {
 					Service:      svc,
 				}
 				dataCatalog.On("Find", "service_id").Return(svcExt)
+				dataCatalog.On("FindInstance", mock.Anything).Return(nil)
 				dataCatalog.On("AppendInstance", mock.Anything).Return()
-				request := new(cf.ServiceCreationRequest)
+				dataCatalog.On("UpdateInstance", mock.Anything).Return(nil)
+				request := new(extension.ServiceCreationRequest)
 				request.SpaceGUID = "space_guid"
 				request.ServiceID = "service_id"
+				request.PlanID = "plan_id"
 				request.Parameters = make(map[string]string)
 
 				cfApi := new(CfMock)
 				createAppResp := &extension.ServiceCreationResponse{}
-				cfApi.On("Provision", "source_app_id", mock.Anything, request).Return(createAppResp, nil)
+				cfApi.On("Provision", "source_app_id", mock.Anything, mock.Anything, mock.Anything, &request.ServiceCreationRequest, mock.Anything).Return(createAppResp, nil)
 
 				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
-				resp, _ := sut.CreateService(request)
+				resp, _ := sut.CreateService(request, nil)
 
 				cfApi.AssertExpectations(GinkgoT())
 				Expect(resp).NotTo(BeNil())
-				dataCatalog.AssertCalled(GinkgoT(), "AppendInstance", mock.Anything)
+				dataCatalog.AssertCalled(GinkgoT(), "AppendInstance", mock.MatchedBy(
+					func(instance extension.ServiceInstanceExtension) bool { return instance.PlanID == "plan_id" }))
+				dataCatalog.AssertCalled(GinkgoT(), "UpdateInstance", mock.MatchedBy(
+					func(instance *extension.ServiceInstanceExtension) bool { return instance.State == extension.InstanceStarted }))
+			})
+		})
+
+		Context("when routes are configured", func() {
+			var svcExt *extension.ServiceExtension
+
+			BeforeEach(func() {
+				svcExt = &extension.ServiceExtension{
+					ReferenceApp: types.CfAppResource{Meta: types.CfMeta{GUID: "source_app_id"}},
+					Service:      cf.Service{ID: "service_id", Name: "super_service"},
+					PlanRoutes: map[string][]*extension.Route{
+						"plan_id": {{Domain: "example.com", Path: "/app"}, {Hostname: "extra"}},
+					},
+				}
+				dataCatalog.On("Find", "service_id").Return(svcExt)
+			})
+
+			It("should pass routes of the plan overridden by parameters to cloud", func() {
+				dataCatalog.On("FindInstance", mock.Anything).Return(nil)
+				dataCatalog.On("AppendInstance", mock.Anything).Return()
+				dataCatalog.On("UpdateInstance", mock.Anything).Return(nil)
+				request := new(extension.ServiceCreationRequest)
+				request.ServiceID = "service_id"
+				request.PlanID = "plan_id"
+				request.Parameters = map[string]string{extension.RouteHostnameParameter: "shop"}
+				expected := []*extension.Route{{Domain: "example.com", Hostname: "shop", Path: "/app"}, {Hostname: "extra"}}
+				cfApi := new(CfMock)
+				cfApi.On("Provision", "source_app_id", mock.Anything, mock.Anything, expected, mock.Anything, mock.Anything).
+					Return(&extension.ServiceCreationResponse{Routes: []string{"route_guid"}}, nil)
+
+				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
+				_, err := sut.CreateService(request, nil)
+
+				Expect(err).To(BeNil())
+				cfApi.AssertExpectations(GinkgoT())
+				dataCatalog.AssertCalled(GinkgoT(), "UpdateInstance", mock.MatchedBy(
+					func(instance *extension.ServiceInstanceExtension) bool {
+						return len(instance.Routes) == 1 && instance.Routes[0] == "route_guid"
+					}))
+			})
+
+			It("should refuse invalid hostname before calling cloud foundry", func() {
+				request := new(extension.ServiceCreationRequest)
+				request.ServiceID = "service_id"
+				request.PlanID = "plan_id"
+				request.Parameters = map[string]string{extension.RouteHostnameParameter: "Not A Host"}
+				cfApi := new(CfMock)
+
+				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
+				_, err := sut.CreateService(request, nil)
+
+				Expect(sfxerrors.Tail(err)).To(Equal(types.InvalidInputError))
+				cfApi.AssertNotCalled(GinkgoT(), "Provision", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			})
+		})
+
+		Context("when quota is reached", func() {
+			var request *extension.ServiceCreationRequest
+
+			BeforeEach(func() {
+				dataCatalog.On("Find", "service_id").Return(&extension.ServiceExtension{
+					ReferenceApp: types.CfAppResource{Meta: types.CfMeta{GUID: "source_app_id"}},
+					Service:      cf.Service{ID: "service_id", Name: "super_service"},
+					Quota:        &extension.Quota{Total: 10, PerSpace: 2},
+				})
+				dataCatalog.On("FindInstance", mock.Anything).Return(nil)
+				request = new(extension.ServiceCreationRequest)
+				request.InstanceID = "instance_id"
+				request.ServiceID = "service_id"
+				request.SpaceGUID = "space_guid"
+				request.Parameters = make(map[string]string)
+			})
+
+			It("should refuse before storing the instance and calling cloud foundry", func() {
+				dataCatalog.On("CountInstances", dao.InstanceFilter{ServiceID: "service_id"}).Return(5, nil)
+				dataCatalog.On("CountInstances", dao.InstanceFilter{ServiceID: "service_id", SpaceGUID: "space_guid"}).Return(2, nil)
+
+				cfApi := new(CfMock)
+				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
+				resp, err := sut.CreateService(request, nil)
+
+				Expect(resp).To(BeNil())
+				Expect(sfxerrors.Tail(err)).To(Equal(extension.QuotaExceededError))
+				Expect(sfxerrors.Message(err)).To(ContainSubstring("Quota of 2 instance(s) of service super_service per space"))
+				cfApi.AssertNotCalled(GinkgoT(), "Provision", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				dataCatalog.AssertNotCalled(GinkgoT(), "AppendInstance", mock.Anything)
+			})
+
+			It("should refuse and remove the record when concurrent provisioning reached it", func() {
+				dataCatalog.On("CountInstances", dao.InstanceFilter{ServiceID: "service_id"}).Return(5, nil)
+				// counted before the record is stored and after it, when a concurrent one is stored as well
+				dataCatalog.On("CountInstances", dao.InstanceFilter{ServiceID: "service_id", SpaceGUID: "space_guid"}).Return(1, nil).Once()
+				dataCatalog.On("CountInstances", dao.InstanceFilter{ServiceID: "service_id", SpaceGUID: "space_guid"}).Return(3, nil)
+				dataCatalog.On("AppendInstance", mock.Anything).Return()
+				dataCatalog.On("RemoveInstance", "instance_id").Return(nil)
+
+				cfApi := new(CfMock)
+				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
+				_, err := sut.CreateService(request, nil)
+
+				Expect(sfxerrors.Tail(err)).To(Equal(extension.QuotaExceededError))
+				cfApi.AssertNotCalled(GinkgoT(), "Provision", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				dataCatalog.AssertCalled(GinkgoT(), "RemoveInstance", "instance_id")
+			})
+		})
+
+		Context("when instance with the same ID exists", func() {
+			It("should refuse without touching the existing record", func() {
+				dataCatalog.On("Find", "service_id").Return(&extension.ServiceExtension{
+					Service: cf.Service{ID: "service_id", Name: "super_service"},
+				})
+				dataCatalog.On("FindInstance", "instance_id").Return(&extension.ServiceInstanceExtension{ID: "instance_id"})
+				request := new(extension.ServiceCreationRequest)
+				request.InstanceID = "instance_id"
+				request.ServiceID = "service_id"
+				request.Parameters = make(map[string]string)
+
+				cfApi := new(CfMock)
+				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
+				_, err := sut.CreateService(request, nil)
+
+				Expect(err).To(Equal(types.InstanceAlreadyExistsError))
+				cfApi.AssertNotCalled(GinkgoT(), "Provision", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				dataCatalog.AssertNotCalled(GinkgoT(), "AppendInstance", mock.Anything)
+				dataCatalog.AssertNotCalled(GinkgoT(), "RemoveInstance", mock.Anything)
+			})
+		})
+		Context("when quota per space is set and request has no space", func() {
+			It("should refuse instead of counting instances in all spaces", func() {
+				svcExt := &extension.ServiceExtension{
+					ReferenceApp: types.CfAppResource{Meta: types.CfMeta{GUID: "source_app_id"}},
+					Service:      cf.Service{ID: "service_id", Name: "super_service"},
+					Quota:        &extension.Quota{PerSpace: 2},
+				}
+				dataCatalog.On("Find", "service_id").Return(svcExt)
+				dataCatalog.On("FindInstance", mock.Anything).Return(nil)
+				dataCatalog.On("AppendInstance", mock.Anything).Return()
+				dataCatalog.On("RemoveInstance", "instance_id").Return(nil)
+				request := new(extension.ServiceCreationRequest)
+				request.InstanceID = "instance_id"
+				request.ServiceID = "service_id"
+				request.OrganizationGUID = "org_guid"
+				request.Parameters = make(map[string]string)
+
+				cfApi := new(CfMock)
+				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
+				_, err := sut.CreateService(request, nil)
+
+				Expect(sfxerrors.Tail(err)).To(Equal(types.InvalidInputError))
+				dataCatalog.AssertNotCalled(GinkgoT(), "CountInstances", mock.Anything)
+				cfApi.AssertNotCalled(GinkgoT(), "Provision", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			})
+		})
+
+		Context("when plan quota is reached", func() {
+			It("should refuse with plan name in description", func() {
+				svcExt := &extension.ServiceExtension{
+					ReferenceApp: types.CfAppResource{Meta: types.CfMeta{GUID: "source_app_id"}},
+					Service: cf.Service{ID: "service_id", Name: "super_service",
+						Plans: []*cf.Plan{{ID: "plan_id", Name: "premium"}}},
+					PlanQuotas: map[string]*extension.Quota{"plan_id": {PerOrg: 1}},
+				}
+				dataCatalog.On("Find", "service_id").Return(svcExt)
+				dataCatalog.On("CountInstances", dao.InstanceFilter{ServiceID: "service_id", PlanID: "plan_id",
+					OrganizationGUID: "org_guid"}).Return(1, nil)
+				dataCatalog.On("FindInstance", mock.Anything).Return(nil)
+				dataCatalog.On("AppendInstance", mock.Anything).Return()
+				dataCatalog.On("RemoveInstance", mock.Anything).Return(nil)
+				request := new(extension.ServiceCreationRequest)
+				request.ServiceID = "service_id"
+				request.PlanID = "plan_id"
+				request.OrganizationGUID = "org_guid"
+				request.Parameters = make(map[string]string)
+
+				sut := New(dataCatalog, new(CfMock), nats, CreationStatusFactory{})
+				_, err := sut.CreateService(request, nil)
+
+				Expect(sfxerrors.Message(err)).To(Equal("Quota of 1 instance(s) of plan premium of service super_service per org exceeded"))
 			})
 		})
 
@@ -235,22 +429,311 @@ var _ = Describe("Launching service", func() {
 				nats.(*messagebus.MessageBusMock).On("Publish", mock.Anything).Return()
 
 				dataCatalog.On("Find", mock.Anything).Return(&extension.ServiceExtension{})
+				dataCatalog.On("FindInstance", mock.Anything).Return(nil)
 				dataCatalog.On("AppendInstance", mock.Anything).Return()
+				dataCatalog.On("UpdateInstance", mock.Anything).Return(nil)
 
-				request := &cf.ServiceCreationRequest{}
+				request := &extension.ServiceCreationRequest{}
 				request.Parameters = make(map[string]string)
 				cfApi := new(CfMock)
 				createAppResp := &extension.ServiceCreationResponse{}
-				cfApi.On("Provision", "", mock.Anything, request).Return(createAppResp, nil)
+				cfApi.On("Provision", "", mock.Anything, mock.Anything, mock.Anything, &request.ServiceCreationRequest, mock.Anything).Return(createAppResp, nil)
 
 				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
-				sut.CreateService(request)
+				sut.CreateService(request, nil)
 
 				nats.(*messagebus.MessageBusMock).AssertNumberOfCalls(GinkgoT(), "Publish", 2)
 			})
 		})
 	})
 
+	Describe("import catalog", func() {
+		var (
+			existing *extension.ServiceExtension
+			doc      *extension.CatalogExport
+			appRef   extension.AppReference
+		)
+
+		BeforeEach(func() {
+			existing = &extension.ServiceExtension{
+				Service:      cf.Service{ID: "existingId", Name: "existing", Description: "desc"},
+				ReferenceApp: types.CfAppResource{Meta: types.CfMeta{GUID: "existingApp"}},
+			}
+			appRef = extension.AppReference{Org: "org", Space: "space", Name: "app"}
+			doc = &extension.CatalogExport{
+				Version: extension.CatalogExportVersion,
+				Services: []*extension.ServiceExport{{
+					Service:      cf.Service{Name: "new", Description: "desc", Plans: []*cf.Plan{{Name: "Simple"}}},
+					ReferenceApp: appRef,
+				}},
+			}
+			dataCatalog.On("Get").Return([]*extension.ServiceExtension{existing})
+			cfMock.On("FindAppGUID", appRef).Return("newApp", nil)
+		})
+
+		Context("in merge mode", func() {
+			It("should insert new service resolving reference app", func() {
+				dataCatalog.On("Append", mock.Anything).Return()
+				cfMock.On("CheckIfServiceExists", "new").Return(nil)
+
+				sut := New(dataCatalog, cfMock, nats, CreationStatusFactory{})
+				report, err := sut.ImportCatalog(doc, extension.ImportMerge, false)
+
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(report.Inserted).To(Equal([]string{"new"}))
+				Expect(report.Deleted).To(BeEmpty())
+				appended := dataCatalog.Calls[1].Arguments.Get(0).(*extension.ServiceExtension)
+				Expect(appended.ReferenceApp.Meta.GUID).To(Equal("newApp"))
+				Expect(appended.ID).NotTo(BeEmpty())
+				Expect(appended.Plans[0].ID).NotTo(BeEmpty())
+			})
+		})
+
+		Context("in replace mode with dry run", func() {
+			BeforeEach(func() {
+				cfMock.On("CheckServiceName", "new").Return(nil)
+			})
+
+			It("should report blocked deletion without changing catalog", func() {
+				dataCatalog.On("HasInstancesOf", "existingId").Return(true, nil)
+
+				sut := New(dataCatalog, cfMock, nats, CreationStatusFactory{})
+				report, err := sut.ImportCatalog(doc, extension.ImportReplace, true)
+
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(report.Inserted).To(Equal([]string{"new"}))
+				Expect(report.Errors).To(HaveLen(1))
+				Expect(report.Errors[0].Service).To(Equal("existing"))
+				dataCatalog.AssertNotCalled(GinkgoT(), "Append", mock.Anything)
+				dataCatalog.AssertNotCalled(GinkgoT(), "Remove", mock.Anything)
+				cfMock.AssertNotCalled(GinkgoT(), "CheckIfServiceExists", mock.Anything)
+			})
+
+			It("should report deletion of the only service left", func() {
+				dataCatalog.On("HasInstancesOf", "existingId").Return(false, nil)
+				doc.Services = []*extension.ServiceExport{}
+
+				sut := New(dataCatalog, cfMock, nats, CreationStatusFactory{})
+				report, err := sut.ImportCatalog(doc, extension.ImportReplace, true)
+
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(report.Deleted).To(BeEmpty())
+				Expect(report.Errors).To(Equal([]extension.ImportError{{Service: "existing",
+					Error: "Cannot delete the only service offering. Catalog cannot be empty."}}))
+			})
+
+			It("should count services it would insert", func() {
+				dataCatalog.On("HasInstancesOf", "existingId").Return(false, nil)
+
+				sut := New(dataCatalog, cfMock, nats, CreationStatusFactory{})
+				report, err := sut.ImportCatalog(doc, extension.ImportReplace, true)
+
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(report.Inserted).To(Equal([]string{"new"}))
+				Expect(report.Deleted).To(Equal([]string{"existing"}))
+				Expect(report.Errors).To(BeEmpty())
+			})
+		})
+
+		Context("in merge mode with dry run", func() {
+			It("should report service name registered by different broker", func() {
+				cfMock.On("CheckServiceName", "new").Return(sfxerrors.Annotate(types.InternalServerError,
+					"Service name already registered in different CF broker!"))
+
+				sut := New(dataCatalog, cfMock, nats, CreationStatusFactory{})
+				report, err := sut.ImportCatalog(doc, extension.ImportMerge, true)
+
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(report.Inserted).To(BeEmpty())
+				Expect(report.Errors).To(Equal([]extension.ImportError{{Service: "new",
+					Error: "Service name already registered in different CF broker!"}}))
+			})
+		})
+
+		Context("with unknown mode", func() {
+			It("should return error indicating bad input", func() {
+				sut := New(dataCatalog, cfMock, nats, CreationStatusFactory{})
+				_, err := sut.ImportCatalog(doc, extension.ImportMode("overwrite"), false)
+
+				Expect(err).To(Equal(types.InvalidInputError))
+			})
+		})
+	})
+
+	Describe("sync catalog", func() {
+		var syncFile string
+
+		BeforeEach(func() {
+			file, _ := ioutil.TempFile("", "catalog")
+			file.WriteString(`version: 1
+services:
+- name: new
+  description: desc
+  app: {org: o, space: s, name: app}
+- id: keptId
+  name: kept
+  description: desc
+  app: {org: o, space: s, name: app}
+`)
+			file.Close()
+			syncFile = file.Name() + ".yml"
+			os.Rename(file.Name(), syncFile)
+			os.Setenv("CATALOG_SYNC_FILE", syncFile)
+		})
+
+		AfterEach(func() {
+			os.Remove(syncFile)
+			os.Unsetenv("CATALOG_SYNC_FILE")
+		})
+
+		Context("when service absent in file has instances", func() {
+			It("should apply other changes and report blocked deletion as error", func() {
+				existing := &extension.ServiceExtension{Service: cf.Service{ID: "existingId", Name: "existing"}}
+				kept := &extension.ServiceExtension{
+					Service:      cf.Service{ID: "keptId", Name: "kept", Description: "desc"},
+					ReferenceApp: types.CfAppResource{Meta: types.CfMeta{GUID: "appGuid"}},
+				}
+				dataCatalog.On("Get").Return([]*extension.ServiceExtension{existing, kept})
+				dataCatalog.On("Append", mock.Anything).Return()
+				dataCatalog.On("Find", "existingId").Return(existing, nil)
+				dataCatalog.On("HasInstancesOf", "existingId").Return(true, nil)
+				cfMock.On("FindAppGUID", extension.AppReference{Org: "o", Space: "s", Name: "app"}).Return("appGuid", nil)
+				cfMock.On("CheckIfServiceExists", "new").Return(nil)
+
+				sut := New(dataCatalog, cfMock, nats, CreationStatusFactory{})
+				report, err := sut.SyncCatalog(false)
+
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(report.Inserted).To(Equal([]string{"new"}))
+				Expect(report.Unchanged).To(Equal([]string{"kept"}))
+				Expect(report.Deleted).To(BeEmpty())
+				Expect(report.Errors).To(Equal([]extension.ImportError{
+					{Service: "existing", Error: types.ExistingInstancesError.Error()}}))
+				dataCatalog.AssertNotCalled(GinkgoT(), "Remove", mock.Anything)
+			})
+		})
+
+		Context("when watched file is synchronized with blocked deletion", func() {
+			It("should not retry the sync until the file changes", func() {
+				existing := &extension.ServiceExtension{Service: cf.Service{ID: "existingId", Name: "existing"}}
+				kept := &extension.ServiceExtension{
+					Service:      cf.Service{ID: "keptId", Name: "kept", Description: "desc"},
+					ReferenceApp: types.CfAppResource{Meta: types.CfMeta{GUID: "appGuid"}},
+				}
+				dataCatalog.On("Get").Return([]*extension.ServiceExtension{existing, kept})
+				dataCatalog.On("Append", mock.Anything).Return()
+				dataCatalog.On("Find", "existingId").Return(existing, nil)
+				dataCatalog.On("HasInstancesOf", "existingId").Return(true, nil)
+				cfMock.On("FindAppGUID", extension.AppReference{Org: "o", Space: "s", Name: "app"}).Return("appGuid", nil)
+				cfMock.On("CheckIfServiceExists", "new").Return(nil)
+				info, _ := os.Stat(syncFile)
+
+				sut := New(dataCatalog, cfMock, nats, CreationStatusFactory{})
+				lastModified := sut.syncModifiedCatalog(syncFile, time.Time{})
+
+				Expect(lastModified).To(Equal(info.ModTime()))
+			})
+		})
+
+		Context("when watched file is synchronized with errors which may not happen again", func() {
+			It("should retry the sync on next check", func() {
+				dataCatalog.On("Get").Return([]*extension.ServiceExtension{})
+				cfMock.On("FindAppGUID", mock.Anything).Return("", types.InternalServerError)
+
+				sut := New(dataCatalog, cfMock, nats, CreationStatusFactory{})
+				lastModified := sut.syncModifiedCatalog(syncFile, time.Time{})
+
+				Expect(lastModified.IsZero()).To(BeTrue())
+			})
+		})
+
+		Context("when sync file is not configured", func() {
+			It("should return error", func() {
+				os.Unsetenv("CATALOG_SYNC_FILE")
+
+				sut := New(dataCatalog, cfMock, nats, CreationStatusFactory{})
+				_, err := sut.SyncCatalog(false)
+
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("plans", func() {
+		var svcExt *extension.ServiceExtension
+
+		BeforeEach(func() {
+			svcExt = &extension.ServiceExtension{
+				ReferenceApp: types.CfAppResource{Meta: types.CfMeta{GUID: "source_app_id"}},
+				Service: cf.Service{ID: "service_id", Name: "super_service", Description: "desc",
+					Plans: []*cf.Plan{{ID: "simple_id", Name: "Simple", Description: "SimplePlan", Free: true}}},
+			}
+			dataCatalog.On("Find", "service_id").Return(svcExt)
+			dataCatalog.On("Update", mock.Anything).Return()
+		})
+
+		Context("when adding valid plan", func() {
+			It("should update service with new plan", func() {
+				sut := New(dataCatalog, cfMock, nats, CreationStatusFactory{})
+				err := sut.AddPlan("service_id", &cf.Plan{ID: "paid_id", Name: "paid", Description: "Paid plan"})
+
+				Expect(err).To(BeNil())
+				Expect(svcExt.Plans).To(HaveLen(2))
+				Expect(svcExt.Plans[1].Free).To(BeFalse())
+				dataCatalog.AssertCalled(GinkgoT(), "Update", svcExt)
+			})
+		})
+
+		Context("when adding plan with invalid or duplicated name", func() {
+			It("should return error without updating service", func() {
+				sut := New(dataCatalog, cfMock, nats, CreationStatusFactory{})
+
+				err := sut.AddPlan("service_id", &cf.Plan{ID: "other_id", Name: "has spaces", Description: "d"})
+				Expect(err).To(Equal(types.InvalidInputError))
+				err = sut.AddPlan("service_id", &cf.Plan{ID: "other_id", Name: "Simple", Description: "d"})
+				Expect(sfxerrors.Tail(err)).To(Equal(types.ServiceAlreadyExistsError))
+				dataCatalog.AssertNotCalled(GinkgoT(), "Update", mock.Anything)
+			})
+		})
+
+		Context("when updating not existing plan", func() {
+			It("should return not found error", func() {
+				sut := New(dataCatalog, cfMock, nats, CreationStatusFactory{})
+				err := sut.UpdatePlan("service_id", &cf.Plan{ID: "missing", Name: "x", Description: "d"})
+
+				Expect(sfxerrors.Tail(err)).To(Equal(types.EntityNotFoundError))
+			})
+		})
+
+		Context("when deleting plan with instances", func() {
+			It("should refuse", func() {
+				svcExt.Plans = append(svcExt.Plans, &cf.Plan{ID: "paid_id", Name: "paid", Description: "d"})
+				dataCatalog.On("HasInstancesOfPlan", "service_id", "paid_id").Return(true, nil)
+
+				sut := New(dataCatalog, cfMock, nats, CreationStatusFactory{})
+				err := sut.DeletePlan("service_id", "paid_id")
+
+				Expect(sfxerrors.Tail(err)).To(Equal(types.ExistingInstancesError))
+				Expect(svcExt.Plans).To(HaveLen(2))
+				dataCatalog.AssertNotCalled(GinkgoT(), "Update", mock.Anything)
+			})
+		})
+
+		Context("when deleting plan without instances", func() {
+			It("should remove it from service", func() {
+				svcExt.Plans = append(svcExt.Plans, &cf.Plan{ID: "paid_id", Name: "paid", Description: "d"})
+				dataCatalog.On("HasInstancesOfPlan", "service_id", "paid_id").Return(false, nil)
+
+				sut := New(dataCatalog, cfMock, nats, CreationStatusFactory{})
+				err := sut.DeletePlan("service_id", "paid_id")
+
+				Expect(err).To(BeNil())
+				Expect(svcExt.Plans).To(HaveLen(1))
+				Expect(svcExt.Plans[0].ID).To(Equal("simple_id"))
+			})
+		})
+	})
+
 	Describe("delete service", func() {
 		Context("in case of cloud foundry error", func() {
 			It("should propagate error", func() {
@@ -260,10 +743,10 @@ var _ = Describe("Launching service", func() {
 
 				cfApi := new(CfMock)
 				expectedErr := errors.New("ERROR!")
-				cfApi.On("Deprovision", mock.Anything).Return(expectedErr)
+				cfApi.On("Deprovision", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(expectedErr)
 
 				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
-				err := sut.DeleteService("serviceID")
+				err := sut.DeleteService("serviceID", nil)
 
 				Expect(err).To(Equal(expectedErr))
 			})
@@ -278,14 +761,264 @@ var _ = Describe("Launching service", func() {
 				dataCatalog.On("RemoveInstance", mock.Anything).Return(nil)
 
 				cfApi := new(CfMock)
-				cfApi.On("Deprovision", mock.Anything).Return(nil)
+				cfApi.On("Deprovision", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
 				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
-				err := sut.DeleteService("serviceID")
+				err := sut.DeleteService("serviceID", nil)
 
 				Expect(err).To(BeNil())
 				dataCatalog.AssertCalled(GinkgoT(), "RemoveInstance", "entryId")
 			})
 		})
 	})
+
+	Describe("update broker", func() {
+		BeforeEach(func() {
+			os.Setenv("VCAP_APPLICATION", "{\"name\":\"banana\",\"uris\":[\"banana.example.com\"]}")
+			os.Setenv("AUTH_USER", "admin")
+			os.Setenv("AUTH_PASS", "secret")
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("AUTH_USER")
+			os.Unsetenv("AUTH_PASS")
+			os.Unsetenv("TLS_CERT_FILE")
+		})
+
+		It("should register broker with http url", func() {
+			sut := New(dataCatalog, cfMock, nats, CreationStatusFactory{})
+
+			Expect(sut.UpdateBroker()).To(Succeed())
+			cfMock.AssertCalled(GinkgoT(), "UpdateBroker", "banana", "http://banana.example.com", "admin", "secret")
+		})
+
+		It("should register broker with https url when it serves TLS", func() {
+			os.Setenv("TLS_CERT_FILE", "/etc/broker/tls.crt")
+			sut := New(dataCatalog, cfMock, nats, CreationStatusFactory{})
+
+			Expect(sut.UpdateBroker()).To(Succeed())
+			cfMock.AssertCalled(GinkgoT(), "UpdateBroker", "banana", "https://banana.example.com", "admin", "secret")
+		})
+	})
+
+	Describe("instance expiry", func() {
+		var svcExt *extension.ServiceExtension
+
+		BeforeEach(func() {
+			svcExt = &extension.ServiceExtension{
+				ReferenceApp: types.CfAppResource{Meta: types.CfMeta{GUID: "source_app_id"}},
+				Service:      cf.Service{ID: "service_id", Name: "super_service"},
+				PlanTTLs:     map[string]string{"trial_id": "72h"},
+			}
+			dataCatalog.On("Find", "service_id").Return(svcExt)
+		})
+
+		Context("when provisioning with TTL parameter", func() {
+			It("should store expiry of the instance", func() {
+				dataCatalog.On("FindInstance", mock.Anything).Return(nil)
+				dataCatalog.On("AppendInstance", mock.Anything).Return()
+				dataCatalog.On("UpdateInstance", mock.Anything).Return(nil)
+				request := new(extension.ServiceCreationRequest)
+				request.ServiceID = "service_id"
+				request.PlanID = "trial_id"
+				request.Parameters = map[string]string{extension.TTLParameter: "24h"}
+				cfApi := new(CfMock)
+				cfApi.On("Provision", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&extension.ServiceCreationResponse{}, nil)
+
+				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
+				_, err := sut.CreateService(request, nil)
+
+				Expect(err).To(BeNil())
+				dataCatalog.AssertCalled(GinkgoT(), "UpdateInstance", mock.MatchedBy(
+					func(instance *extension.ServiceInstanceExtension) bool {
+						return instance.ExpiresAt != nil && instance.ExpiresAt.Sub(instance.CreatedAt) == 24*time.Hour
+					}))
+			})
+
+			It("should not set expiry of instance which failed to provision", func() {
+				dataCatalog.On("FindInstance", mock.Anything).Return(nil)
+				dataCatalog.On("AppendInstance", mock.Anything).Return()
+				dataCatalog.On("UpdateInstance", mock.Anything).Return(nil)
+				request := new(extension.ServiceCreationRequest)
+				request.ServiceID = "service_id"
+				request.PlanID = "trial_id"
+				request.Parameters = map[string]string{extension.TTLParameter: "24h"}
+				cfApi := new(CfMock)
+				cfApi.On("Provision", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("push failed"))
+
+				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
+				_, err := sut.CreateService(request, nil)
+
+				Expect(err).To(HaveOccurred())
+				dataCatalog.AssertCalled(GinkgoT(), "UpdateInstance", mock.MatchedBy(
+					func(instance *extension.ServiceInstanceExtension) bool {
+						return instance.State == extension.InstanceFailed && instance.ExpiresAt == nil
+					}))
+			})
+		})
+
+		Context("when requested TTL exceeds TTL of the plan", func() {
+			It("should return error indicating bad input", func() {
+				request := new(extension.ServiceCreationRequest)
+				request.ServiceID = "service_id"
+				request.PlanID = "trial_id"
+				request.Parameters = map[string]string{extension.TTLParameter: "100h"}
+				cfApi := new(CfMock)
+
+				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
+				_, err := sut.CreateService(request, nil)
+
+				Expect(sfxerrors.Tail(err)).To(Equal(types.InvalidInputError))
+				cfApi.AssertNotCalled(GinkgoT(), "Provision", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			})
+		})
+
+		Context("when extending instance", func() {
+			It("should move expiry and reset warning", func() {
+				expiresAt := time.Now().Add(time.Hour)
+				instance := &extension.ServiceInstanceExtension{ID: "instance_id", ServiceID: "service_id",
+					PlanID: "trial_id", ExpiresAt: &expiresAt, ExpiryWarned: true}
+				dataCatalog.On("FindInstance", "instance_id").Return(instance)
+				dataCatalog.On("UpdateInstance", instance).Return(nil)
+
+				sut := New(dataCatalog, cfMock, nats, CreationStatusFactory{})
+				extended, err := sut.ExtendInstance("instance_id", "")
+
+				Expect(err).To(BeNil())
+				Expect(extended.ExpiryWarned).To(BeFalse())
+				Expect(extended.ExpiresAt.Sub(time.Now())).To(BeNumerically(">", 71*time.Hour))
+			})
+		})
+
+		Context("when checking expiry", func() {
+			It("should delete expired and warn about expiring instances", func() {
+				nats = new(messagebus.MessageBusMock)
+				nats.(*messagebus.MessageBusMock).On("Publish", mock.Anything).Return()
+				expired, soon := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+				expiredInstance := &extension.ServiceInstanceExtension{ID: "expired_id", ServiceID: "service_id",
+					ExpiresAt: &expired, App: types.CfAppResource{Meta: types.CfMeta{GUID: "expired_app"}}}
+				expiringInstance := &extension.ServiceInstanceExtension{ID: "expiring_id", ServiceID: "service_id",
+					ExpiresAt: &soon, App: types.CfAppResource{Meta: types.CfMeta{GUID: "expiring_app"}}}
+				dataCatalog.On("GetInstancesExpiringBefore", mock.Anything).Return(
+					[]*extension.ServiceInstanceExtension{expiredInstance, expiringInstance}, nil)
+				dataCatalog.On("FindInstance", "expired_id").Return(expiredInstance)
+				dataCatalog.On("RemoveInstance", "expired_id").Return(nil)
+				dataCatalog.On("UpdateInstance", expiringInstance).Return(nil)
+				cfApi := new(CfMock)
+				cfApi.On("Deprovision", "expired_app", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
+				err := sut.ExpireInstances(24 * time.Hour)
+
+				Expect(err).To(BeNil())
+				cfApi.AssertCalled(GinkgoT(), "Deprovision", "expired_app", mock.Anything, mock.Anything, mock.Anything)
+				Expect(expiringInstance.ExpiryWarned).To(BeTrue())
+				dataCatalog.AssertNotCalled(GinkgoT(), "UpdateInstance", expiredInstance)
+				nats.(*messagebus.MessageBusMock).AssertNumberOfCalls(GinkgoT(), "Publish", 2)
+			})
+
+			It("should skip expired instances which failed to provision", func() {
+				expired := time.Now().Add(-time.Minute)
+				failedInstance := &extension.ServiceInstanceExtension{ID: "failed_id", ServiceID: "service_id",
+					ExpiresAt: &expired, State: extension.InstanceFailed}
+				dataCatalog.On("GetInstancesExpiringBefore", mock.Anything).Return(
+					[]*extension.ServiceInstanceExtension{failedInstance}, nil)
+				cfApi := new(CfMock)
+
+				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
+				err := sut.ExpireInstances(24 * time.Hour)
+
+				Expect(err).To(BeNil())
+				cfApi.AssertNotCalled(GinkgoT(), "Deprovision", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				dataCatalog.AssertNotCalled(GinkgoT(), "RemoveInstance", mock.Anything)
+			})
+		})
+	})
+
+	Describe("instance schedules", func() {
+		var (
+			instance *extension.ServiceInstanceExtension
+			cfApi    *CfMock
+			monday   time.Time
+		)
+
+		BeforeEach(func() {
+			monday = time.Date(2016, time.May, 2, 0, 0, 0, 0, time.UTC)
+			instance = &extension.ServiceInstanceExtension{ID: "instance_id", ServiceID: "service_id",
+				PlanID: "plan_id", State: extension.InstanceStarted,
+				App: types.CfAppResource{Meta: types.CfMeta{GUID: "app_guid"}}}
+			svcExt := &extension.ServiceExtension{
+				Service: cf.Service{ID: "service_id"},
+				PlanSchedules: map[string]*extension.Schedule{
+					"plan_id": {Start: "08:00", Stop: "18:00", Days: []string{"mon", "tue", "wed", "thu", "fri"}},
+				},
+			}
+			dataCatalog.On("Get").Return([]*extension.ServiceExtension{svcExt})
+			dataCatalog.On("GetInstances").Return([]*extension.ServiceInstanceExtension{instance})
+			dataCatalog.On("FindInstance", "instance_id").Return(instance)
+			dataCatalog.On("UpdateInstance", instance).Return(nil)
+			cfApi = new(CfMock)
+			cfApi.On("StopInstance", "app_guid", mock.Anything).Return(nil)
+			cfApi.On("StartInstance", "app_guid", mock.Anything).Return(nil)
+		})
+
+		Context("when working hours of the plan end", func() {
+			It("should stop the instance", func() {
+				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
+				err := sut.ApplySchedules(monday.Add(17*time.Hour+59*time.Minute), monday.Add(18*time.Hour))
+
+				Expect(err).To(BeNil())
+				cfApi.AssertCalled(GinkgoT(), "StopInstance", "app_guid", mock.Anything)
+				Expect(instance.State).To(Equal(extension.InstanceStopped))
+			})
+		})
+
+		Context("when instance was started manually outside of working hours", func() {
+			It("should leave it running until next transition", func() {
+				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
+				err := sut.ApplySchedules(monday.Add(20*time.Hour), monday.Add(20*time.Hour+time.Minute))
+
+				Expect(err).To(BeNil())
+				cfApi.AssertNotCalled(GinkgoT(), "StopInstance", mock.Anything, mock.Anything)
+			})
+		})
+
+		Context("when instance schedule spans midnight", func() {
+			It("should start the instance in the evening", func() {
+				instance.State = extension.InstanceStopped
+				instance.Schedule = &extension.Schedule{Start: "22:00", Stop: "06:00"}
+
+				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
+				err := sut.ApplySchedules(monday.Add(21*time.Hour+59*time.Minute), monday.Add(22*time.Hour))
+
+				Expect(err).To(BeNil())
+				cfApi.AssertCalled(GinkgoT(), "StartInstance", "app_guid", mock.Anything)
+				Expect(instance.State).To(Equal(extension.InstanceStarted))
+			})
+		})
+
+		Context("when stopping instance which failed to provision", func() {
+			It("should return conflict error without touching the cloud", func() {
+				instance.State = extension.InstanceFailed
+				instance.App = types.CfAppResource{}
+
+				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
+				_, err := sut.StopInstance("instance_id", nil)
+
+				Expect(sfxerrors.Tail(err)).To(Equal(extension.InstanceNotProvisionedError))
+				cfApi.AssertNotCalled(GinkgoT(), "StopInstance", mock.Anything, mock.Anything)
+				dataCatalog.AssertNotCalled(GinkgoT(), "UpdateInstance", mock.Anything)
+			})
+		})
+
+		Context("when setting invalid schedule", func() {
+			It("should return error indicating bad input", func() {
+				sut := New(dataCatalog, cfApi, nats, CreationStatusFactory{})
+				_, err := sut.SetInstanceSchedule("instance_id", &extension.Schedule{Start: "8am", Stop: "18:00"})
+
+				Expect(sfxerrors.Tail(err)).To(Equal(types.InvalidInputError))
+				dataCatalog.AssertNotCalled(GinkgoT(), "UpdateInstance", mock.Anything)
+			})
+		})
+	})
 })
@@ -0,0 +1,93 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package service
+
+import (
+	"fmt"
+
+	"github.com/signalfx/golib/errors"
+	"github.com/trustedanalytics/application-broker/dao"
+	"github.com/trustedanalytics/application-broker/service/extension"
+	"github.com/trustedanalytics/go-cf-lib/types"
+)
+
+// checkQuotas fails when new instance exceeds quota of the service or of requested plan.
+// Stored tells whether record of the new instance is already stored and counted.
+func (p *LaunchingService) checkQuotas(svc *extension.ServiceExtension, r *extension.ServiceCreationRequest,
+	stored bool) error {
+
+	scope := dao.InstanceFilter{ServiceID: svc.ID}
+	if err := p.checkQuota(svc.Quota, scope, "service "+svc.Name, r, stored); err != nil {
+		return err
+	}
+
+	quota, ok := svc.PlanQuotas[r.PlanID]
+	if !ok {
+		return nil
+	}
+	planName := r.PlanID
+	if idx := findPlan(svc.Plans, r.PlanID); idx >= 0 {
+		planName = svc.Plans[idx].Name
+	}
+	scope.PlanID = r.PlanID
+	return p.checkQuota(quota, scope, fmt.Sprintf("plan %v of service %v", planName, svc.Name), r, stored)
+}
+
+func (p *LaunchingService) checkQuota(quota *extension.Quota, scope dao.InstanceFilter, subject string,
+	r *extension.ServiceCreationRequest, stored bool) error {
+
+	if quota == nil {
+		return nil
+	}
+
+	inOrg := scope
+	inOrg.OrganizationGUID = r.OrganizationGUID
+	inSpace := scope
+	inSpace.SpaceGUID = r.SpaceGUID
+	limits := []struct {
+		limit  int
+		filter dao.InstanceFilter
+		suffix string
+		// filter without org or space GUID would count instances everywhere
+		unscoped bool
+	}{
+		{quota.Total, scope, "in total", false},
+		{quota.PerOrg, inOrg, "per org", len(r.OrganizationGUID) == 0},
+		{quota.PerSpace, inSpace, "per space", len(r.SpaceGUID) == 0},
+	}
+
+	for _, l := range limits {
+		if l.limit == 0 {
+			continue
+		}
+		if l.unscoped {
+			return errors.Annotatef(types.InvalidInputError, "Quota of %v %v requires organization and space GUIDs",
+				subject, l.suffix)
+		}
+		count, err := p.db.CountInstances(l.filter)
+		if err != nil {
+			return err
+		}
+		if !stored {
+			count++
+		}
+		if count > l.limit {
+			return errors.Annotatef(extension.QuotaExceededError, "Quota of %d instance(s) of %v %v exceeded",
+				l.limit, subject, l.suffix)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"sync"
+)
+
+// ExporterMock keeps exported spans in memory
+type ExporterMock struct {
+	mutex sync.Mutex
+	spans []SpanData
+}
+
+func (e *ExporterMock) Export(spans []SpanData) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// Spans returns spans exported so far, after flushing finished ones
+func (e *ExporterMock) Spans() []SpanData {
+	Flush()
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]SpanData{}, e.spans...)
+}
+
+// Find returns first exported span of given name
+func (e *ExporterMock) Find(name string) (SpanData, bool) {
+	for _, span := range e.Spans() {
+		if span.Name == name {
+			return span, true
+		}
+	}
+	return SpanData{}, false
+}
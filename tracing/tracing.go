@@ -0,0 +1,305 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	log "github.com/cihub/seelog"
+	"github.com/trustedanalytics/application-broker/env"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceParentHeader carries W3C trace context of the caller
+const TraceParentHeader = "traceparent"
+
+// Kind tells the role of a span, values match OTLP span kinds
+type Kind int
+
+const (
+	InternalKind Kind = 1
+	ServerKind   Kind = 2
+	ClientKind   Kind = 3
+)
+
+const (
+	exportInterval = 5 * time.Second
+	maxBatchSize   = 512
+	maxQueueSize   = 4096
+)
+
+var traceParentPattern = regexp.MustCompile(`^00-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}$`)
+
+// SpanData is what exporters get for finished span
+type SpanData struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Name         string
+	Kind         Kind
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]string
+	// Error describes failure of the traced work, it is empty on success
+	Error string
+}
+
+// Span is a timed piece of work. All methods accept nil span, which is what Start returns when tracing is disabled.
+type Span struct {
+	mutex   sync.Mutex
+	data    SpanData
+	ended   bool
+	batcher *batcher
+}
+
+var (
+	mutex   sync.RWMutex
+	current *batcher
+)
+
+// Initialize enables tracing when TRACING_EXPORTER is set to "otlp" or "file"
+func Initialize() {
+	switch exporter := env.GetEnvVarAsString("TRACING_EXPORTER", ""); exporter {
+	case "":
+		return
+	case "otlp":
+		SetExporter(NewOTLPExporter(
+			env.GetEnvVarAsString("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318"),
+			env.GetEnvVarAsString("OTEL_SERVICE_NAME", "application-broker")))
+	case "file":
+		fileExporter, err := NewFileExporter(env.GetEnvVarAsString("TRACING_FILE", "traces.jsonl"))
+		if err != nil {
+			log.Errorf("Tracing disabled, cannot open trace file: %v", err)
+			return
+		}
+		SetExporter(fileExporter)
+	default:
+		log.Errorf("Tracing disabled, unknown exporter %v. Use otlp or file", exporter)
+	}
+}
+
+// SetExporter sends finished spans to exporter in batches. Spans still waiting for previous exporter are flushed to it.
+// Nil exporter disables tracing.
+func SetExporter(exporter Exporter) {
+	mutex.Lock()
+	previous := current
+	current = nil
+	if exporter != nil {
+		current = newBatcher(exporter)
+	}
+	mutex.Unlock()
+
+	if previous != nil {
+		previous.stop()
+	}
+}
+
+// Flush exports spans finished so far
+func Flush() {
+	mutex.RLock()
+	b := current
+	mutex.RUnlock()
+	if b != nil {
+		b.flush()
+	}
+}
+
+// Start begins span as a child of parent, or in a new trace when parent is nil
+func Start(parent *Span, name string, kind Kind) *Span {
+	if parent == nil {
+		return start(newID(16), "", name, kind)
+	}
+	return start(parent.data.TraceID, parent.data.SpanID, name, kind)
+}
+
+// StartRemote begins span continuing trace of the caller given in traceparent header
+func StartRemote(traceParent string, name string, kind Kind) *Span {
+	match := traceParentPattern.FindStringSubmatch(strings.TrimSpace(traceParent))
+	if match == nil || isZero(match[1]) || isZero(match[2]) {
+		return start(newID(16), "", name, kind)
+	}
+	return start(match[1], match[2], name, kind)
+}
+
+func start(traceID, parentSpanID, name string, kind Kind) *Span {
+	mutex.RLock()
+	b := current
+	mutex.RUnlock()
+	if b == nil {
+		return nil
+	}
+	return &Span{
+		data: SpanData{
+			TraceID:      traceID,
+			SpanID:       newID(8),
+			ParentSpanID: parentSpanID,
+			Name:         name,
+			Kind:         kind,
+			Start:        time.Now(),
+			Attributes:   map[string]string{},
+		},
+		batcher: b,
+	}
+}
+
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data.Attributes[key] = value
+}
+
+// End finishes span, marking it failed when err is not nil. Only first call has effect.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	if err != nil {
+		s.data.Error = err.Error()
+	}
+	data := s.data
+	data.Attributes = make(map[string]string, len(s.data.Attributes))
+	for key, value := range s.data.Attributes {
+		data.Attributes[key] = value
+	}
+	s.mutex.Unlock()
+
+	s.batcher.add(data)
+}
+
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.data.TraceID
+}
+
+func (s *Span) SpanID() string {
+	if s == nil {
+		return ""
+	}
+	return s.data.SpanID
+}
+
+// TraceParent returns value of traceparent header propagating the span to called services
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%v-%v-01", s.data.TraceID, s.data.SpanID)
+}
+
+func newID(bytes int) string {
+	id := make([]byte, bytes)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func isZero(id string) bool {
+	return strings.Trim(id, "0") == ""
+}
+
+// batcher exports finished spans in background, so that traced operations do not wait for exporter
+type batcher struct {
+	exporter Exporter
+	spans    chan SpanData
+	flushes  chan chan struct{}
+	done     chan struct{}
+}
+
+func newBatcher(exporter Exporter) *batcher {
+	b := &batcher{
+		exporter: exporter,
+		spans:    make(chan SpanData, maxQueueSize),
+		flushes:  make(chan chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// add queues span for export, dropping it when exporter cannot keep up
+func (b *batcher) add(span SpanData) {
+	select {
+	case b.spans <- span:
+	default:
+		log.Warnf("Tracing queue is full, span %v dropped", span.Name)
+	}
+}
+
+func (b *batcher) flush() {
+	flushed := make(chan struct{})
+	select {
+	case b.flushes <- flushed:
+		<-flushed
+	case <-b.done:
+	}
+}
+
+func (b *batcher) stop() {
+	b.flush()
+	close(b.done)
+}
+
+func (b *batcher) run() {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	batch := []SpanData{}
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := b.exporter.Export(batch); err != nil {
+			log.Warnf("Cannot export %v spans: %v", len(batch), err)
+		}
+		batch = []SpanData{}
+	}
+
+	for {
+		select {
+		case span := <-b.spans:
+			batch = append(batch, span)
+			if len(batch) >= maxBatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case flushed := <-b.flushes:
+			for queued := len(b.spans); queued > 0; queued-- {
+				batch = append(batch, <-b.spans)
+			}
+			export()
+			close(flushed)
+		case <-b.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,174 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var _ = Describe("Tracing", func() {
+
+	var (
+		exporter *ExporterMock
+	)
+
+	BeforeEach(func() {
+		exporter = &ExporterMock{}
+		SetExporter(exporter)
+	})
+
+	AfterEach(func() {
+		SetExporter(nil)
+	})
+
+	It("should not create spans when tracing is disabled", func() {
+		SetExporter(nil)
+
+		span := Start(nil, "provision", ServerKind)
+		span.SetAttribute("key", "value")
+		span.End(nil)
+
+		Expect(span).To(BeNil())
+		Expect(span.TraceParent()).To(BeEmpty())
+	})
+
+	It("should export child spans in the trace of their parent", func() {
+		parent := Start(nil, "provision", ServerKind)
+		child := Start(parent, "discovery", InternalKind)
+		child.SetAttribute("app", "source")
+		child.End(nil)
+		parent.End(errors.New("quota exceeded"))
+
+		spans := exporter.Spans()
+		Expect(spans).To(HaveLen(2))
+		Expect(spans[0].Name).To(Equal("discovery"))
+		Expect(spans[0].TraceID).To(Equal(parent.TraceID()))
+		Expect(spans[0].ParentSpanID).To(Equal(parent.SpanID()))
+		Expect(spans[0].Attributes).To(Equal(map[string]string{"app": "source"}))
+		Expect(spans[0].Error).To(BeEmpty())
+		Expect(spans[1].ParentSpanID).To(BeEmpty())
+		Expect(spans[1].Kind).To(Equal(ServerKind))
+		Expect(spans[1].Error).To(Equal("quota exceeded"))
+		Expect(spans[1].End).NotTo(BeTemporally("<", spans[1].Start))
+	})
+
+	It("should export span once", func() {
+		span := Start(nil, "copy_bits", InternalKind)
+		span.End(errors.New("copy failed"))
+		span.End(nil)
+
+		spans := exporter.Spans()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Error).To(Equal("copy failed"))
+	})
+
+	It("should continue trace of the caller", func() {
+		span := StartRemote("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "provision", ServerKind)
+
+		Expect(span.TraceID()).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+		Expect(span.TraceParent()).To(MatchRegexp("^00-4bf92f3577b34da6a3ce929d0e0e4736-[0-9a-f]{16}-01$"))
+		span.End(nil)
+		Expect(exporter.Spans()[0].ParentSpanID).To(Equal("00f067aa0ba902b7"))
+	})
+
+	It("should start new trace when traceparent header is invalid", func() {
+		for _, header := range []string{"", "garbage", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"} {
+			span := StartRemote(header, "provision", ServerKind)
+
+			Expect(span.TraceID()).To(MatchRegexp("^[0-9a-f]{32}$"))
+			Expect(span.TraceID()).NotTo(Equal(strings.Repeat("0", 32)))
+		}
+	})
+
+	Describe("file exporter", func() {
+		It("should append spans as JSON lines", func() {
+			dir, _ := ioutil.TempDir("", "traces")
+			defer os.RemoveAll(dir)
+			fileExporter, err := NewFileExporter(filepath.Join(dir, "traces.jsonl"))
+			Expect(err).NotTo(HaveOccurred())
+			SetExporter(fileExporter)
+
+			Start(nil, "provision", ServerKind).End(nil)
+			Start(nil, "deprovision", ServerKind).End(errors.New("failed"))
+			Flush()
+
+			raw, _ := ioutil.ReadFile(filepath.Join(dir, "traces.jsonl"))
+			lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+			Expect(lines).To(HaveLen(2))
+			span := map[string]interface{}{}
+			Expect(json.Unmarshal([]byte(lines[1]), &span)).To(Succeed())
+			Expect(span["name"]).To(Equal("deprovision"))
+			Expect(span["kind"]).To(Equal("server"))
+			Expect(span["error"]).To(Equal("failed"))
+			Expect(span["trace_id"]).To(MatchRegexp("^[0-9a-f]{32}$"))
+		})
+	})
+
+	Describe("OTLP exporter", func() {
+		It("should post spans to collector in OTLP JSON encoding", func() {
+			var path string
+			received := map[string]interface{}{}
+			collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				path = req.URL.Path
+				json.NewDecoder(req.Body).Decode(&received)
+			}))
+			defer collector.Close()
+
+			err := NewOTLPExporter(collector.URL+"/", "application-broker").Export([]SpanData{{
+				TraceID:    "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:     "00f067aa0ba902b7",
+				Name:       "GET /v2/apps/:guid/summary",
+				Kind:       ClientKind,
+				Attributes: map[string]string{"http.method": "GET"},
+				Error:      "Cloud Controller responded with status 404",
+			}})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(path).To(Equal("/v1/traces"))
+			resourceSpans := received["resourceSpans"].([]interface{})[0].(map[string]interface{})
+			Expect(resourceSpans["resource"]).To(Equal(map[string]interface{}{"attributes": []interface{}{
+				map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "application-broker"}},
+			}}))
+			span := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})[0].(map[string]interface{})
+			Expect(span["traceId"]).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+			Expect(span["spanId"]).To(Equal("00f067aa0ba902b7"))
+			Expect(span["kind"]).To(BeNumerically("==", ClientKind))
+			Expect(span["status"]).To(Equal(map[string]interface{}{
+				"code": float64(2), "message": "Cloud Controller responded with status 404"}))
+		})
+
+		It("should fail when collector rejects spans", func() {
+			collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+			}))
+			defer collector.Close()
+
+			err := NewOTLPExporter(collector.URL, "application-broker").Export([]SpanData{})
+
+			Expect(err).To(MatchError(ContainSubstring("400")))
+		})
+	})
+})